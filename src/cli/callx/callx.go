@@ -27,12 +27,34 @@ import (
 )
 
 var (
-	log = xlog.NewStdLog(xlog.Level(xlog.INFO))
+	log       = xlog.NewStdLog(xlog.Level(xlog.INFO))
+	transport = xrpc.DefaultTransport
 )
 
+// SetRPCConfig used to make the calls as the nodes do, over the rpc tls and signed with the rpc-secret.
+func SetRPCConfig(conf *config.Config) error {
+	transport = xrpc.DefaultTransport
+	if conf.RPC.TLSCert != "" {
+		tlsTransport, err := xrpc.NewTLSTransport(transport, xrpc.TLSOptions{
+			CA:             conf.RPC.TLSCA,
+			Cert:           conf.RPC.TLSCert,
+			Key:            conf.RPC.TLSKey,
+			VerifyEndpoint: conf.RPC.TLSVerifyEndpoint,
+		})
+		if err != nil {
+			return err
+		}
+		transport = tlsTransport
+	}
+	if conf.Server.RPCSecret != "" {
+		transport = xrpc.NewAuthTransport(transport, conf.Server.RPCSecret)
+	}
+	return nil
+}
+
 // xrpc client
 func GetClient(conn string) (*xrpc.Client, func(), error) {
-	client, err := xrpc.NewClient(conn, 1000)
+	client, err := xrpc.NewTransportClient(transport, "", conn, 1000)
 	if err != nil {
 		return nil, nil, fmt.Errorf("get.client.error[%v]", err)
 	}
@@ -479,28 +501,27 @@ func DoApplyLogRPC(node string, backupdir string) error {
 }
 
 // raft
-func AddNodeRPC(node string, nodes []string) error {
+func AddNodeRPC(node string, nodes []string, force bool) (*model.NodeRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
-
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer cleanup()
 
 	method := model.RPCNodesAdd
 	req := model.NewNodeRPCRequest()
 	req.Nodes = nodes
+	req.Force = force
 	rsp := model.NewNodeRPCResponse(model.OK)
 	err = cli.Call(method, req, rsp)
 
-	return err
+	return rsp, err
 }
 
-func AddIdleNodeRPC(node string, nodes []string) error {
+func AddIdleNodeRPC(node string, nodes []string) (*model.NodeRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
-
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer cleanup()
 
@@ -510,13 +531,30 @@ func AddIdleNodeRPC(node string, nodes []string) error {
 	rsp := model.NewNodeRPCResponse(model.OK)
 	err = cli.Call(method, req, rsp)
 
-	return err
+	return rsp, err
 }
 
-func RemoveNodeRPC(node string, nodes []string) error {
+func AddWitnessNodeRPC(node string, nodes []string, force bool) (*model.NodeRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCWitnessNodesAdd
+	req := model.NewNodeRPCRequest()
+	req.Nodes = nodes
+	req.Force = force
+	rsp := model.NewNodeRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RemoveNodeRPC(node string, nodes []string) (*model.NodeRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
 	}
 	defer cleanup()
 
@@ -525,13 +563,14 @@ func RemoveNodeRPC(node string, nodes []string) error {
 	req.Nodes = nodes
 	rsp := model.NewNodeRPCResponse(model.OK)
 	err = cli.Call(method, req, rsp)
-	return err
+
+	return rsp, err
 }
 
-func RemoveIdleNodeRPC(node string, nodes []string) error {
+func RemoveIdleNodeRPC(node string, nodes []string) (*model.NodeRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer cleanup()
 
@@ -540,7 +579,8 @@ func RemoveIdleNodeRPC(node string, nodes []string) error {
 	req.Nodes = nodes
 	rsp := model.NewNodeRPCResponse(model.OK)
 	err = cli.Call(method, req, rsp)
-	return err
+
+	return rsp, err
 }
 
 func GetNodesRPC(node string) (*model.NodeRPCResponse, error) {
@@ -573,6 +613,22 @@ func GetRaftStatusRPC(node string) (*model.RaftStatusRPCResponse, error) {
 	return rsp, err
 }
 
+func GetRaftHistoryRPC(node string, limit int) (*model.RaftHistoryRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftHistory
+	req := model.NewRaftHistoryRPCRequest()
+	req.Limit = limit
+	rsp := model.NewRaftHistoryRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
 func EnableRaftRPC(node string) (*model.HARPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
@@ -634,6 +690,122 @@ func TryToLeaderRPC(node string) (*model.HARPCResponse, error) {
 	return rsp, err
 }
 
+// TransferLeaderRPC used to transfer the leadership from the leader to the target node.
+// The transfer may take a long time, so the call has no timeout here.
+func TransferLeaderRPC(leader string, to string) (*model.HATransferLeaderRPCResponse, error) {
+	cli, cleanup, err := GetClient(leader)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCHATransferLeader
+	req := model.NewHATransferLeaderRPCRequest()
+	req.To = to
+	rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// EnterMaintenanceRPC used to put the node in maintenance for the duration.
+// The leader hands over the leadership first, it may take a long time, so the call has no timeout here.
+func EnterMaintenanceRPC(node string, duration time.Duration, reason string) (*model.HAMaintenanceRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCHAEnterMaintenance
+	req := model.NewHAMaintenanceRPCRequest()
+	req.Duration = int64(duration / time.Millisecond)
+	req.Reason = reason
+	rsp := model.NewHAMaintenanceRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// ExitMaintenanceRPC used to take the node out of maintenance.
+func ExitMaintenanceRPC(node string) (*model.HAMaintenanceRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCHAExitMaintenance
+	req := model.NewHAMaintenanceRPCRequest()
+	rsp := model.NewHAMaintenanceRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// RaftUnfreezeRPC used to clear the failover freeze of the node.
+func RaftUnfreezeRPC(node string) (*model.RaftStatusRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftUnfreeze
+	req := model.NewRaftStatusRPCRequest()
+	rsp := model.NewRaftStatusRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// RaftFreezeRPC used to set the cluster freeze on the leader, until is the unix ms it expires, 0 means never.
+func RaftFreezeRPC(leader string, from string, until int64, reason string) (*model.RaftFreezeRPCResponse, error) {
+	cli, cleanup, err := GetClient(leader)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftFreeze
+	req := model.NewRaftFreezeRPCRequest()
+	req.From = from
+	req.Until = until
+	req.Reason = reason
+	rsp := model.NewRaftFreezeRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// RaftSetTimingsRPC used to change the raft timings on the leader, the zero fields are unchanged.
+func RaftSetTimingsRPC(leader string, from string, timings *model.RaftTimings) (*model.RaftTimingsRPCResponse, error) {
+	cli, cleanup, err := GetClient(leader)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftSetTimings
+	req := model.NewRaftTimingsRPCRequest()
+	req.From = from
+	req.Timings = *timings
+	rsp := model.NewRaftTimingsRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// RaftDRPromoteRPC used to detach the standby cluster from the primary and promote its leader.
+func RaftDRPromoteRPC(leader string, from string) (*model.RaftDRPromoteRPCResponse, error) {
+	cli, cleanup, err := GetClient(leader)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftDRPromote
+	req := model.NewRaftDRPromoteRPCRequest()
+	req.From = from
+	rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
 func RaftEnablePurgeBinlogRPC(node string) error {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
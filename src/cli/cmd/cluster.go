@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 	"xbase/common"
 
 	"github.com/spf13/cobra"
@@ -31,6 +32,14 @@ var (
 	logDir        string
 	startDatatime string
 	stopDatatime  string
+	forceAdd      bool
+
+	// the witness runs no mysql, shows this in the mysql columns
+	witnessState = raft.WITNESS.String()
+	witnessInfo  = "-"
+
+	// the leader has no lag, shows this in the lag column
+	leaderLagInfo = "-"
 )
 
 func NewClusterCommand() *cobra.Command {
@@ -41,9 +50,13 @@ func NewClusterCommand() *cobra.Command {
 
 	cmd.AddCommand(NewClusterAddCommand())
 	cmd.AddCommand(NewClusterIdleAddCommand())
+	cmd.AddCommand(NewClusterWitnessAddCommand())
 	cmd.AddCommand(NewClusterRemoveCommand())
 	cmd.AddCommand(NewClusterIdleRemoveCommand())
 	cmd.AddCommand(NewClusterStatusCommand())
+	cmd.AddCommand(NewClusterFreezeCommand())
+	cmd.AddCommand(NewClusterUnfreezeCommand())
+	cmd.AddCommand(NewClusterDRPromoteCommand())
 	cmd.AddCommand(NewClusterMysqlCommand())
 	cmd.AddCommand(NewClusterGTIDCommand())
 	cmd.AddCommand(NewClusterRaftCommand())
@@ -59,6 +72,7 @@ func NewClusterAddCommand() *cobra.Command {
 		Short: "add peers to leader(if there is no leader, add to local)",
 		Run:   clusterAddCommandFn,
 	}
+	cmd.Flags().BoolVar(&forceAdd, "force", false, "--force, add the nodes even if the majority would sit in one zone")
 
 	return cmd
 }
@@ -80,18 +94,29 @@ func clusterAddCommandFn(cmd *cobra.Command, args []string) {
 			log.Warning("%v", err)
 		}
 		log.Warning("cluster.prepare.to.add.nodes[%v].to.leader[%v]", args[0], leader)
-		if leader != "" {
-			err := callx.AddNodeRPC(leader, nodes)
-			ErrorOK(err)
-		} else {
+		target := leader
+		if target == "" {
+			target = self
+		}
+		if leader == "" {
 			log.Warning("cluster.canot.found.leader.forward.to[%v]", self)
-			err := callx.AddNodeRPC(self, nodes)
-			ErrorOK(err)
 		}
+		// the leader returns after the change is committed
+		rsp, err := callx.AddNodeRPC(target, nodes, forceAdd)
+		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("cluster.add.nodes.to.leader[%v].done", leader)
 	}
 }
 
+// membershipChangeDone reports the nodes which have adopted the epoch of the membership change.
+func membershipChangeDone(rsp *model.NodeRPCResponse) {
+	if adopted := rsp.GetAdopted(); len(adopted) > 0 {
+		log.Warning("cluster.membership.change.epoch[%v].adopted.by[%v]", rsp.EpochID, strings.Join(adopted, ","))
+	}
+	RspOK(rsp.RetCode)
+}
+
 func NewClusterIdleAddCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "addidle nodename1,nodename2",
@@ -119,18 +144,60 @@ func clusterIdleAddCommandFn(cmd *cobra.Command, args []string) {
 			log.Warning("%v", err)
 		}
 		log.Warning("cluster.prepare.to.add.idle.nodes[%v].to.leader[%v]", args[0], leader)
-		if leader != "" {
-			err := callx.AddIdleNodeRPC(leader, nodes)
-			ErrorOK(err)
-		} else {
+		target := leader
+		if target == "" {
 			log.Warning("cluster.canot.found.leader.forward.to[%v]", self)
-			err := callx.AddIdleNodeRPC(self, nodes)
-			ErrorOK(err)
+			target = self
 		}
+		rsp, err := callx.AddIdleNodeRPC(target, nodes)
+		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("cluster.add.idle.nodes.to.leader[%v].done", leader)
 	}
 }
 
+func NewClusterWitnessAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "addwitness nodename1,nodename2",
+		Short: "add witness peers(vote without mysql) to leader(if there is no leader, add to local)",
+		Run:   clusterWitnessAddCommandFn,
+	}
+	cmd.Flags().BoolVar(&forceAdd, "force", false, "--force, add the nodes even if the majority would sit in one zone")
+
+	return cmd
+}
+
+func clusterWitnessAddCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("node.name.is.nil"))
+	}
+
+	// send add witness node rpc to leader
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		nodes := strings.Split(strings.Trim(args[0], ","), ",")
+
+		leader, err := callx.GetClusterLeader(self)
+		if err != nil {
+			log.Warning("%v", err)
+		}
+		log.Warning("cluster.prepare.to.add.witness.nodes[%v].to.leader[%v]", args[0], leader)
+		target := leader
+		if target == "" {
+			target = self
+		}
+		if leader == "" {
+			log.Warning("cluster.canot.found.leader.forward.to[%v]", self)
+		}
+		rsp, err := callx.AddWitnessNodeRPC(target, nodes, forceAdd)
+		ErrorOK(err)
+		membershipChangeDone(rsp)
+		log.Warning("cluster.add.witness.nodes.to.leader[%v].done", leader)
+	}
+}
+
 func NewClusterRemoveCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "remove nodename1,nodename2",
@@ -157,14 +224,14 @@ func clusterRemoveCommandFn(cmd *cobra.Command, args []string) {
 			log.Warning("%v", err)
 		}
 		log.Warning("cluster.prepare.to.remove.nodes[%v].from.leader[%v]", args[0], leader)
-		if leader != "" {
-			err := callx.RemoveNodeRPC(leader, nodes)
-			ErrorOK(err)
-		} else {
+		target := leader
+		if target == "" {
 			log.Warning("cluster.remove.canot.found.leader.forward.to[%v]", self)
-			err := callx.RemoveNodeRPC(self, nodes)
-			ErrorOK(err)
+			target = self
 		}
+		rsp, err := callx.RemoveNodeRPC(target, nodes)
+		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("cluster.remove.nodes.from.leader[%v].done", leader)
 	}
 }
@@ -195,14 +262,14 @@ func clusterIdleRemoveCommandFn(cmd *cobra.Command, args []string) {
 			log.Warning("%v", err)
 		}
 		log.Warning("cluster.prepare.to.remove.idle.nodes[%v].from.leader[%v]", args[0], leader)
-		if leader != "" {
-			err := callx.RemoveIdleNodeRPC(leader, nodes)
-			ErrorOK(err)
-		} else {
+		target := leader
+		if target == "" {
 			log.Warning("cluster.remove.canot.found.leader.forward.to[%v]", self)
-			err := callx.RemoveIdleNodeRPC(self, nodes)
-			ErrorOK(err)
+			target = self
 		}
+		rsp, err := callx.RemoveIdleNodeRPC(target, nodes)
+		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("cluster.remove.idle.nodes.from.leader[%v].done", leader)
 	}
 }
@@ -225,6 +292,7 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 
 	nodes, err := callx.GetNodes(conf.Server.Endpoint)
 	ErrorOK(err)
+	lags := clusterLags(conf.Server.Endpoint)
 
 	for _, node := range nodes {
 		raft := "UNKNOW"
@@ -234,16 +302,31 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 		mysqlInfo := "UNKNOW"
 		slaveInfo := "UNKNOW"
 		myLeader := "UNKNOW"
+		lagInfo := "UNKNOW"
+		witness := false
 
 		// raft
 		{
 			if rsp, err := callx.GetNodesRPC(node); err == nil {
-				raft = fmt.Sprintf("[ViewID:%v EpochID:%v]@%v",
-					rsp.ViewID, rsp.EpochID, rsp.State)
+				raft = formatRaft(rsp)
 				myLeader = rsp.GetLeader()
+				witness = (rsp.State == witnessState)
 			}
 		}
 
+		// the witness runs no mysql
+		if witness {
+			rows = append(rows, []string{node, raft, witnessInfo, witnessInfo, witnessInfo, witnessInfo, witnessInfo, witnessInfo, myLeader})
+			continue
+		}
+
+		// lag
+		if node == myLeader {
+			lagInfo = leaderLagInfo
+		} else if lag, ok := lags[node]; ok {
+			lagInfo = formatLag(lag)
+		}
+
 		// mysqld
 		{
 			if rsp, err := callx.GetMysqldStatusRPC(node); err == nil {
@@ -277,6 +360,7 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 			strings.TrimSpace(backupInfo),
 			strings.TrimSpace(mysqlInfo),
 			strings.TrimSpace(slaveInfo),
+			lagInfo,
 			myLeader,
 		}
 		rows = append(rows, row)
@@ -290,12 +374,214 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 		"Backup",
 		"Mysql",
 		"IO/SQL_RUNNING",
+		"Lag",
 		"MyLeader",
 	}
 
 	callx.PrintQueryOutput(columns, rows)
 }
 
+// clusterLags returns the replication lag of the peers tracked by the leader, it's empty if there is no leader.
+func clusterLags(self string) map[string]model.RaftPeerLag {
+	lags := make(map[string]model.RaftPeerLag)
+	leader, err := callx.GetClusterLeader(self)
+	if err != nil || leader == "" {
+		log.Warning("cluster.get.leader.error[%v].skip.lags", err)
+		return lags
+	}
+	rsp, err := callx.GetRaftStatusRPC(leader)
+	if err != nil {
+		log.Warning("cluster.get.lags.from.leader[%v].error[%v]", leader, err)
+		return lags
+	}
+	for _, lag := range rsp.Lags {
+		lags[lag.Peer] = lag
+	}
+	return lags
+}
+
+// formatRaft formats the raft as '[ViewID:1 EpochID:0]@FOLLOWER',
+// the node with the cluster ID has a '\n[CLUSTER-ID:9b2c0a8e-...]' line, the nodes of one cluster must have the same one,
+// the node in maintenance has a '\n[MAINTENANCE until:2006-01-02 15:04:05]' line,
+// the node with the failover frozen has a '\n[FROZEN 3.leader.changes.in.600000ms]' line,
+// the node with the cluster freeze has a '\n[CLUSTER-FROZEN by:192.168.0.2:8801 until:2006-01-02 15:04:05]' line.
+func formatRaft(rsp *model.NodeRPCResponse) string {
+	raft := fmt.Sprintf("[ViewID:%v EpochID:%v]@%v", rsp.ViewID, rsp.EpochID, rsp.State)
+	if rsp.ClusterID != "" {
+		raft = fmt.Sprintf("%v\n[CLUSTER-ID:%v]", raft, rsp.ClusterID)
+	}
+	if m := rsp.Maintenance; m != nil {
+		until := time.Unix(0, m.Until*int64(time.Millisecond)).Format("2006-01-02 15:04:05")
+		raft = fmt.Sprintf("%v\n[MAINTENANCE until:%v]", raft, until)
+	}
+	if f := rsp.Freeze; f != nil {
+		raft = fmt.Sprintf("%v\n[FROZEN %v]", raft, f.Reason)
+	}
+	if f := rsp.ClusterFreeze; f != nil {
+		until := "NEVER"
+		if f.Until > 0 {
+			until = time.Unix(0, f.Until*int64(time.Millisecond)).Format("2006-01-02 15:04:05")
+		}
+		raft = fmt.Sprintf("%v\n[CLUSTER-FROZEN by:%v until:%v]", raft, f.By, until)
+	}
+	return raft
+}
+
+// formatLag formats the lag as '[trx:3 sec:1]\nack:120ms.ago misses:0', the unknown values are NULL.
+func formatLag(lag model.RaftPeerLag) string {
+	trx := "NULL"
+	if lag.LagTransactions >= 0 {
+		trx = fmt.Sprintf("%d", lag.LagTransactions)
+	}
+	sec := "NULL"
+	if lag.LagSeconds >= 0 {
+		sec = fmt.Sprintf("%d", lag.LagSeconds)
+	}
+	ack := "NEVER"
+	if lag.LastAckAt > 0 {
+		ack = fmt.Sprintf("%vms.ago", time.Now().UnixNano()/1e6-lag.LastAckAt)
+	}
+	return fmt.Sprintf("[trx:%v sec:%v]\nack:%v misses:%v", trx, sec, ack, lag.AckMisses)
+}
+
+var (
+	clusterFreezeUntil  string
+	clusterFreezeReason string
+)
+
+func NewClusterFreezeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "freeze --until=<duration|time> --reason=<reason>",
+		Short: "stop the automatic failover of all the nodes until the time, the manual switchover still works",
+		Run:   clusterFreezeCommandFn,
+	}
+	cmd.Flags().StringVar(&clusterFreezeUntil, "until", "", "--until=2h or --until='2006-01-02 15:04:05', empty means until 'cluster unfreeze'")
+	cmd.Flags().StringVar(&clusterFreezeReason, "reason", "", "--reason=<reason>")
+
+	return cmd
+}
+
+// parseFreezeUntil parses the until as a duration from now or a local time, returns the unix ms, 0 if it's empty.
+func parseFreezeUntil(until string) (int64, error) {
+	if until == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(until); err == nil {
+		if d <= 0 {
+			return 0, fmt.Errorf("cluster.freeze.until[%v].must.be.positive", until)
+		}
+		return time.Now().Add(d).UnixNano() / int64(time.Millisecond), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", until, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("cluster.freeze.until[%v].must.be.duration.or.time[2006-01-02 15:04:05]", until)
+	}
+	if !t.After(time.Now()) {
+		return 0, fmt.Errorf("cluster.freeze.until[%v].is.in.the.past", until)
+	}
+	return t.UnixNano() / int64(time.Millisecond), nil
+}
+
+func clusterFreezeCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+	until, err := parseFreezeUntil(clusterFreezeUntil)
+	ErrorOK(err)
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	self := conf.Server.Endpoint
+	leader, err := callx.GetClusterLeader(self)
+	ErrorOK(err)
+	if leader == "" {
+		ErrorOK(fmt.Errorf("cluster.freeze.no.leader"))
+	}
+
+	log.Warning("[%v].prepare.to.freeze.cluster.until[%v].reason[%v]", leader, clusterFreezeUntil, clusterFreezeReason)
+	rsp, err := callx.RaftFreezeRPC(leader, self, until, clusterFreezeReason)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	log.Warning("[%v].freeze.cluster.done[%+v]", leader, *rsp.ClusterFreeze)
+}
+
+func NewClusterUnfreezeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unfreeze",
+		Short: "clear the failover freeze and the cluster freeze on all the nodes, the failover limit counts from scratch",
+		Run:   clusterUnfreezeCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterUnfreezeCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	nodes, err := callx.GetNodes(conf.Server.Endpoint)
+	ErrorOK(err)
+
+	failed := []string{}
+	for _, node := range nodes {
+		log.Warning("[%v].prepare.to.unfreeze.failover", node)
+		rsp, err := callx.RaftUnfreezeRPC(node)
+		if err == nil && rsp.RetCode != model.OK {
+			err = fmt.Errorf("%v", rsp.RetCode)
+		}
+		if err != nil {
+			log.Error("[%v].unfreeze.failover.error[%v]", node, err)
+			failed = append(failed, node)
+			continue
+		}
+		log.Warning("[%v].unfreeze.failover.done", node)
+	}
+	if len(failed) > 0 {
+		ErrorOK(fmt.Errorf("cluster.unfreeze.failed.on.nodes[%v]", strings.Join(failed, ",")))
+	}
+}
+
+func NewClusterDRPromoteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dr-promote",
+		Short: "detach the standby cluster from the primary and make its leader writable",
+		Run:   clusterDRPromoteCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterDRPromoteCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	self := conf.Server.Endpoint
+	leader, err := callx.GetClusterLeader(self)
+	ErrorOK(err)
+	if leader == "" {
+		ErrorOK(fmt.Errorf("cluster.leader.not.found"))
+	}
+
+	log.Warning("[%v].prepare.to.dr-promote", leader)
+	rsp, err := callx.RaftDRPromoteRPC(leader, self)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	log.Warning("[%v].dr-promote.done", leader)
+
+	columns := []string{
+		"Leader",
+		"Last_Applied_Primary_GTID",
+	}
+	rows := [][]string{{leader, strings.TrimSpace(rsp.GTID)}}
+	callx.PrintQueryOutput(columns, rows)
+}
+
 func NewClusterStatusJsonCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "json",
@@ -308,14 +594,19 @@ func NewClusterStatusJsonCommand() *cobra.Command {
 
 func clusterStatusJsonCommandFn(cmd *cobra.Command, args []string) {
 	type Status struct {
-		Id          string `json:"id"`
-		Raft        string `json:"raft"`
-		MysqldInfo  string `json:"mysqld-info"`
-		MonitorInfo string `json:"monitor-info"`
-		BackupInfo  string `json:"backup-info"`
-		MysqlInfo   string `json:"mysql-info"`
-		SlaveInfo   string `json:"slave-info"`
-		MyLeader    string `json:"myleader"`
+		Id            string                 `json:"id"`
+		Raft          string                 `json:"raft"`
+		ClusterID     string                 `json:"cluster-id,omitempty"`
+		MysqldInfo    string                 `json:"mysqld-info"`
+		MonitorInfo   string                 `json:"monitor-info"`
+		BackupInfo    string                 `json:"backup-info"`
+		MysqlInfo     string                 `json:"mysql-info"`
+		SlaveInfo     string                 `json:"slave-info"`
+		Lag           *model.RaftPeerLag     `json:"lag,omitempty"`
+		Maintenance   *model.RaftMaintenance `json:"maintenance,omitempty"`
+		Freeze        *model.RaftFreeze      `json:"freeze,omitempty"`
+		ClusterFreeze *model.RaftFreeze      `json:"cluster-freeze,omitempty"`
+		MyLeader      string                 `json:"myleader"`
 	}
 
 	type StatusList struct {
@@ -331,17 +622,33 @@ func clusterStatusJsonCommandFn(cmd *cobra.Command, args []string) {
 
 	nodes, err := callx.GetNodes(conf.Server.Endpoint)
 	ErrorOK(err)
+	lags := clusterLags(conf.Server.Endpoint)
 
 	list := make([]*Status, 0, len(nodes))
 	for _, node := range nodes {
 		status := &Status{}
 		status.Id = node
+		if lag, ok := lags[node]; ok {
+			status.Lag = &lag
+		}
 		// raft
 		{
 			if rsp, err := callx.GetNodesRPC(node); err == nil {
-				status.Raft = fmt.Sprintf("[ViewID:%v EpochID:%v]@%v",
-					rsp.ViewID, rsp.EpochID, rsp.State)
+				status.Raft = formatRaft(rsp)
+				status.ClusterID = rsp.ClusterID
+				status.Maintenance = rsp.Maintenance
+				status.Freeze = rsp.Freeze
+				status.ClusterFreeze = rsp.ClusterFreeze
 				status.MyLeader = rsp.GetLeader()
+				if rsp.State == witnessState {
+					status.MysqldInfo = witnessInfo
+					status.MonitorInfo = witnessInfo
+					status.BackupInfo = witnessInfo
+					status.MysqlInfo = witnessInfo
+					status.SlaveInfo = witnessInfo
+					list = append(list, status)
+					continue
+				}
 			}
 		}
 
@@ -10,6 +10,7 @@ package cmd
 
 import (
 	"bytes"
+	"cli/callx"
 	"config"
 	"fmt"
 	"io/ioutil"
@@ -65,6 +66,10 @@ func GetConfig() (*config.Config, error) {
 		return nil, err
 	}
 
+	// the nodes with the rpc tls or rpc-secret reject the plain calls
+	if err := callx.SetRPCConfig(conf); err != nil {
+		return nil, err
+	}
 	return conf, nil
 }
 
@@ -3,6 +3,12 @@ package cmd
 import (
 	"config"
 	"os"
+	"path/filepath"
+)
+
+var (
+	testConfigFile  = filepath.Join(os.TempDir(), "test.cli.config.json")
+	testMetaDatadir = filepath.Join(os.TempDir(), "test.cli.raft")
 )
 
 var defaultConfig = config.Config{
@@ -11,7 +17,7 @@ var defaultConfig = config.Config{
 	},
 
 	Raft: &config.RaftConfig{
-		MetaDatadir:        "",
+		MetaDatadir:        testMetaDatadir,
 		HeartbeatTimeout:   1000,
 		ElectionTimeout:    3000,
 		LeaderStartCommand: "",
@@ -52,25 +58,44 @@ var defaultConfig = config.Config{
 	},
 }
 
+// testConfigPathFile returns the config.path beside the test binary, it's in the temp dir of go test,
+// GetConfig falls back to it as there is no config.path in the current dir.
+func testConfigPathFile() (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configPathFile), nil
+}
+
 func createConfig() error {
-	path := "/tmp/test.cli.config.json"
-	err := config.WriteConfig(path, &defaultConfig)
+	err := config.WriteConfig(testConfigFile, &defaultConfig)
 	if err != nil {
 		return err
 	}
 
+	path, err := testConfigPathFile()
+	if err != nil {
+		return err
+	}
 	flag := os.O_RDWR | os.O_TRUNC | os.O_CREATE
-	f, err := os.OpenFile("./config.path", flag, 0644)
+	f, err := os.OpenFile(path, flag, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(path)
+	_, err = f.WriteString(testConfigFile)
 	return err
 }
 
 func removeConfig() error {
-	os.Remove("/tmp/test.cli.config.json")
+	os.Remove(testConfigFile)
+	if path, err := testConfigPathFile(); err == nil {
+		os.Remove(path)
+	}
+	// the raft files, the history is written when the raft stops
+	os.Remove(filepath.Join(testMetaDatadir, "raft.history.json"))
+	os.RemoveAll(testMetaDatadir)
 	return nil
 }
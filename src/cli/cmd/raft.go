@@ -12,7 +12,10 @@ import (
 	"cli/callx"
 	"encoding/json"
 	"fmt"
+	"model"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -26,10 +29,14 @@ func NewRaftCommand() *cobra.Command {
 	cmd.AddCommand(NewRaftEnableCommand())
 	cmd.AddCommand(NewRaftDisableCommand())
 	cmd.AddCommand(NewRaftTryToLeaderCommand())
+	cmd.AddCommand(NewRaftTransferCommand())
+	cmd.AddCommand(NewRaftMaintenanceCommand())
+	cmd.AddCommand(NewRaftSetCommand())
 	cmd.AddCommand(NewRaftAddCommand())
 	cmd.AddCommand(NewRaftRemoveCommand())
 	cmd.AddCommand(NewRaftNodesCommand())
 	cmd.AddCommand(NewRaftStatusCommand())
+	cmd.AddCommand(NewRaftHistoryCommand())
 	cmd.AddCommand(NewRaftEnablePurgeBinlogCommand())
 	cmd.AddCommand(NewRaftDisablePurgeBinlogCommand())
 	cmd.AddCommand(NewRaftEnableCheckSemiSyncCommand())
@@ -121,6 +128,187 @@ func raftTryToLeaderCommandFn(cmd *cobra.Command, args []string) {
 	}
 }
 
+var (
+	transferTo string
+)
+
+func NewRaftTransferCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "transfer --to=<node>",
+		Short: "transfer the leadership to the node without losing writes",
+		Run:   raftTransferCommandFn,
+	}
+	cmd.Flags().StringVar(&transferTo, "to", "", "--to=<node>")
+
+	return cmd
+}
+
+func raftTransferCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+	if transferTo == "" {
+		ErrorOK(fmt.Errorf("transfer.to.node.is.nil"))
+	}
+
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		leader, err := callx.GetClusterLeader(self)
+		ErrorOK(err)
+		if leader == "" {
+			ErrorOK(fmt.Errorf("cluster.leader.not.found"))
+		}
+		log.Warning("prepare.to.transfer.leader.from[%v].to[%v]", leader, transferTo)
+		rsp, err := callx.TransferLeaderRPC(leader, transferTo)
+		ErrorOK(err)
+		log.Warning("transfer.leader.to[%v].ret[%v].leader.now[%v].catchup[%vms].election[%vms].total[%vms]", transferTo, rsp.RetCode, rsp.Leader, rsp.CatchUpTime, rsp.ElectionTime, rsp.TotalTime)
+		RspOK(rsp.RetCode)
+		log.Warning("transfer.leader.to[%v].done", transferTo)
+	}
+}
+
+func NewRaftSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <heartbeat-timeout|election-timeout|admit-defeat-hearbeat-count|purge-binlog-interval>=<value>...",
+		Short: "change the raft timings of all the nodes at runtime(ms), the leader carries them in the heartbeats",
+		Run:   raftSetCommandFn,
+	}
+
+	return cmd
+}
+
+// parseRaftTimings parses the 'name=value' args to the timings, the unset fields are zero.
+func parseRaftTimings(args []string) (*model.RaftTimings, error) {
+	timings := &model.RaftTimings{}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("raft.set.arg[%v].must.be.name=value", arg)
+		}
+		value, err := strconv.Atoi(kv[1])
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("raft.set.arg[%v].value.must.be.positive.integer", arg)
+		}
+		switch kv[0] {
+		case "heartbeat-timeout":
+			timings.HeartbeatTimeout = value
+		case "election-timeout":
+			timings.ElectionTimeout = value
+		case "admit-defeat-hearbeat-count":
+			timings.AdmitDefeatHtCnt = value
+		case "purge-binlog-interval":
+			timings.PurgeBinlogInterval = value
+		default:
+			return nil, fmt.Errorf("raft.set.arg[%v].name.is.unknown", arg)
+		}
+	}
+	return timings, nil
+}
+
+func raftSetCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		ErrorOK(fmt.Errorf("args.can.not.be.null"))
+	}
+	timings, err := parseRaftTimings(args)
+	ErrorOK(err)
+
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		leader, err := callx.GetClusterLeader(self)
+		ErrorOK(err)
+		if leader == "" {
+			ErrorOK(fmt.Errorf("cluster.leader.not.found"))
+		}
+		log.Warning("[%v].prepare.to.set.timings[%v]", leader, strings.Join(args, " "))
+		rsp, err := callx.RaftSetTimingsRPC(leader, self, timings)
+		ErrorOK(err)
+		RspOK(rsp.RetCode)
+		log.Warning("[%v].set.timings.done[%+v]", leader, rsp.Timings)
+	}
+}
+
+var (
+	maintenanceDuration time.Duration
+	maintenanceReason   string
+)
+
+func NewRaftMaintenanceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance <subcommand>",
+		Short: "put the node in or out of maintenance",
+	}
+
+	cmd.AddCommand(NewRaftMaintenanceOnCommand())
+	cmd.AddCommand(NewRaftMaintenanceOffCommand())
+	return cmd
+}
+
+func NewRaftMaintenanceOnCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "on --duration=<duration> --reason=<reason>",
+		Short: "hand over the leadership and never be candidate until the duration expires",
+		Run:   raftMaintenanceOnCommandFn,
+	}
+	cmd.Flags().DurationVar(&maintenanceDuration, "duration", 0, "--duration=2h")
+	cmd.Flags().StringVar(&maintenanceReason, "reason", "", "--reason=<reason>")
+
+	return cmd
+}
+
+func raftMaintenanceOnCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+	if maintenanceDuration <= 0 {
+		ErrorOK(fmt.Errorf("maintenance.duration.must.be.positive"))
+	}
+
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		log.Warning("[%v].prepare.to.enter.maintenance.duration[%v].reason[%v]", self, maintenanceDuration, maintenanceReason)
+		rsp, err := callx.EnterMaintenanceRPC(self, maintenanceDuration, maintenanceReason)
+		ErrorOK(err)
+		log.Warning("[%v].enter.maintenance.ret[%v].leader.now[%v]", self, rsp.RetCode, rsp.Leader)
+		RspOK(rsp.RetCode)
+		if rsp.Maintenance != nil {
+			log.Warning("[%v].enter.maintenance.done.until[%v]", self, time.Unix(0, rsp.Maintenance.Until*int64(time.Millisecond)).Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+func NewRaftMaintenanceOffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "off",
+		Short: "take the node out of maintenance",
+		Run:   raftMaintenanceOffCommandFn,
+	}
+
+	return cmd
+}
+
+func raftMaintenanceOffCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		log.Warning("[%v].prepare.to.exit.maintenance", self)
+		rsp, err := callx.ExitMaintenanceRPC(self)
+		ErrorOK(err)
+		RspOK(rsp.RetCode)
+		log.Warning("[%v].exit.maintenance.done", self)
+	}
+}
+
 func NewRaftAddCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add nodename1,nodename2",
@@ -143,8 +331,9 @@ func raftAddCommandFn(cmd *cobra.Command, args []string) {
 		self := conf.Server.Endpoint
 		log.Warning("[%v].prepare.to.add.nodes[%v]", self, args[0])
 		nodes := strings.Split(strings.Trim(args[0], ","), ",")
-		err = callx.AddNodeRPC(self, nodes)
+		rsp, err := callx.AddNodeRPC(self, nodes, false)
 		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("[%v].add.nodes.done", self)
 	}
 }
@@ -171,8 +360,9 @@ func raftRemoveCommandFn(cmd *cobra.Command, args []string) {
 		self := conf.Server.Endpoint
 		log.Warning("[%v].prepare.to.remove.nodes[%v]", self, args[0])
 		nodes := strings.Split(strings.Trim(args[0], ","), ",")
-		err = callx.RemoveNodeRPC(self, nodes)
+		rsp, err := callx.RemoveNodeRPC(self, nodes)
 		ErrorOK(err)
+		membershipChangeDone(rsp)
 		log.Warning("[%v].remove.nodes.done", self)
 	}
 }
@@ -223,10 +413,33 @@ func NewRaftStatusCommand() *cobra.Command {
 }
 
 func raftStatusCommandFn(cmd *cobra.Command, args []string) {
+	type Lease struct {
+		Timeout   int64 `json:"timeout-ms"`
+		RenewAt   int64 `json:"renew-at-ms"`
+		Remaining int64 `json:"remaining-ms"`
+	}
+	type Hook struct {
+		Stage    string `json:"stage"`
+		Name     string `json:"name"`
+		StartAt  int64  `json:"start-at-ms"`
+		Cost     int64  `json:"cost-ms"`
+		Attempts int    `json:"attempts"`
+		Output   string `json:"output"`
+		RetCode  string `json:"retcode"`
+	}
 	type Status struct {
-		State  string   `json:"state"`
-		Leader string   `json:"leader"`
-		Nodes  []string `json:"nodes"`
+		State         string                     `json:"state"`
+		Leader        string                     `json:"leader"`
+		Nodes         []string                   `json:"nodes"`
+		Lease         Lease                      `json:"lease"`
+		Hooks         []Hook                     `json:"hooks"`
+		Maintenance   *model.RaftMaintenance     `json:"maintenance,omitempty"`
+		Freeze        *model.RaftFreeze          `json:"freeze,omitempty"`
+		ClusterFreeze *model.RaftFreeze          `json:"cluster-freeze,omitempty"`
+		Learner       *model.RaftLearnerProgress `json:"learner,omitempty"`
+		Timings       model.RaftTimings          `json:"timings"`
+		Standby       *model.RaftStandby         `json:"standby,omitempty"`
+		Changes       int                        `json:"leader-changes"`
 	}
 	status := &Status{}
 
@@ -245,10 +458,89 @@ func raftStatusCommandFn(cmd *cobra.Command, args []string) {
 	ErrorOK(err)
 	status.Leader = rsp.GetLeader()
 
+	raftRsp, err := callx.GetRaftStatusRPC(conf.Server.Endpoint)
+	ErrorOK(err)
+	status.Lease = Lease(raftRsp.Lease)
+	status.Maintenance = raftRsp.Maintenance
+	status.Freeze = raftRsp.Freeze
+	status.ClusterFreeze = raftRsp.ClusterFreeze
+	status.Learner = raftRsp.Learner
+	status.Timings = raftRsp.Timings
+	status.Standby = raftRsp.Standby
+	status.Changes = raftRsp.LeaderChanges
+	status.Hooks = []Hook{}
+	for _, hook := range raftRsp.Hooks {
+		status.Hooks = append(status.Hooks, Hook(hook))
+	}
+
 	statusB, _ := json.Marshal(status)
 	fmt.Printf("%s", string(statusB))
 }
 
+var (
+	historyNode  string
+	historyLimit int
+)
+
+// raft history of the state transitions
+func NewRaftHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [--node=<node>] [--limit=<n>]",
+		Short: "show the raft events(state changes, votes, degrade reasons, epoch changes) of the node",
+		Run:   raftHistoryCommandFn,
+	}
+	cmd.Flags().StringVar(&historyNode, "node", "", "--node=<node>, default is this node")
+	cmd.Flags().IntVar(&historyLimit, "limit", 0, "--limit=<n>, show the latest n events, 0 means all")
+
+	return cmd
+}
+
+func raftHistoryCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	node := historyNode
+	if node == "" {
+		conf, err := GetConfig()
+		ErrorOK(err)
+		node = conf.Server.Endpoint
+	}
+
+	rsp, err := callx.GetRaftHistoryRPC(node, historyLimit)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+
+	var rows [][]string
+	for _, event := range rsp.Events {
+		row := []string{
+			time.Unix(0, event.Time*int64(time.Millisecond)).Format("2006-01-02 15:04:05.000"),
+			event.Type,
+			event.State,
+			fmt.Sprintf("%v", event.ViewID),
+			fmt.Sprintf("%v", event.EpochID),
+			event.From,
+			event.To,
+			event.Peer,
+			event.Reason,
+		}
+		rows = append(rows, row)
+	}
+	columns := []string{
+		"Time",
+		"Type",
+		"State",
+		"ViewID",
+		"EpochID",
+		"From",
+		"To",
+		"Peer",
+		"Reason",
+	}
+
+	callx.PrintQueryOutput(columns, rows)
+}
+
 func NewRaftEnablePurgeBinlogCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "enablepurgebinlog",
@@ -168,4 +168,27 @@ func TestCLIRaftCommand(t *testing.T) {
 			assert.Nil(t, err)
 		}
 	}
+
+	// 3. test transfer leader
+	{
+		var target string
+
+		server.MockWaitLeaderEggs(servers, 1)
+		for _, server := range servers {
+			if server.GetState() == raft.FOLLOWER {
+				target = server.Address()
+				break
+			}
+		}
+
+		cmd := NewRaftCommand()
+		_, err := executeCommand(cmd, "transfer", "--to", target)
+		assert.Nil(t, err)
+
+		for _, server := range servers {
+			if server.Address() == target {
+				assert.Equal(t, raft.LEADER, server.GetState())
+			}
+		}
+	}
 }
@@ -26,6 +26,9 @@ type ServerConfig struct {
 	EnableAPIs bool `json:"enable-apis"`
 	// HTTP APIs address.
 	PeerAddress string `json:"peer-address,omitempty"`
+	// the cluster shared secret, if set, every rpc between the nodes and from xenoncli is signed with it,
+	// all the nodes must have the same one.
+	RPCSecret string `json:"rpc-secret,omitempty"`
 }
 
 func DefaultServerConfig() *ServerConfig {
@@ -82,19 +85,122 @@ type RaftConfig struct {
 
 	// candicate wait timeout(ms) for 2 nodes.
 	CandidateWaitFor2Nodes int `json:"candidate-wait-for-2nodes"`
+
+	// leader transfer timeout(ms), for both the target catching up and winning the election.
+	TransferLeaderTimeout int `json:"transfer-leader-timeout"`
+
+	// leader lease(ms), the leader fences itself if it can't get the majority heartbeat acks in the lease.
+	// it must be less than election-timeout, otherwise election-timeout - heartbeat-timeout is used.
+	// 0 disables the lease.
+	LeaderLeaseTimeout int `json:"leader-lease-timeout"`
+
+	// election priority, the higher priority node wins the election when the GTIDs are equal.
+	// 0 means the node votes but never becomes CANDIDATE.
+	Priority int `json:"priority"`
+
+	// zone(datacenter/rack) label of this node, empty means unknown.
+	Zone string `json:"zone"`
+
+	// if true, the leader requires at least one semi-sync ack from the follower in the other zone.
+	SemiSyncCrossZone bool `json:"semi-sync-cross-zone"`
+
+	// membership change timeout(ms), the leader waits for the majority acks of the old and new peers in it.
+	MembershipChangeTimeout int `json:"membership-change-timeout"`
+
+	// failover hooks, the key is the stage:
+	// pre-promote, post-promote, pre-demote, post-demote, on-invalid, on-mysql-down and on-failover-frozen.
+	// the hooks of one stage run in order, leader-start-command runs as the last post-promote hook
+	// and leader-stop-command runs as the last post-demote hook.
+	Hooks map[string][]HookConfig `json:"hooks,omitempty"`
+
+	// the max events kept in the raft history, the history is persisted in the meta-datadir.
+	HistorySize int `json:"history-size"`
+
+	// failover rate limit, at most failover-limit leader changes in failover-window(ms),
+	// if it's hit the failover is frozen until 'cluster unfreeze'. 0 disables the limit.
+	FailoverLimit  int `json:"failover-limit"`
+	FailoverWindow int `json:"failover-window"`
+
+	// the min time(ms) a new leader keeps the leadership, no election in it. 0 disables it.
+	MinLeaderTenure int `json:"min-leader-tenure"`
+
+	// the new leader fences the old leader before serving the writes, it sets the old leader MySQL super_read_only,
+	// if it fails, runs the fence-command with bash -c, such as killing the remote mysqld or blocking its port.
+	// the old leader MySQL address is in the env XENON_FENCE_HOST and XENON_FENCE_PORT.
+	FenceCommand string `json:"fence-command"`
+
+	// fencing timeout(ms), the fencing retries until the old leader is fenced or it's timeout.
+	FenceTimeout int `json:"fence-timeout"`
+
+	// what to do if the old leader is still not fenced after fence-timeout:
+	// promote(default): continue the promotion
+	// abort: the new leader steps down
+	FenceTimeoutPolicy string `json:"fence-timeout-policy"`
+
+	// the LEARNER promotes itself to FOLLOWER when it's at most learner-promote-lag transactions behind the leader
+	// for learner-promote-after(ms) continuously. 0 learner-promote-after disables it, 'raft enable' is needed.
+	LearnerPromoteLag   int `json:"learner-promote-lag"`
+	LearnerPromoteAfter int `json:"learner-promote-after"`
+
+	// the candidate gets the vote if its GTID set(executed and retrieved) is a superset of the voter one,
+	// what to do if neither set contains the other:
+	// more(default): the vote is rejected if the voter has more transactions the candidate misses than the reverse
+	// grant: the vote is granted, the quorum decides
+	// reject: the vote is rejected, no transaction of the voter is lost, but the election may stall
+	GTIDTieBreak string `json:"gtid-tie-break"`
+
+	// the xenon endpoints of the primary cluster, it makes this cluster the disaster recovery standby:
+	// the leader replicates from the primary leader and stays read-only until 'cluster dr-promote'.
+	StandbyOf []string `json:"standby-of,omitempty"`
+
+	// the xenon endpoints of the standby nodes, the primary leader gives its replication info only to them,
+	// any caller is answered if rpc-secret is set, the calls are signed by the cluster secret.
+	StandbyNodes []string `json:"standby-nodes,omitempty"`
+}
+
+// HookConfig is one failover hook of a stage.
+type HookConfig struct {
+	// the name shows in the log and the raft status
+	Name string `json:"name"`
+
+	// the shell command, executes with bash -c
+	Command string `json:"command"`
+
+	// timeout(ms) of one attempt, 0 means 10000
+	Timeout int `json:"timeout"`
+
+	// the times to retry when the attempt fails
+	Retries int `json:"retries"`
+
+	// the interval(ms) between the retries
+	RetryInterval int `json:"retry-interval"`
+
+	// what to do if the hook still fails after the retries:
+	// continue(default): run the following hooks of the stage
+	// abort: skip the following hooks, and the pre-promote abort makes the leader step down
+	OnFailure string `json:"on-failure"`
 }
 
 func DefaultRaftConfig() *RaftConfig {
 	return &RaftConfig{
-		MetaDatadir:            ".",
-		HeartbeatTimeout:       1000,
-		AdmitDefeatHtCnt:       10,
-		ElectionTimeout:        3000,
-		PurgeBinlogInterval:    1000 * 60 * 5,
-		LeaderStartCommand:     "nop",
-		LeaderStopCommand:      "nop",
-		RequestTimeout:         1000,
-		CandidateWaitFor2Nodes: 1000 * 60,
+		MetaDatadir:             ".",
+		HeartbeatTimeout:        1000,
+		AdmitDefeatHtCnt:        10,
+		ElectionTimeout:         3000,
+		PurgeBinlogInterval:     1000 * 60 * 5,
+		LeaderStartCommand:      "nop",
+		LeaderStopCommand:       "nop",
+		RequestTimeout:          1000,
+		CandidateWaitFor2Nodes:  1000 * 60,
+		TransferLeaderTimeout:   1000 * 30,
+		LeaderLeaseTimeout:      2000,
+		Priority:                1,
+		MembershipChangeTimeout: 1000 * 30,
+		HistorySize:             1000,
+		FailoverWindow:          1000 * 60 * 10,
+		FenceTimeout:            1000 * 10,
+		FenceTimeoutPolicy:      "promote",
+		GTIDTieBreak:            "more",
 	}
 }
 
@@ -284,6 +390,22 @@ func (c *BackupConfig) UnmarshalJSON(b []byte) error {
 
 type RPCConfig struct {
 	RequestTimeout int `json:"request-timeout"`
+
+	// the mutual TLS of the rpc between the nodes and from xenoncli, disabled if tls-cert is empty.
+	// the CA verifies the certificates of the peers, the certificate of the node is used both as the
+	// service and the client, the changed files are reloaded at the next connection.
+	TLSCA   string `json:"tls-ca,omitempty"`
+	TLSCert string `json:"tls-cert,omitempty"`
+	TLSKey  string `json:"tls-key,omitempty"`
+
+	// if true, the certificate of the node must have the SAN matching the host of its endpoint,
+	// and the client certificate must have the SAN matching the host of a member, this node, a standby-of or standby-nodes node.
+	TLSVerifyEndpoint bool `json:"tls-verify-endpoint,omitempty"`
+
+	// the hosts of the clients allowed besides the members, this node, the standby-of and standby-nodes nodes.
+	// if set or tls-verify-endpoint is on, the client certificate must have the SAN(IP or DNS) matching one of them,
+	// otherwise any client signed by tls-ca is accepted, the CA must be dedicated to this cluster.
+	TLSAllowedClients []string `json:"tls-allowed-clients,omitempty"`
 }
 
 func DefaultRPCConfig() *RPCConfig {
@@ -354,6 +476,11 @@ func parseConfig(data []byte) (*Config, error) {
 
 	// raft
 	conf.Raft.RequestTimeout = conf.RPC.RequestTimeout
+	switch conf.Raft.FenceTimeoutPolicy {
+	case "promote", "abort":
+	default:
+		return nil, errors.Errorf("raft.fence-timeout-policy[%v].must.be.promote.or.abort", conf.Raft.FenceTimeoutPolicy)
+	}
 
 	// backup
 	conf.Backup.Admin = conf.Mysql.Admin
@@ -82,6 +82,23 @@ func TestParseConfig(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestParseConfigFenceTimeoutPolicy(t *testing.T) {
+	// abort.
+	{
+		got, err := parseConfig([]byte(`{"raft":{"fence-timeout-policy":"abort"}}`))
+		assert.Nil(t, err)
+		assert.Equal(t, "abort", got.Raft.FenceTimeoutPolicy)
+	}
+
+	// unknown.
+	{
+		_, err := parseConfig([]byte(`{"raft":{"fence-timeout-policy":"kill"}}`))
+		want := "raft.fence-timeout-policy[kill].must.be.promote.or.abort"
+		got := err.Error()
+		assert.Equal(t, want, got)
+	}
+}
+
 func TestWriteConfig(t *testing.T) {
 	path := "/tmp/test.config.json"
 	os.Remove(path)
@@ -26,7 +26,10 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 
 		// raft.
 		rest.Get("/v1/raft/status", v1.RaftStatusHandler(log, xenon)),
+		rest.Get("/v1/raft/history", v1.RaftHistoryHandler(log, xenon)),
+		rest.Get("/v1/raft/lags", v1.RaftLagsHandler(log, xenon)),
 		rest.Post("/v1/raft/trytoleader", v1.RaftTryToLeaderHandler(log, xenon)),
+		rest.Post("/v1/raft/transfer", v1.RaftTransferHandler(log, xenon)),
 		rest.Put("/v1/raft/disablechecksemisync", v1.RaftDisableCheckSemiSyncHandler(log, xenon)),
 		rest.Put("/v1/raft/disable", v1.RaftDisableHandler(log, xenon)),
 
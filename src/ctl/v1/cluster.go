@@ -9,10 +9,12 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
 	"cli/callx"
+	"model"
 	"server"
 	"xbase/xlog"
 
@@ -52,14 +54,14 @@ func clusterAddHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWrite
 
 	log.Warning("api.v1.cluster.prepare.to.add.nodes[%v].to.leader[%v]", p.Address, leader)
 	if leader != "" {
-		if err := callx.AddNodeRPC(leader, nodes); err != nil {
+		if err := membershipChange(callx.AddNodeRPC(leader, nodes, false)); err != nil {
 			log.Error("api.v1.cluster.add[%+v].error:%+v", p, err)
 			rest.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	} else {
 		log.Warning("api.v1.cluster.add.canot.found.leader.forward.to[%v]", self)
-		if err := callx.AddNodeRPC(self, nodes); err != nil {
+		if err := membershipChange(callx.AddNodeRPC(self, nodes, false)); err != nil {
 			log.Error("api.v1.cluster.add[%+v].error:%+v", p, err)
 			rest.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -97,14 +99,14 @@ func clusterRemoveHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWr
 
 	log.Warning("api.v1.cluster.prepare.to.remove.nodes[%v].from.leader[%v]", p.Address, leader)
 	if leader != "" {
-		if err := callx.RemoveNodeRPC(leader, nodes); err != nil {
+		if err := membershipChange(callx.RemoveNodeRPC(leader, nodes)); err != nil {
 			log.Error("api.v1.cluster.remove[%+v].error:%+v", p, err)
 			rest.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	} else {
 		log.Warning("api.v1.cluster.remove.canot.found.leader.forward.to[%v]", self)
-		if err := callx.RemoveNodeRPC(self, nodes); err != nil {
+		if err := membershipChange(callx.RemoveNodeRPC(self, nodes)); err != nil {
 			log.Error("api.v1.cluster.remove[%+v].error:%+v", p, err)
 			rest.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -112,3 +114,14 @@ func clusterRemoveHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWr
 	}
 	log.Warning("api.v1.cluster.remove.nodes.from.leader[%v].done", leader)
 }
+
+// membershipChange returns the error of the membership change rpc.
+func membershipChange(rsp *model.NodeRPCResponse, err error) error {
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return fmt.Errorf("%s.adopted.by[%v]", rsp.RetCode, rsp.GetAdopted())
+	}
+	return nil
+}
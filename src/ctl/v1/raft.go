@@ -10,6 +10,7 @@ package v1
 
 import (
 	"net/http"
+	"strconv"
 
 	"cli/callx"
 	"model"
@@ -55,6 +56,94 @@ func raftStatusHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWrite
 	w.WriteJson(status)
 }
 
+// RaftHistoryHandler impl.
+// GET /v1/raft/history?node=<node>&limit=<n>, the node is this xenon by default.
+func RaftHistoryHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		raftHistoryHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func raftHistoryHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	type History struct {
+		Node   string            `json:"node"`
+		Events []model.RaftEvent `json:"events"`
+	}
+
+	query := r.URL.Query()
+	node := query.Get("node")
+	if node == "" {
+		node = xenon.Address()
+	}
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			rest.Error(w, "api.v1.raft.history.request.limit.is.invalid", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rsp, err := callx.GetRaftHistoryRPC(node, limit)
+	if err != nil {
+		log.Error("api.v1.raft.history.node[%v].error:%+v", node, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	history := &History{
+		Node:   node,
+		Events: rsp.Events,
+	}
+	if history.Events == nil {
+		history.Events = []model.RaftEvent{}
+	}
+	w.WriteJson(history)
+}
+
+// RaftLagsHandler impl.
+// GET /v1/raft/lags, the replication lag of the peers tracked by the cluster leader.
+func RaftLagsHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		raftLagsHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func raftLagsHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	type Lags struct {
+		Leader string              `json:"leader"`
+		Lags   []model.RaftPeerLag `json:"lags"`
+	}
+
+	leader, err := callx.GetClusterLeader(xenon.Address())
+	if err != nil {
+		log.Error("api.v1.raft.lags.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if leader == "" {
+		rest.Error(w, "api.v1.raft.lags.cluster.leader.not.found", http.StatusInternalServerError)
+		return
+	}
+
+	rsp, err := callx.GetRaftStatusRPC(leader)
+	if err != nil {
+		log.Error("api.v1.raft.lags.leader[%v].error:%+v", leader, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lags := &Lags{
+		Leader: leader,
+		Lags:   rsp.Lags,
+	}
+	if lags.Lags == nil {
+		lags.Lags = []model.RaftPeerLag{}
+	}
+	w.WriteJson(lags)
+}
+
 // RaftTryToLeaderHandler impl.
 func RaftTryToLeaderHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
 	f := func(w rest.ResponseWriter, r *rest.Request) {
@@ -78,6 +167,72 @@ func raftTryToLeaderHandler(log *xlog.Log, xenon *server.Server, w rest.Response
 	log.Warning("api.v1.raft.trytoleader.[%v].propose.done", address)
 }
 
+type transferParams struct {
+	To string `json:"to"`
+}
+
+// RaftTransferHandler impl.
+func RaftTransferHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		raftTransferHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func raftTransferHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	type Transfer struct {
+		Leader       string `json:"leader"`
+		CatchUpTime  int64  `json:"catchup-time"`
+		ElectionTime int64  `json:"election-time"`
+		TotalTime    int64  `json:"total-time"`
+		RetCode      string `json:"retcode"`
+	}
+
+	p := transferParams{}
+	if err := r.DecodeJsonPayload(&p); err != nil {
+		log.Error("api.v1.raft.transfer.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if p.To == "" {
+		rest.Error(w, "api.v1.raft.transfer.request.to.is.null", http.StatusInternalServerError)
+		return
+	}
+
+	leader, err := callx.GetClusterLeader(xenon.Address())
+	if err != nil {
+		log.Error("api.v1.raft.transfer.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if leader == "" {
+		rest.Error(w, "api.v1.raft.transfer.cluster.leader.not.found", http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.raft.transfer.prepare.to.transfer.leader.from[%v].to[%v]", leader, p.To)
+	rsp, err := callx.TransferLeaderRPC(leader, p.To)
+	if err != nil {
+		log.Error("api.v1.raft.transfer.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	transfer := &Transfer{
+		Leader:       rsp.Leader,
+		CatchUpTime:  rsp.CatchUpTime,
+		ElectionTime: rsp.ElectionTime,
+		TotalTime:    rsp.TotalTime,
+		RetCode:      rsp.RetCode,
+	}
+	if rsp.RetCode != model.OK {
+		log.Error("api.v1.raft.transfer.error:rsp[%v] != [OK]", rsp.RetCode)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.WriteJson(transfer)
+	log.Warning("api.v1.raft.transfer.to[%v].ret[%v].total[%vms]", p.To, rsp.RetCode, rsp.TotalTime)
+}
+
 // RaftDisableCheckSemiSyncHandler impl.
 func RaftDisableCheckSemiSyncHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
 	f := func(w rest.ResponseWriter, r *rest.Request) {
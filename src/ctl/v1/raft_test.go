@@ -43,7 +43,10 @@ func TestCtlV1Raft(t *testing.T) {
 
 	router, _ := rest.MakeRouter(
 		rest.Get("/v1/raft/status", RaftStatusHandler(log, xenon)),
+		rest.Get("/v1/raft/history", RaftHistoryHandler(log, xenon)),
+		rest.Get("/v1/raft/lags", RaftLagsHandler(log, xenon)),
 		rest.Post("/v1/raft/trytoleader", RaftTryToLeaderHandler(log, xenon)),
+		rest.Post("/v1/raft/transfer", RaftTransferHandler(log, xenon)),
 		rest.Put("/v1/raft/disablechecksemisync", RaftDisableCheckSemiSyncHandler(log, xenon)),
 		rest.Put("/v1/raft/disable", RaftDisableHandler(log, xenon)),
 	)
@@ -69,6 +72,15 @@ func TestCtlV1Raft(t *testing.T) {
 		assert.True(t, strings.Contains(got, `"state":"FOLLOWER"`))
 	}
 
+	// lags 500, no leader.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/raft/lags", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
 	// trytoleader.
 	{
 		req := test.MakeSimpleRequest("POST", "http://localhost/v1/raft/trytoleader", nil)
@@ -90,6 +102,37 @@ func TestCtlV1Raft(t *testing.T) {
 		assert.True(t, strings.Contains(got, `"state":"CANDIDATE"`))
 	}
 
+	// history 200.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/raft/history?limit=10", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		log.Debug("%s", got)
+		assert.True(t, strings.Contains(got, `"type":"state-change","state":"CANDIDATE"`))
+	}
+
+	// history 400.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/raft/history?limit=x", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(400)
+	}
+
+	// transfer 500.
+	{
+		p := &transferParams{}
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/raft/transfer", p)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
 	// disablechecksemisync 200.
 	{
 		req := test.MakeSimpleRequest("PUT", "http://localhost/v1/raft/disablechecksemisync", nil)
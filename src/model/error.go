@@ -20,6 +20,24 @@ const (
 	ErrorChangeMaster     = "ErrorChangeMaster"
 	ErrorBackupNotFound   = "ErrorBackupNotFound"
 	ErrorMysqldNotRunning = "ErrorMysqldNotRunning"
+	ErrorLeaderAlive      = "ErrorLeaderAlive"
+	ErrorCatchUpTimeout   = "ErrorCatchUpTimeout"
+	ErrorElectionTimeout  = "ErrorElectionTimeout"
+	ErrorLowerPriority    = "ErrorLowerPriority"
+	ErrorNeverPromote     = "ErrorNeverPromote"
+	ErrorInvalidEpochID   = "ErrorInvalidEpochID"
+	ErrorNotLeader        = "ErrorNotLeader"
+	ErrorChangeInProgress = "ErrorChangeInProgress"
+	ErrorChangeTimeout    = "ErrorChangeTimeout"
+	ErrorInMaintenance    = "ErrorInMaintenance"
+	ErrorNoTransferTarget = "ErrorNoTransferTarget"
+	ErrorFailoverFrozen   = "ErrorFailoverFrozen"
+	ErrorFailoverCooldown = "ErrorFailoverCooldown"
+	ErrorNotStandby       = "ErrorNotStandby"
+	ErrorZonePlacement    = "ErrorZonePlacement"
+
+	ErrorClusterIDMismatch = "ErrorClusterIDMismatch"
+	ErrorStandbyNotAllowed = "ErrorStandbyNotAllowed"
 )
 
 const (
@@ -13,6 +13,11 @@ const (
 	RPCHADisable     = "HARPC.HADisable"
 	RPCHAEnable      = "HARPC.HAEnable"
 	RPCHATryToLeader = "HARPC.HATryToLeader"
+
+	RPCHATransferLeader = "HARPC.HATransferLeader"
+
+	RPCHAEnterMaintenance = "HARPC.HAEnterMaintenance"
+	RPCHAExitMaintenance  = "HARPC.HAExitMaintenance"
 )
 
 type HARPCRequest struct {
@@ -36,3 +41,78 @@ func (req *HARPCRequest) GetFrom() string {
 func NewHARPCResponse(code string) *HARPCResponse {
 	return &HARPCResponse{RetCode: code}
 }
+
+type HATransferLeaderRPCRequest struct {
+	// My RPC client IP
+	From string
+
+	// The node which the leadership transfers to
+	To string
+}
+
+type HATransferLeaderRPCResponse struct {
+	// The leader after the transfer
+	Leader string
+
+	// How long(ms) the leader waited for the target to catch up
+	CatchUpTime int64
+
+	// How long(ms) the target took to win the election
+	ElectionTime int64
+
+	// How long(ms) the whole transfer took
+	TotalTime int64
+
+	// Return code to rpc client
+	RetCode string
+}
+
+func NewHATransferLeaderRPCRequest() *HATransferLeaderRPCRequest {
+	return &HATransferLeaderRPCRequest{}
+}
+
+func (req *HATransferLeaderRPCRequest) GetFrom() string {
+	return req.From
+}
+
+func (req *HATransferLeaderRPCRequest) GetTo() string {
+	return req.To
+}
+
+func NewHATransferLeaderRPCResponse(code string) *HATransferLeaderRPCResponse {
+	return &HATransferLeaderRPCResponse{RetCode: code}
+}
+
+type HAMaintenanceRPCRequest struct {
+	// My RPC client IP
+	From string
+
+	// How long(ms) the maintenance lasts
+	Duration int64
+
+	// Why the node enters maintenance
+	Reason string
+}
+
+type HAMaintenanceRPCResponse struct {
+	// The maintenance of the node after the call, nil if it's not in maintenance
+	Maintenance *RaftMaintenance
+
+	// The leader after the call, it's another node if the leadership was drained
+	Leader string
+
+	// Return code to rpc client
+	RetCode string
+}
+
+func NewHAMaintenanceRPCRequest() *HAMaintenanceRPCRequest {
+	return &HAMaintenanceRPCRequest{}
+}
+
+func (req *HAMaintenanceRPCRequest) GetFrom() string {
+	return req.From
+}
+
+func NewHAMaintenanceRPCResponse(code string) *HAMaintenanceRPCResponse {
+	return &HAMaintenanceRPCResponse{RetCode: code}
+}
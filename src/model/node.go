@@ -13,6 +13,7 @@ const (
 	RPCIdleNodesAdd    = "NodeRPC.AddIdleNodes"
 	RPCNodesRemove     = "NodeRPC.RemoveNodes"
 	RPCIdleNodesRemove = "NodeRPC.RemoveIdleNodes"
+	RPCWitnessNodesAdd = "NodeRPC.AddWitnessNodes"
 	RPCNodes           = "NodeRPC.GetNodes"
 )
 
@@ -22,6 +23,9 @@ type NodeRPCRequest struct {
 
 	// Node endpoint lists
 	Nodes []string
+
+	// Add the nodes even if the majority of the members would sit in one zone
+	Force bool
 }
 
 type NodeRPCResponse struct {
@@ -32,15 +36,33 @@ type NodeRPCResponse struct {
 	ViewID uint64
 
 	// The State of the raft:
-	// FOLLOWER/CANDIDATE/LEADER/IDLE/INVALID
+	// FOLLOWER/CANDIDATE/LEADER/IDLE/INVALID/WITNESS
 	State string
 
 	// The Leader endpoint of the cluster
 	Leader string
 
+	// The maintenance of the node, nil if it's not in maintenance
+	Maintenance *RaftMaintenance
+
+	// The failover freeze of the node, nil if the failover is not frozen
+	Freeze *RaftFreeze
+
+	// The cluster freeze of the node, nil if it's not set
+	ClusterFreeze *RaftFreeze
+
+	// The cluster ID of the node, empty before the cluster is bootstrapped
+	ClusterID string
+
 	// The Nodes(endpoint) of the cluster
 	Nodes []string
 
+	// The zone labels of the Nodes(endpoint -> zone), the nodes with unknown zone are absent
+	Zones map[string]string
+
+	// The Nodes(endpoint) which have adopted the EpochID of the membership change
+	Adopted []string
+
 	// Return code to rpc client:
 	// OK or other errors
 	RetCode string
@@ -66,6 +88,14 @@ func (rsp *NodeRPCResponse) GetNodes() []string {
 	return rsp.Nodes
 }
 
+func (rsp *NodeRPCResponse) GetZones() map[string]string {
+	return rsp.Zones
+}
+
+func (rsp *NodeRPCResponse) GetAdopted() []string {
+	return rsp.Adopted
+}
+
 func (rsp *NodeRPCResponse) GetLeader() string {
 	return rsp.Leader
 }
@@ -18,11 +18,18 @@ const (
 	RPCRaftPing                 = "RaftRPC.Ping"
 	RPCRaftHeartbeat            = "RaftRPC.Heartbeat"
 	RPCRaftRequestVote          = "RaftRPC.RequestVote"
+	RPCRaftPreVote              = "RaftRPC.PreVote"
 	RPCRaftStatus               = "RaftRPC.Status"
 	RPCRaftEnablePurgeBinlog    = "RaftRPC.EnablePurgeBinlog"
 	RPCRaftDisablePurgeBinlog   = "RaftRPC.DisablePurgeBinlog"
 	RPCRaftEnableCheckSemiSync  = "RaftRPC.EnableCheckSemiSync"
 	RPCRaftDisableCheckSemiSync = "RaftRPC.DisableCheckSemiSync"
+	RPCRaftHistory              = "RaftRPC.History"
+	RPCRaftUnfreeze             = "RaftRPC.Unfreeze"
+	RPCRaftFreeze               = "RaftRPC.Freeze"
+	RPCRaftSetTimings           = "RaftRPC.SetTimings"
+	RPCRaftPrimary              = "RaftRPC.Primary"
+	RPCRaftDRPromote            = "RaftRPC.DRPromote"
 )
 
 // raft
@@ -42,8 +49,21 @@ type Raft struct {
 	// The endpoint of the rpc call to
 	To string
 
-	// The state string(LEADER/CANCIDATE/FOLLOWER/IDLE/INVALID)
+	// The state string(LEADER/CANCIDATE/FOLLOWER/IDLE/INVALID/WITNESS)
 	State string
+
+	// The election priority of the node rpc call from
+	Priority int
+
+	// The zone label of the node rpc call from
+	Zone string
+
+	// The cluster ID of the node rpc call from, empty before the cluster is bootstrapped
+	ClusterID string
+
+	// The candidate rpc call from campaigns for a leader transfer, the voters skip the priority veto,
+	// or the leader rpc call from is elected by a leader transfer, the members don't count the leader change
+	Transfer bool
 }
 
 // replication info
@@ -70,13 +90,28 @@ type RaftRPCRequest struct {
 	GTID      GTID
 	Peers     []string
 	IdlePeers []string
+	Witnesses []string
+	NextPeers []string
+
+	// The cluster freeze the leader carries, nil if the cluster is not frozen
+	ClusterFreeze *RaftFreeze
+
+	// The raft timings the leader carries, the members follow the higher revision
+	Timings *RaftTimings
+
+	// The standby state the leader carries, nil if the cluster is not the disaster recovery standby
+	Standby *RaftStandby
 }
 
 type RaftRPCResponse struct {
 	Raft                  Raft
 	GTID                  GTID
 	Relay_Master_Log_File string
-	RetCode               string
+
+	// The raft timings of the heartbeat receiver, the leader follows the higher revision
+	Timings *RaftTimings
+
+	RetCode string
 }
 
 func NewRaftRPCRequest() *RaftRPCRequest {
@@ -107,6 +142,14 @@ func (req *RaftRPCRequest) GetIdlePeers() []string {
 	return req.IdlePeers
 }
 
+func (req *RaftRPCRequest) GetWitnesses() []string {
+	return req.Witnesses
+}
+
+func (req *RaftRPCRequest) GetNextPeers() []string {
+	return req.NextPeers
+}
+
 func (req *RaftRPCRequest) GetFrom() string {
 	return req.Raft.From
 }
@@ -178,6 +221,12 @@ type RaftStats struct {
 	// How many times the candidate degrades to a follower
 	CandidateDegrades uint64
 
+	// How many times the node failed to get the majority pre-votes
+	PreVoteFails uint64
+
+	// How many times the leader lease expired and the leader fenced itself
+	LeaderLeaseExpires uint64
+
 	// How long of the state up
 	StateUptimes uint64
 
@@ -188,9 +237,159 @@ type RaftStats struct {
 type RaftStatusRPCRequest struct {
 }
 
+// RaftLease tuple.
+type RaftLease struct {
+	// The lease timeout(ms) of the leader, 0 means disabled
+	Timeout int64
+
+	// The last time(unix ms) the leader renewed the lease by the majority heartbeat acks
+	RenewAt int64
+
+	// The remaining time(ms) of the lease, 0 if this node isn't the leader or the lease expired
+	Remaining int64
+}
+
+// RaftTimings tuple.
+type RaftTimings struct {
+	// The revision of the timings, 0 means they are from the config file
+	Revision int64 `json:"revision"`
+
+	// The leader heartbeat interval(ms)
+	HeartbeatTimeout int `json:"heartbeat-timeout"`
+
+	// The election timeout(ms)
+	ElectionTimeout int `json:"election-timeout"`
+
+	// The admit defeat count for the heartbeat
+	AdmitDefeatHtCnt int `json:"admit-defeat-hearbeat-count"`
+
+	// The purge binlog interval(ms)
+	PurgeBinlogInterval int `json:"purge-binlog-interval"`
+}
+
+// RaftLearnerProgress tuple.
+type RaftLearnerProgress struct {
+	// The lag in transactions behind the leader, -1 if it's unknown
+	LagTransactions int64 `json:"lag-transactions"`
+
+	// The max lag in transactions to promote to FOLLOWER
+	MaxLag int64 `json:"max-lag"`
+
+	// The time(unix ms) the learner has been within the max lag since, 0 if it's not
+	CaughtUpSince int64 `json:"caught-up-since"`
+
+	// The time(ms) the learner must stay within the max lag before the promotion
+	PromoteAfter int64 `json:"promote-after"`
+}
+
+// RaftPeerLag tuple.
+type RaftPeerLag struct {
+	// The peer endpoint
+	Peer string `json:"peer"`
+
+	// The state of the peer in the last heartbeat ack
+	State string `json:"state"`
+
+	// The slave IO/SQL thread state(Yes/No/Connecting) of the peer
+	IORunning  string `json:"io-running"`
+	SQLRunning string `json:"sql-running"`
+
+	// The executed GTID set of the peer
+	ExecutedGTIDSet string `json:"executed-gtid-set"`
+
+	// The transactions executed by the leader but not by the peer, -1 if unknown
+	LagTransactions int64 `json:"lag-transactions"`
+
+	// The Seconds_Behind_Master of the peer, -1 if unknown
+	LagSeconds int64 `json:"lag-seconds"`
+
+	// The last time(unix ms) the peer acked the heartbeat, 0 if never
+	LastAckAt int64 `json:"last-ack-at"`
+
+	// The heartbeats the peer missed since the last ack
+	AckMisses uint64 `json:"ack-misses"`
+}
+
+// RaftMaintenance tuple.
+type RaftMaintenance struct {
+	// Why the node is in maintenance
+	Reason string `json:"reason"`
+
+	// The time(unix ms) the maintenance began
+	Since int64 `json:"since"`
+
+	// The time(unix ms) the maintenance expires, the node re-enables itself then
+	Until int64 `json:"until"`
+}
+
+// RaftFreeze tuple.
+type RaftFreeze struct {
+	// Why the failover is frozen, such as 3.leader.changes.in.600000ms
+	Reason string `json:"reason"`
+
+	// The time(unix ms) the failover was frozen
+	Since int64 `json:"since"`
+
+	// Who froze the cluster failover, only for the cluster freeze
+	By string `json:"by,omitempty"`
+
+	// The time(unix ms) the cluster freeze expires, 0 means it never expires
+	Until int64 `json:"until,omitempty"`
+}
+
+// RaftHookResult tuple.
+type RaftHookResult struct {
+	// The stage of the hook: pre-promote/post-promote/pre-demote/post-demote/on-invalid/on-mysql-down
+	Stage string
+
+	// The name of the hook
+	Name string
+
+	// The time(unix ms) the last run started
+	StartAt int64
+
+	// The cost(ms) of the last run, includes the retries
+	Cost int64
+
+	// The attempts of the last run
+	Attempts int
+
+	// The output of the last attempt
+	Output string
+
+	// OK or the error of the last attempt
+	RetCode string
+}
+
 type RaftStatusRPCResponse struct {
 	Stats     *RaftStats
 	IdleCount uint64
+	Lease     RaftLease
+	Hooks     []RaftHookResult
+
+	// The replication lag of the peers, only the leader tracks them
+	Lags []RaftPeerLag
+
+	// The maintenance of this node, nil if it's not in maintenance
+	Maintenance *RaftMaintenance
+
+	// The failover freeze of this node, nil if the failover is not frozen
+	Freeze *RaftFreeze
+
+	// The leader changes this node saw in the failover window
+	LeaderChanges int
+
+	// The cluster freeze set by the operator and carried in the leader heartbeats, nil if it's not set
+	ClusterFreeze *RaftFreeze
+
+	// The progress of the LEARNER toward the automatic promotion, nil if it's not a LEARNER or the promotion is disabled
+	Learner *RaftLearnerProgress
+
+	// The raft timings in use
+	Timings RaftTimings
+
+	// The disaster recovery standby state, nil if the cluster is not the standby
+	Standby *RaftStandby
 
 	// The state info of this raft
 	// FOLLOWER/CANDIDATE/LEADER/IDLE
@@ -208,3 +407,191 @@ func NewRaftStatusRPCRequest() *RaftStatusRPCRequest {
 func NewRaftStatusRPCResponse(code string) *RaftStatusRPCResponse {
 	return &RaftStatusRPCResponse{RetCode: code}
 }
+
+// RaftEvent tuple.
+type RaftEvent struct {
+	// The time(unix ms) the event happened
+	Time int64 `json:"time"`
+
+	// The event type: state-change/vote-granted/vote-denied/degrade/epoch-change/maintenance/
+	// failover-frozen/failover-unfrozen/fence/cluster-freeze/timings-change/standby/dr-promote
+	Type string `json:"type"`
+
+	// The state of this raft when the event happened
+	State string `json:"state"`
+
+	// The ViewID and EpochID when the event happened
+	ViewID  uint64 `json:"viewid"`
+	EpochID uint64 `json:"epochid"`
+
+	// state-change/degrade: the old state, epoch-change: the old EpochID, maintenance/cluster-freeze: on/off
+	From string `json:"from,omitempty"`
+
+	// state-change/degrade: the new state, epoch-change: the new EpochID, maintenance/cluster-freeze: on/off
+	To string `json:"to,omitempty"`
+
+	// vote-granted/vote-denied: the candidate, fence: the old leader, cluster-freeze: who set the freeze,
+	// timings-change: who the timings are from, standby: the primary leader, dr-promote: who promoted
+	Peer string `json:"peer,omitempty"`
+
+	// vote-denied: the error code, degrade: the reason, such as lessHtAcks/mysqlDown,
+	// maintenance: the reason of the maintenance or expired, failover-frozen: the reason of the freeze,
+	// fence: super-read-only/fence-command if the old leader is fenced, otherwise timeout,
+	// timings-change: the new timings, standby: the primary MySQL, dr-promote: the last applied primary GTID
+	Reason string `json:"reason,omitempty"`
+}
+
+type RaftHistoryRPCRequest struct {
+	// The max number of the latest events to return, 0 means all
+	Limit int
+}
+
+type RaftHistoryRPCResponse struct {
+	// The events in time order
+	Events []RaftEvent
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftHistoryRPCRequest() *RaftHistoryRPCRequest {
+	return &RaftHistoryRPCRequest{}
+}
+
+func NewRaftHistoryRPCResponse(code string) *RaftHistoryRPCResponse {
+	return &RaftHistoryRPCResponse{RetCode: code}
+}
+
+type RaftFreezeRPCRequest struct {
+	// The node who sets the freeze
+	From string
+
+	// The time(unix ms) the freeze expires, 0 means it never expires
+	Until int64
+
+	// Why the failover is frozen
+	Reason string
+}
+
+type RaftFreezeRPCResponse struct {
+	// The cluster freeze of the leader
+	ClusterFreeze *RaftFreeze
+
+	// The leader of the cluster, it's useful when the RetCode is ErrorNotLeader
+	Leader string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+type RaftTimingsRPCRequest struct {
+	// The node who changes the timings
+	From string
+
+	// The timings to change, the zero fields are unchanged
+	Timings RaftTimings
+}
+
+type RaftTimingsRPCResponse struct {
+	// The timings of the leader after the change
+	Timings RaftTimings
+
+	// The leader of the cluster, it's useful when the RetCode is ErrorNotLeader
+	Leader string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftTimingsRPCRequest() *RaftTimingsRPCRequest {
+	return &RaftTimingsRPCRequest{}
+}
+
+func NewRaftTimingsRPCResponse(code string) *RaftTimingsRPCResponse {
+	return &RaftTimingsRPCResponse{RetCode: code}
+}
+
+func NewRaftFreezeRPCRequest() *RaftFreezeRPCRequest {
+	return &RaftFreezeRPCRequest{}
+}
+
+func NewRaftFreezeRPCResponse(code string) *RaftFreezeRPCResponse {
+	return &RaftFreezeRPCResponse{RetCode: code}
+}
+
+// RaftStandby tuple.
+type RaftStandby struct {
+	// The xenon endpoints of the primary cluster
+	Primary []string `json:"primary"`
+
+	// The primary leader the standby leader replicates from
+	PrimaryLeader string `json:"primary-leader,omitempty"`
+
+	// The MySQL(host:port) of the primary leader
+	Master string `json:"master,omitempty"`
+
+	// The last error of the primary leader discovery or the replication change
+	LastError string `json:"last-error,omitempty"`
+
+	// The time(unix ms) the standby is detached from the primary by dr-promote, 0 if it's still the standby
+	DetachedAt int64 `json:"detached-at,omitempty"`
+
+	// The node who detached the standby
+	DetachedBy string `json:"detached-by,omitempty"`
+
+	// The last applied primary GTID when the standby is detached
+	DetachedGTID string `json:"detached-gtid,omitempty"`
+}
+
+type RaftPrimaryRPCRequest struct {
+	// The node who asks for the primary leader
+	From string
+}
+
+type RaftPrimaryRPCResponse struct {
+	// The leader of the cluster, it's useful when the RetCode is ErrorNotLeader
+	Leader string
+
+	// The MySQL replication info of the leader
+	Repl Repl
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftPrimaryRPCRequest() *RaftPrimaryRPCRequest {
+	return &RaftPrimaryRPCRequest{}
+}
+
+func NewRaftPrimaryRPCResponse(code string) *RaftPrimaryRPCResponse {
+	return &RaftPrimaryRPCResponse{RetCode: code}
+}
+
+type RaftDRPromoteRPCRequest struct {
+	// The node who promotes the standby
+	From string
+}
+
+type RaftDRPromoteRPCResponse struct {
+	// The leader of the cluster, it's useful when the RetCode is ErrorNotLeader
+	Leader string
+
+	// The last applied primary GTID
+	GTID string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftDRPromoteRPCRequest() *RaftDRPromoteRPCRequest {
+	return &RaftDRPromoteRPCRequest{}
+}
+
+func NewRaftDRPromoteRPCResponse(code string) *RaftDRPromoteRPCResponse {
+	return &RaftDRPromoteRPCResponse{RetCode: code}
+}
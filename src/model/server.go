@@ -50,6 +50,8 @@ type ConfigStatus struct {
 // stats
 type ServerStats struct {
 	Uptimes uint64
+	// the rpc calls rejected by the authentication
+	AuthRejected uint64
 }
 
 type ServerRPCResponse struct {
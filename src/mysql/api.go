@@ -12,7 +12,6 @@ import (
 	"database/sql"
 	"fmt"
 	"model"
-	"strconv"
 	"strings"
 	"time"
 
@@ -91,27 +90,63 @@ func (m *Mysql) SetReadWrite() (err error) {
 	return
 }
 
-// GTIDGreaterThan used to compare the master_log_file and read_master_log_pos between from and this.
-func (m *Mysql) GTIDGreaterThan(gtid *model.GTID) (bool, model.GTID, error) {
+// SetRemoteReadOnly used to set the remote mysql to readonly with the admin user, it's used to fence the old leader.
+// SET GLOBAL super_read_only may block, the reads and the writes are bounded by the timeout(ms) too.
+func (m *Mysql) SetRemoteReadOnly(host string, port int, timeout int) error {
+	dial := m.conf.PingTimeout
+	if timeout < dial {
+		dial = timeout
+	}
+	connstr := fmt.Sprintf("%s:%s@tcp(%s:%d)/?timeout=%dms&readTimeout=%dms&writeTimeout=%dms", m.conf.Admin, m.conf.Passwd, host, port, dial, timeout, timeout)
+	db, err := sql.Open("mysql", connstr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Close()
+	return m.mysqlHandler.SetReadOnly(db, true)
+}
+
+// GTIDGreaterThan used to compare the GTID sets between this and from,
+// the decision is logged with the GTID sets behind it.
+func (m *Mysql) GTIDGreaterThan(gtid *model.GTID, tieBreak string) (bool, model.GTID, error) {
 	log := m.log
 	this, err := m.GetGTID()
 	if err != nil {
 		return false, this, err
 	}
 
-	a := strings.ToUpper(fmt.Sprintf("%s:%016d", this.Master_Log_File, this.Read_Master_Log_Pos))
-	b := strings.ToUpper(fmt.Sprintf("%s:%016d", gtid.Master_Log_File, gtid.Read_Master_Log_Pos))
-	log.Warning("mysql.gtid.compare.this[%v].from[%v]", this, gtid)
-	cmp := strings.Compare(a, b)
-	// compare seconds behind master
-	if cmp == 0 {
-		thislag, err1 := strconv.Atoi(this.Seconds_Behind_Master)
-		gtidlag, err2 := strconv.Atoi(gtid.Seconds_Behind_Master)
-		if err1 == nil && err2 == nil {
-			return (thislag < gtidlag), this, nil
+	greater, reason := GTIDGreater(&this, gtid, tieBreak)
+	log.Warning("mysql.gtid.compare.this[executed:%v, retrieved:%v].from[executed:%v, retrieved:%v].%v.greater[%v]",
+		compactGTIDSet(this.Executed_GTID_Set), compactGTIDSet(this.Retrieved_GTID_Set),
+		compactGTIDSet(gtid.Executed_GTID_Set), compactGTIDSet(gtid.Retrieved_GTID_Set), reason, greater)
+	return greater, this, nil
+}
+
+// GTIDGreater returns true if this GTID set is a strict superset of that one,
+// the incomparable sets follow the tieBreak policy, the reason tells how it's decided.
+// the sets can't be parsed are taken as greater, so the vote is rejected rather than granted to a garbled GTID.
+// it doesn't need the MySQL, the WITNESS compares the GTIDs carried in the requests with it.
+func GTIDGreater(this *model.GTID, that *model.GTID, tieBreak string) (bool, string) {
+	relation, thisOnly, thatOnly, err := CompareGTIDSet(this, that)
+	if err != nil {
+		return true, fmt.Sprintf("gtid.set.invalid[%v]", err)
+	}
+	reason := fmt.Sprintf("%v[this.only:%v, that.only:%v]", relation, thisOnly, thatOnly)
+	switch relation {
+	case GTIDSuperset:
+		return true, reason
+	case GTIDIncomparable:
+		reason = fmt.Sprintf("%v.tie-break[%v]", reason, tieBreak)
+		switch tieBreak {
+		case GTIDTieBreakGrant:
+			return false, reason
+		case GTIDTieBreakReject:
+			return true, reason
+		default:
+			return thisOnly > thatOnly, reason
 		}
 	}
-	return cmp > 0, this, nil
+	return false, reason
 }
 
 func (m *Mysql) GetLocalGTID(gtid string) (string, error) {
@@ -314,6 +349,20 @@ func (m *Mysql) RelayMasterLogFile() string {
 	return m.pingEntry.Relay_Master_Log_File
 }
 
+// GetReplStatus returns the replication status got by the last ping, it doesn't query the MySQL.
+func (m *Mysql) GetReplStatus() model.GTID {
+	pe := m.pingEntry
+	return model.GTID{
+		Relay_Master_Log_File: pe.Relay_Master_Log_File,
+		Executed_GTID_Set:     pe.Executed_GTID_Set,
+		Seconds_Behind_Master: pe.Seconds_Behind_Master,
+		Slave_IO_Running:      (pe.Slave_IO_Running == "Yes"),
+		Slave_IO_Running_Str:  pe.Slave_IO_Running,
+		Slave_SQL_Running:     (pe.Slave_SQL_Running == "Yes"),
+		Slave_SQL_Running_Str: pe.Slave_SQL_Running,
+	}
+}
+
 // WaitMysqlWorks used to wait for the mysqld to work.
 func (m *Mysql) WaitMysqlWorks(timeout int) error {
 	maxRunTime := time.Duration(timeout) * time.Millisecond
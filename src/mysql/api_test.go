@@ -371,137 +371,85 @@ func TestGTIDGreaterThan(t *testing.T) {
 	mysql := NewMysql(conf, 10000, log)
 	mysql.db = db
 
-	// 1. show slave status OK
-	{
-		query := "SHOW SLAVE STATUS"
-		columns := []string{"Master_Log_File",
-			"Read_Master_Log_Pos",
-			"Retrieved_Gtid_Set",
-			"Executed_Gtid_Set",
-			"Slave_IO_Running",
-			"Slave_SQL_Running"}
-
-		GTID := model.GTID{Master_Log_File: "mysql-bin.000001",
-			Read_Master_Log_Pos: 147,
-			Retrieved_GTID_Set:  "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			Executed_GTID_Set:   "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			Slave_IO_Running:    true,
-			Slave_SQL_Running:   true}
+	query := "SHOW SLAVE STATUS"
+	columns := []string{"Master_Log_File",
+		"Read_Master_Log_Pos",
+		"Retrieved_Gtid_Set",
+		"Executed_Gtid_Set",
+		"Seconds_Behind_Master",
+		"Slave_IO_Running",
+		"Slave_SQL_Running"}
 
-		mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
-			"148",
-			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+	tests := []struct {
+		// the show slave status of this node
+		file      string
+		pos       string
+		retrieved string
+		executed  string
+		// the GTID of the candidate
+		gtid     model.GTID
+		tieBreak string
+		want     bool
+	}{
+		// 1. the retrieved set is a superset
+		{"mysql-bin.000001", "148",
+			"84030605-66aa-11e6-9465-52540e7fd51c:154-161",
 			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			"Yes",
-			"Yes")
-
-		mock.ExpectQuery(query).WillReturnRows(mockRows)
-		want := true
-		got, _, err := mysql.GTIDGreaterThan(&GTID)
-		assert.Nil(t, err)
-		assert.Equal(t, want, got)
-	}
-
-	// 2. Seconds_Behind_Master
-	{
-		query := "SHOW SLAVE STATUS"
-		columns := []string{"Master_Log_File",
-			"Read_Master_Log_Pos",
-			"Retrieved_Gtid_Set",
-			"Executed_Gtid_Set",
-			"Seconds_Behind_Master",
-			"Slave_IO_Running",
-			"Slave_SQL_Running"}
-
-		GTID := model.GTID{Master_Log_File: "mysql-bin.000001",
-			Read_Master_Log_Pos:   147,
-			Retrieved_GTID_Set:    "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			Seconds_Behind_Master: "13",
-			Slave_IO_Running:      true,
-			Slave_SQL_Running:     true}
-
-		mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
-			"147",
+			model.GTID{Master_Log_File: "mysql-bin.000001",
+				Read_Master_Log_Pos: 147,
+				Retrieved_GTID_Set:  "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+				Executed_GTID_Set:   "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1"},
+			GTIDTieBreakMore, true},
+		// 2. the same sets, the binlog file and position are ignored
+		{"mysql-bin.000009", "999",
 			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
 			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
+			model.GTID{Master_Log_File: "mysql-bin.000001",
+				Read_Master_Log_Pos:   147,
+				Retrieved_GTID_Set:    "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+				Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
+				Seconds_Behind_Master: "13"},
+			GTIDTieBreakMore, false},
+		// 3. the subset
+		{"mysql-bin.000002", "147",
+			"84030605-66aa-11e6-9465-52540e7fd51c:154-158",
+			"84030605-66aa-11e6-9465-52540e7fd51c:1-158",
+			model.GTID{Master_Log_File: "mysql-bin.000001",
+				Read_Master_Log_Pos: 147,
+				Retrieved_GTID_Set:  "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+				Executed_GTID_Set:   "84030605-66aa-11e6-9465-52540e7fd51c:1-159"},
+			GTIDTieBreakMore, false},
+		// 4. the incomparable sets, this has more
+		{"mysql-bin.000001", "147",
+			"ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3",
+			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3",
+			model.GTID{Master_Log_File: "mysql-bin.000001",
+				Read_Master_Log_Pos: 147,
+				Executed_GTID_Set:   "84030605-66aa-11e6-9465-52540e7fd51c:1-160"},
+			GTIDTieBreakMore, true},
+		// 5. the incomparable sets, the grant policy
+		{"mysql-bin.000001", "147",
+			"ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3",
+			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3",
+			model.GTID{Master_Log_File: "mysql-bin.000001",
+				Read_Master_Log_Pos: 147,
+				Executed_GTID_Set:   "84030605-66aa-11e6-9465-52540e7fd51c:1-160"},
+			GTIDTieBreakGrant, false},
+	}
+	for _, test := range tests {
+		mockRows := sqlmock.NewRows(columns).AddRow(test.file,
+			test.pos,
+			test.retrieved,
+			test.executed,
 			"12",
 			"Yes",
 			"Yes")
 
 		mock.ExpectQuery(query).WillReturnRows(mockRows)
-		want := true
-		got, _, err := mysql.GTIDGreaterThan(&GTID)
-		assert.Nil(t, err)
-		assert.Equal(t, want, got)
-	}
-
-	// 3. Seconds_Behind_Master error
-	{
-		query := "SHOW SLAVE STATUS"
-		columns := []string{"Master_Log_File",
-			"Read_Master_Log_Pos",
-			"Retrieved_Gtid_Set",
-			"Executed_Gtid_Set",
-			"Seconds_Behind_Master",
-			"Slave_IO_Running",
-			"Slave_SQL_Running"}
-
-		GTID := model.GTID{Master_Log_File: "mysql-bin.000001",
-			Read_Master_Log_Pos:   147,
-			Retrieved_GTID_Set:    "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			Seconds_Behind_Master: "13",
-			Slave_IO_Running:      true,
-			Slave_SQL_Running:     true}
-
-		mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
-			"147",
-			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			"NULL",
-			"Yes",
-			"Yes")
-
-		mock.ExpectQuery(query).WillReturnRows(mockRows)
-		want := false
-		got, _, err := mysql.GTIDGreaterThan(&GTID)
-		assert.Nil(t, err)
-		assert.Equal(t, want, got)
-	}
-
-	// 4. Seconds_Behind_Master error too
-	{
-		query := "SHOW SLAVE STATUS"
-		columns := []string{"Master_Log_File",
-			"Read_Master_Log_Pos",
-			"Retrieved_Gtid_Set",
-			"Executed_Gtid_Set",
-			"Seconds_Behind_Master",
-			"Slave_IO_Running",
-			"Slave_SQL_Running"}
-
-		GTID := model.GTID{Master_Log_File: "mysql-bin.000001",
-			Read_Master_Log_Pos:   147,
-			Retrieved_GTID_Set:    "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			Seconds_Behind_Master: "NULL",
-			Slave_IO_Running:      true,
-			Slave_SQL_Running:     true}
-
-		mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
-			"147",
-			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
-			"84030605-66aa-11e6-9465-52540e7fd51c:1-159,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1",
-			"14",
-			"Yes",
-			"Yes")
-
-		mock.ExpectQuery(query).WillReturnRows(mockRows)
-		want := false
-		got, _, err := mysql.GTIDGreaterThan(&GTID)
+		got, this, err := mysql.GTIDGreaterThan(&test.gtid, test.tieBreak)
 		assert.Nil(t, err)
-		assert.Equal(t, want, got)
+		assert.Equal(t, test.want, got, "%+v", test)
+		assert.Equal(t, test.executed, this.Executed_GTID_Set)
 	}
 }
 
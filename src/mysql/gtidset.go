@@ -0,0 +1,175 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"model"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gtidInterval is the closed interval of the transaction numbers.
+type gtidInterval struct {
+	start uint64
+	end   uint64
+}
+
+// parseGTIDSet parses the GTID set such as 'uuid1:1-5:7,uuid2:1-3' to the intervals of every source,
+// the source is the uuid, or uuid:tag of the tagged GTIDs in MySQL 8.4.
+func parseGTIDSet(set string) (map[string][]gtidInterval, error) {
+	sources := make(map[string][]gtidInterval)
+	set = compactGTIDSet(set)
+	if set == "" {
+		return sources, nil
+	}
+
+	for _, gtid := range strings.Split(set, ",") {
+		parts := strings.Split(gtid, ":")
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, errors.Errorf("mysql.gtid.set[%v].invalid", gtid)
+		}
+		source := strings.ToLower(parts[0])
+		for _, part := range parts[1:] {
+			bounds := strings.SplitN(part, "-", 2)
+			start, err := strconv.ParseUint(bounds[0], 10, 64)
+			if err != nil {
+				// the tag of the following intervals
+				source = strings.ToLower(parts[0]) + ":" + part
+				continue
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseUint(bounds[1], 10, 64); err != nil || end < start {
+					return nil, errors.Errorf("mysql.gtid.set[%v].invalid.interval[%v]", gtid, part)
+				}
+			}
+			sources[source] = append(sources[source], gtidInterval{start: start, end: end})
+		}
+	}
+	return sources, nil
+}
+
+// compactGTIDSet removes the line breaks MySQL puts in the GTID set.
+func compactGTIDSet(set string) string {
+	return strings.NewReplacer("\n", "", "\r", "", " ", "", "\t", "").Replace(set)
+}
+
+// gtidSet is the union of the parsed GTID sets, the intervals of every source are sorted and merged.
+type gtidSet map[string][]gtidInterval
+
+// newGTIDSet parses the GTID sets and returns their union.
+func newGTIDSet(sets ...string) (gtidSet, error) {
+	union := make(gtidSet)
+	for _, set := range sets {
+		sources, err := parseGTIDSet(set)
+		if err != nil {
+			return nil, err
+		}
+		for source, intervals := range sources {
+			union[source] = append(union[source], intervals...)
+		}
+	}
+	for source, intervals := range union {
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+		merged := intervals[:1]
+		for _, interval := range intervals[1:] {
+			last := &merged[len(merged)-1]
+			if interval.start <= last.end+1 {
+				if interval.end > last.end {
+					last.end = interval.end
+				}
+				continue
+			}
+			merged = append(merged, interval)
+		}
+		union[source] = merged
+	}
+	return union, nil
+}
+
+// subtract returns the number of the transactions in s but not in other.
+func (s gtidSet) subtract(other gtidSet) uint64 {
+	var count uint64
+	for source, intervals := range s {
+		for _, interval := range intervals {
+			count += interval.end - interval.start + 1
+			// the intervals of other don't overlap each other in one source
+			for _, o := range other[source] {
+				start, end := interval.start, interval.end
+				if o.start > start {
+					start = o.start
+				}
+				if o.end < end {
+					end = o.end
+				}
+				if start <= end {
+					count -= end - start + 1
+				}
+			}
+		}
+	}
+	return count
+}
+
+// GTIDSetLag returns the number of the transactions in the master GTID set but not in the slave one,
+// it works like GTID_SUBTRACT(master, slave) without the MySQL.
+func GTIDSetLag(master string, slave string) (uint64, error) {
+	masters, err := newGTIDSet(master)
+	if err != nil {
+		return 0, err
+	}
+	slaves, err := newGTIDSet(slave)
+	if err != nil {
+		return 0, err
+	}
+	return masters.subtract(slaves), nil
+}
+
+// the relations of the GTID set of this node to that node
+const (
+	GTIDEqual        = "equal"
+	GTIDSuperset     = "superset"
+	GTIDSubset       = "subset"
+	GTIDIncomparable = "incomparable"
+)
+
+// the tie-break policies when neither GTID set contains the other
+const (
+	GTIDTieBreakMore   = "more"
+	GTIDTieBreakGrant  = "grant"
+	GTIDTieBreakReject = "reject"
+)
+
+// CompareGTIDSet compares the GTID set of this node with that node, the set is the union of
+// Executed_GTID_Set and Retrieved_GTID_Set, since the new leader applies its relay log before serving.
+// it returns the relation and the numbers of the transactions only in this and only in that.
+func CompareGTIDSet(this *model.GTID, that *model.GTID) (string, uint64, uint64, error) {
+	thisSet, err := newGTIDSet(this.Executed_GTID_Set, this.Retrieved_GTID_Set)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	thatSet, err := newGTIDSet(that.Executed_GTID_Set, that.Retrieved_GTID_Set)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	thisOnly, thatOnly := thisSet.subtract(thatSet), thatSet.subtract(thisSet)
+	switch {
+	case thisOnly == 0 && thatOnly == 0:
+		return GTIDEqual, 0, 0, nil
+	case thatOnly == 0:
+		return GTIDSuperset, thisOnly, 0, nil
+	case thisOnly == 0:
+		return GTIDSubset, 0, thatOnly, nil
+	}
+	return GTIDIncomparable, thisOnly, thatOnly, nil
+}
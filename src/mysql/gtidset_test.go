@@ -0,0 +1,121 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGTIDSetLag(t *testing.T) {
+	tests := []struct {
+		master string
+		slave  string
+		lag    uint64
+	}{
+		{"", "", 0},
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-160", "84030605-66aa-11e6-9465-52540e7fd51c:1-160", 0},
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-160", "84030605-66aa-11e6-9465-52540e7fd51c:1-150", 10},
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-160", "", 160},
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-160:170", "84030605-66AA-11E6-9465-52540E7FD51C:1-100:150-155", 55},
+		// the slave has more, no lag
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-150", "84030605-66aa-11e6-9465-52540e7fd51c:1-160", 0},
+		// multi sources with the newlines of 'show slave status'
+		{`052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-37,
+    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`, `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
+    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`, 1},
+		// tagged
+		{"84030605-66aa-11e6-9465-52540e7fd51c:1-10:tag:1-5", "84030605-66aa-11e6-9465-52540e7fd51c:1-10", 5},
+	}
+	for _, test := range tests {
+		lag, err := GTIDSetLag(test.master, test.slave)
+		assert.Nil(t, err)
+		assert.Equal(t, test.lag, lag, "%v - %v", test.master, test.slave)
+	}
+
+	_, err := GTIDSetLag("84030605-66aa-11e6-9465-52540e7fd51c", "")
+	assert.NotNil(t, err)
+	_, err = GTIDSetLag("84030605-66aa-11e6-9465-52540e7fd51c:10-1", "")
+	assert.NotNil(t, err)
+}
+
+func TestCompareGTIDSet(t *testing.T) {
+	tests := []struct {
+		this     model.GTID
+		that     model.GTID
+		relation string
+		thisOnly uint64
+		thatOnly uint64
+	}{
+		{model.GTID{}, model.GTID{}, GTIDEqual, 0, 0},
+		// the binlog file and position are ignored
+		{model.GTID{Master_Log_File: "mysql-bin.000009", Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-160"},
+			model.GTID{Master_Log_File: "mysql-bin.000001", Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-160"}, GTIDEqual, 0, 0},
+		// the retrieved transactions are counted
+		{model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-150", Retrieved_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:140-160"},
+			model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-155"}, GTIDSuperset, 5, 0},
+		{model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-150"},
+			model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-160"}, GTIDSubset, 0, 10},
+		// the followers replicated from the different old masters
+		{model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-160,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3"},
+			model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-161"}, GTIDIncomparable, 3, 1},
+	}
+	for _, test := range tests {
+		relation, thisOnly, thatOnly, err := CompareGTIDSet(&test.this, &test.that)
+		assert.Nil(t, err)
+		assert.Equal(t, test.relation, relation, "%+v - %+v", test.this, test.that)
+		assert.Equal(t, test.thisOnly, thisOnly)
+		assert.Equal(t, test.thatOnly, thatOnly)
+	}
+
+	_, _, _, err := CompareGTIDSet(&model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c"}, &model.GTID{})
+	assert.NotNil(t, err)
+}
+
+func TestGTIDGreater(t *testing.T) {
+	superset := &model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-160"}
+	subset := &model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-150"}
+	more := &model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-150,ebd03dad-69ad-11e6-aa22-52540e7fd51c:1-3"}
+	less := &model.GTID{Executed_GTID_Set: "84030605-66aa-11e6-9465-52540e7fd51c:1-151"}
+
+	tests := []struct {
+		this     *model.GTID
+		that     *model.GTID
+		tieBreak string
+		greater  bool
+	}{
+		{superset, subset, GTIDTieBreakMore, true},
+		{subset, superset, GTIDTieBreakMore, false},
+		{superset, superset, GTIDTieBreakReject, false},
+		{more, less, GTIDTieBreakMore, true},
+		{less, more, GTIDTieBreakMore, false},
+		{more, less, "", true},
+		{more, less, GTIDTieBreakGrant, false},
+		{less, more, GTIDTieBreakReject, true},
+		{more, less, GTIDTieBreakReject, true},
+		{superset, subset, GTIDTieBreakReject, true},
+		{subset, superset, GTIDTieBreakReject, false},
+	}
+	for _, test := range tests {
+		greater, reason := GTIDGreater(test.this, test.that, test.tieBreak)
+		assert.Equal(t, test.greater, greater, "%+v - %+v - %v: %v", *test.this, *test.that, test.tieBreak, reason)
+	}
+
+	// the invalid sets reject the vote whatever the tie-break is
+	invalid := &model.GTID{Executed_GTID_Set: "invalid"}
+	for _, tieBreak := range []string{GTIDTieBreakMore, GTIDTieBreakGrant, GTIDTieBreakReject} {
+		for _, pair := range [][2]*model.GTID{{invalid, subset}, {subset, invalid}} {
+			greater, reason := GTIDGreater(pair[0], pair[1], tieBreak)
+			assert.True(t, greater, "%+v - %+v - %v", *pair[0], *pair[1], tieBreak)
+			assert.Contains(t, reason, "gtid.set.invalid")
+		}
+	}
+}
@@ -437,6 +437,11 @@ func defaultMockGTID() *MockGTID {
 	return mock
 }
 
+// mockGTIDSetA is the GTID set of the mock A,
+// the AA/B/C and X mocks build on it, so they are the supersets of A.
+const mockGTIDSetA = `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
+    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`
+
 // GetSlaveGTIDA mock.
 // with GTID{Master_Log_File = "", Read_Master_Log_Pos = 0}
 // all functions return is OK
@@ -451,10 +456,8 @@ func GetSlaveGTIDA(db *sql.DB) (*model.GTID, error) {
 	gtid.Seconds_Behind_Master = "1"
 	gtid.Last_Error = ""
 	gtid.Slave_SQL_Running_State = "Slave has read all relay log; waiting for the slave I/O thread to update it"
-	gtid.Executed_GTID_Set = `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
-    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`
-	gtid.Retrieved_GTID_Set = `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
-    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`
+	gtid.Executed_GTID_Set = mockGTIDSetA
+	gtid.Retrieved_GTID_Set = mockGTIDSetA
 	return gtid, nil
 }
 
@@ -468,10 +471,8 @@ func GetMasterGTIDA(db *sql.DB) (*model.GTID, error) {
 	gtid.Seconds_Behind_Master = "0"
 	gtid.Last_Error = ""
 	gtid.Slave_SQL_Running_State = ""
-	gtid.Executed_GTID_Set = `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
-    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`
-	gtid.Retrieved_GTID_Set = `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36,
-    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`
+	gtid.Executed_GTID_Set = mockGTIDSetA
+	gtid.Retrieved_GTID_Set = mockGTIDSetA
 	return gtid, nil
 }
 
@@ -542,8 +543,8 @@ func GetSlaveGTIDAA(db *sql.DB) (*model.GTID, error) {
 	gtid.Read_Master_Log_Pos = 122
 	gtid.Slave_IO_Running = true
 	gtid.Slave_SQL_Running = true
-	gtid.Executed_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1"
-	gtid.Retrieved_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1"
 	return gtid, nil
 }
 
@@ -554,7 +555,7 @@ func GetMasterGTIDAA(db *sql.DB) (*model.GTID, error) {
 	gtid.Master_Log_File = "mysql-bin.000001"
 	gtid.Read_Master_Log_Pos = 122
 	gtid.Executed_GTID_Set = ""
-	gtid.Retrieved_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_SQL_Running = true
 	return gtid, nil
@@ -578,8 +579,8 @@ func GetSlaveGTIDB(db *sql.DB) (*model.GTID, error) {
 	gtid.Read_Master_Log_Pos = 123
 	gtid.Slave_IO_Running = true
 	gtid.Slave_SQL_Running = true
-	gtid.Executed_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
-	gtid.Retrieved_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
 	return gtid, nil
 }
 
@@ -589,7 +590,7 @@ func GetMasterGTIDB(db *sql.DB) (*model.GTID, error) {
 
 	gtid.Master_Log_File = "mysql-bin.000001"
 	gtid.Read_Master_Log_Pos = 123
-	gtid.Executed_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-2"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_SQL_Running = true
 	return gtid, nil
@@ -648,7 +649,7 @@ func GetSlaveGTIDC(db *sql.DB) (*model.GTID, error) {
 	gtid.Slave_SQL_Running = true
 	gtid.Slave_IO_Running_Str = "Yes"
 	gtid.Slave_SQL_Running_Str = "Yes"
-	gtid.Retrieved_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
 	return gtid, nil
 }
 
@@ -657,8 +658,8 @@ func GetMasterGTIDC(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 	gtid.Master_Log_File = "mysql-bin.000001"
 	gtid.Read_Master_Log_Pos = 125
-	gtid.Executed_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
-	gtid.Retrieved_GTID_Set = "c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",c78e798a-cccc-cccc-cccc-525433e8e796:1-3"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_SQL_Running = true
 	return gtid, nil
@@ -981,7 +982,7 @@ func NewMockGTIDX1() *MockGTID {
 
 // PingX1 mock.
 func PingX1(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000001"}, nil
+	return &PingEntry{Relay_Master_Log_File: "mysql-bin.000001"}, nil
 }
 
 // GetSlaveGTIDX1 mock.
@@ -989,8 +990,8 @@ func GetSlaveGTIDX1(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 	gtid.Master_Log_File = "mysql-bin.000001"
 	gtid.Read_Master_Log_Pos = 123
-	gtid.Retrieved_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"
-	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_IO_Running_Str = "Yes"
 	gtid.Slave_SQL_Running = true
@@ -1011,7 +1012,7 @@ func NewMockGTIDX3() *MockGTID {
 
 // PingX3 mock.
 func PingX3(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000003"}, nil
+	return &PingEntry{Relay_Master_Log_File: "mysql-bin.000003"}, nil
 }
 
 // GetSlaveGTIDX3 mock.
@@ -1019,8 +1020,8 @@ func GetSlaveGTIDX3(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 	gtid.Master_Log_File = "mysql-bin.000003"
 	gtid.Read_Master_Log_Pos = 123
-	gtid.Retrieved_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1-2"
-	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1-2"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_IO_Running_Str = "Yes"
 	gtid.Slave_SQL_Running = true
@@ -1041,7 +1042,7 @@ func NewMockGTIDX5() *MockGTID {
 
 // PingX5 mock.
 func PingX5(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000005"}, nil
+	return &PingEntry{Relay_Master_Log_File: "mysql-bin.000005"}, nil
 }
 
 // GetSlaveGTIDX5 mock.
@@ -1049,8 +1050,8 @@ func GetSlaveGTIDX5(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 	gtid.Master_Log_File = "mysql-bin.000005"
 	gtid.Read_Master_Log_Pos = 123
-	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"
-	gtid.Retrieved_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1-3"
+	gtid.Executed_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1"
+	gtid.Retrieved_GTID_Set = mockGTIDSetA + ",6127a668-gtid-x555-a28d-5254335479b2:1-3"
 	gtid.Slave_IO_Running = true
 	gtid.Slave_IO_Running_Str = "Yes"
 	gtid.Slave_SQL_Running = true
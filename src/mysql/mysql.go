@@ -14,7 +14,6 @@ import (
 	"fmt"
 	"model"
 	"sync"
-	"time"
 	"xbase/common"
 	"xbase/xlog"
 )
@@ -34,6 +33,10 @@ const (
 // PingEntry tuple.
 type PingEntry struct {
 	Relay_Master_Log_File string
+	Executed_GTID_Set     string
+	Seconds_Behind_Master string
+	Slave_IO_Running      string
+	Slave_SQL_Running     string
 }
 
 // Mysql tuple.
@@ -47,7 +50,8 @@ type Mysql struct {
 	dbmutex      sync.RWMutex
 	mysqlHandler MysqlHandler
 	pingEntry    PingEntry
-	pingTicker   *time.Ticker
+	pingTicker   *common.Ticker
+	clock        common.Clock
 	stats        model.MysqlStats
 	downs        int
 }
@@ -60,12 +64,20 @@ func NewMysql(conf *config.MysqlConfig, queryTimeout int, log *xlog.Log) *Mysql
 		conf:         conf,
 		state:        model.MysqlDead,
 		mysqlHandler: getHandler(conf.Version),
-		pingTicker:   common.NormalTicker(conf.PingTimeout),
+		pingTicker:   common.NewNormalTicker(common.RealClock, conf.PingTimeout),
+		clock:        common.RealClock,
 	}
 	mysql.mysqlHandler.SetQueryTimeout(queryTimeout)
 	return mysql
 }
 
+// SetClock used to set the clock of the ping ticker, it must be called before PingStart.
+func (m *Mysql) SetClock(clock common.Clock) {
+	m.pingTicker.Stop()
+	m.clock = clock
+	m.pingTicker = common.NewNormalTicker(clock, m.conf.PingTimeout)
+}
+
 // SetMysqlHandler used to set the repl handler.
 func (m *Mysql) SetMysqlHandler(h MysqlHandler) {
 	m.mysqlHandler = h
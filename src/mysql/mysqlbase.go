@@ -62,7 +62,7 @@ func (my *MysqlBase) SetQueryTimeout(timeout int) {
 
 // Ping has 2 affects:
 // one for heath check
-// other for get master_binglog the slave is syncing
+// other for get master_binglog the slave is syncing and the replication lag
 func (my *MysqlBase) Ping(db *sql.DB) (*PingEntry, error) {
 	pe := &PingEntry{}
 	query := "SHOW SLAVE STATUS"
@@ -71,7 +71,12 @@ func (my *MysqlBase) Ping(db *sql.DB) (*PingEntry, error) {
 		return nil, err
 	}
 	if len(rows) > 0 {
-		pe.Relay_Master_Log_File = rows[0]["Relay_Master_Log_File"]
+		row := rows[0]
+		pe.Relay_Master_Log_File = row["Relay_Master_Log_File"]
+		pe.Executed_GTID_Set = row["Executed_Gtid_Set"]
+		pe.Seconds_Behind_Master = row["Seconds_Behind_Master"]
+		pe.Slave_IO_Running = row["Slave_IO_Running"]
+		pe.Slave_SQL_Running = row["Slave_SQL_Running"]
 	}
 	return pe, nil
 }
@@ -33,18 +33,32 @@ func TestMysqlBasePing(t *testing.T) {
 	columns := []string{"Master_Log_File",
 		"Read_Master_Log_Pos",
 		"Relay_Master_Log_File",
+		"Executed_Gtid_Set",
+		"Seconds_Behind_Master",
+		"Slave_IO_Running",
+		"Slave_SQL_Running",
 	}
 	mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
 		"147",
 		"mysql-bin.000001",
+		"84030605-66aa-11e6-9465-52540e7fd51c:1-160",
+		"3",
+		"Yes",
+		"No",
 	)
 
 	mock.ExpectQuery(query).WillReturnRows(mockRows)
 	pe, err := mysqlbase.Ping(db)
 	assert.Nil(t, err)
 
-	want := "mysql-bin.000001"
-	got := pe.Relay_Master_Log_File
+	want := &PingEntry{
+		Relay_Master_Log_File: "mysql-bin.000001",
+		Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-160",
+		Seconds_Behind_Master: "3",
+		Slave_IO_Running:      "Yes",
+		Slave_SQL_Running:     "No",
+	}
+	got := pe
 	assert.Equal(t, want, got)
 }
 
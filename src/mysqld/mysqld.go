@@ -14,7 +14,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 	"xbase/common"
 	"xbase/xlog"
 )
@@ -25,7 +24,8 @@ type Mysqld struct {
 	log            *xlog.Log
 	cmd            common.Command
 	backup         *Backup
-	monitorTicker  *time.Ticker
+	monitorTicker  *common.Ticker
+	clock          common.Clock
 	monitorRunning bool
 	mutex          sync.RWMutex
 	status         model.MYSQLD_STATUS
@@ -39,12 +39,18 @@ func NewMysqld(conf *config.BackupConfig, log *xlog.Log) *Mysqld {
 		conf:        conf,
 		log:         log,
 		cmd:         common.NewLinuxCommand(log),
+		clock:       common.RealClock,
 		backup:      NewBackup(conf, log),
 		status:      model.MYSQLD_NOTRUNNING,
 		argsHandler: NewLinuxArgs(conf),
 	}
 }
 
+// SetClock used to set the clock of the monitor ticker, it must be called before MonitorStart.
+func (m *Mysqld) SetClock(clock common.Clock) {
+	m.clock = clock
+}
+
 // SetArgsHandler used to set the args handler.
 func (m *Mysqld) SetArgsHandler(h ArgsHandler) {
 	m.argsHandler = h
@@ -145,7 +151,7 @@ func (m *Mysqld) MonitorStart() {
 	}
 
 	// create ticker
-	m.monitorTicker = common.NormalTicker(m.conf.MysqldMonitorInterval)
+	m.monitorTicker = common.NewNormalTicker(m.clock, m.conf.MysqldMonitorInterval)
 	go func() {
 		for range m.monitorTicker.C {
 			m.monitor()
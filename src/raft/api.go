@@ -8,6 +8,10 @@
 
 package raft
 
+import (
+	"model"
+)
+
 // AddPeer used to add a peer to peers.
 func (r *Raft) AddPeer(connStr string) error {
 	r.mutex.Lock()
@@ -33,7 +37,7 @@ func (r *Raft) AddPeer(connStr string) error {
 
 		// write configure to file
 		r.incEpochID()
-		r.writePeersJSON()
+		r.writeMeta()
 	}
 	r.WARNING("add.peer[%v].to.peers[%+v]", connStr, r.peers)
 	return nil
@@ -64,12 +68,44 @@ func (r *Raft) AddIdlePeer(connStr string) error {
 
 		// write configure to file
 		r.incEpochID()
-		r.writePeersJSON()
+		r.writeMeta()
 	}
 	r.WARNING("add.peer[%v].to.idlePeers[%+v]", connStr, r.idlePeers)
 	return nil
 }
 
+// AddWitnessPeer used to add a witness peer to peers, the witness votes but runs no MySQL.
+func (r *Raft) AddWitnessPeer(connStr string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.peers[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.peers[%+v].can't.add.repeatedly", connStr, r.peers)
+		return nil
+	}
+
+	if r.idlePeers[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.idlePeers[%+v].can't.add.repeatedly", connStr, r.idlePeers)
+		return nil
+	}
+
+	// we can't add ourself
+	if r.getID() != connStr {
+		p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+		r.peers[connStr] = p
+
+		// append peer to conf.Raft.Peers and conf.Raft.Witnesses
+		r.meta.Peers = append(r.meta.Peers, connStr)
+		r.meta.Witnesses = append(r.meta.Witnesses, connStr)
+
+		// write configure to file
+		r.incEpochID()
+		r.writeMeta()
+	}
+	r.WARNING("add.peer[%v].to.witnesses[%+v]", connStr, r.meta.Witnesses)
+	return nil
+}
+
 // RemovePeer used to remove a peer from peers.
 func (r *Raft) RemovePeer(connStr string) error {
 	r.mutex.Lock()
@@ -81,6 +117,7 @@ func (r *Raft) RemovePeer(connStr string) error {
 			r.WARNING("peer[%v].not.exists.in.peers[%+v]", connStr, r.peers)
 			return nil
 		}
+		r.peers[connStr].freePeer()
 		delete(r.peers, connStr)
 
 		// remove peer from conf.Raft.Peers
@@ -91,9 +128,17 @@ func (r *Raft) RemovePeer(connStr string) error {
 			}
 		}
 
+		// remove peer from conf.Raft.Witnesses
+		for i, v := range r.meta.Witnesses {
+			if v == connStr {
+				r.meta.Witnesses = append(r.meta.Witnesses[:i], r.meta.Witnesses[i+1:]...)
+				break
+			}
+		}
+
 		// write configure to file
 		r.incEpochID()
-		r.writePeersJSON()
+		r.writeMeta()
 	}
 	r.WARNING("removed.peer[%v].from.peers[%+v]", connStr, r.peers)
 	return nil
@@ -110,6 +155,7 @@ func (r *Raft) RemoveIdlePeer(connStr string) error {
 			r.WARNING("peer[%v].not.exists.in.idlePeers[%+v]", connStr, r.idlePeers)
 			return nil
 		}
+		r.idlePeers[connStr].freePeer()
 		delete(r.idlePeers, connStr)
 
 		// remove peer from conf.Raft.Peers
@@ -122,7 +168,7 @@ func (r *Raft) RemoveIdlePeer(connStr string) error {
 
 		// write configure to file
 		r.incEpochID()
-		r.writePeersJSON()
+		r.writeMeta()
 	}
 	r.WARNING("removed.peer[%v].from.idlePeers[%+v]", connStr, r.idlePeers)
 	return nil
@@ -143,6 +189,22 @@ func (r *Raft) GetIdlePeers() []string {
 	return r.getIdlePeers()
 }
 
+// GetZones returns the zone labels of all the members.
+func (r *Raft) GetZones() map[string]string {
+	return r.getZones()
+}
+
+// GetWitnesses returns witness peers string.
+func (r *Raft) GetWitnesses() []string {
+	return r.getWitnesses()
+}
+
+// IsWitness returns true if this node is a WITNESS,
+// it's started with the WITNESS role or added as a witness by the cluster.
+func (r *Raft) IsWitness() bool {
+	return r.initRole == WITNESS || r.isWitnessPeer(r.getID())
+}
+
 // GetAllPeers returns all peers string.
 func (r *Raft) GetAllPeers() []string {
 	return r.getAllPeers()
@@ -173,6 +235,26 @@ func (r *Raft) GetState() State {
 	return r.state
 }
 
+// GetMaintenance returns the maintenance of this node, nil if it's not in maintenance.
+func (r *Raft) GetMaintenance() *model.RaftMaintenance {
+	return r.getMaintenance()
+}
+
+// GetFreeze returns the failover freeze of this node, nil if the failover is not frozen.
+func (r *Raft) GetFreeze() *model.RaftFreeze {
+	return r.getFreeze()
+}
+
+// GetClusterFreeze returns the cluster freeze this node knows, nil if it's not set.
+func (r *Raft) GetClusterFreeze() *model.RaftFreeze {
+	return r.getClusterFreeze()
+}
+
+// GetClusterID returns the cluster ID, empty before the cluster is bootstrapped.
+func (r *Raft) GetClusterID() string {
+	return r.getClusterID()
+}
+
 // GetRaftRPC returns RaftRPC.
 func (r *Raft) GetRaftRPC() *RaftRPC {
 	return &RaftRPC{r}
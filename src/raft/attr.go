@@ -49,6 +49,10 @@ const (
 
 	// UNKNOW state.
 	UNKNOW
+
+	// WITNESS state.
+	// votes with the GTID carried in the requests, but runs no MySQL and never becomes CANDIDATE
+	WITNESS
 )
 
 func (s State) String() string {
@@ -67,6 +71,8 @@ func (s State) String() string {
 		return "LEARNER"
 	case 1 << 6:
 		return "STOPPED"
+	case 1 << 8:
+		return "WITNESS"
 	}
 	return "UNKNOW"
 }
@@ -83,6 +89,12 @@ const (
 
 	// MsgRaftPing type.
 	MsgRaftPing
+
+	// MsgRaftPreVote type.
+	MsgRaftPreVote
+
+	// MsgRaftDRPromote type.
+	MsgRaftDRPromote
 )
 
 var (
@@ -96,8 +108,16 @@ func (r *Raft) getState() State {
 }
 
 func (r *Raft) setState(state State) {
+	// only Start leaves STOPPED, an async degrade finished after Stop must not restart the state loop
+	if r.state == STOPPED {
+		return
+	}
 	r.setLeader(noLeader)
+	from := r.state
 	r.state = state
+	if from != state {
+		r.emitStateChange(from, state)
+	}
 }
 
 func (r *Raft) getID() string {
@@ -122,10 +142,33 @@ func (r *Raft) getPeers() []string {
 	return r.meta.Peers
 }
 
+func (r *Raft) getNextPeers() []string {
+	return r.meta.NextPeers
+}
+
 func (r *Raft) getIdlePeers() []string {
 	return r.meta.IdlePeers
 }
 
+func (r *Raft) getWitnesses() []string {
+	return r.meta.Witnesses
+}
+
+// isWitnessPeer returns true if the member is a WITNESS
+func (r *Raft) isWitnessPeer(id string) bool {
+	for _, witness := range r.meta.Witnesses {
+		if witness == id {
+			return true
+		}
+	}
+	return false
+}
+
+// all members include me and exclude idle and witness nodes
+func (r *Raft) getDataMembers() int {
+	return len(r.meta.Peers) - len(r.meta.Witnesses)
+}
+
 func (r *Raft) getAllPeers() []string {
 	allPeers := r.meta.Peers
 	allPeers = append(allPeers, r.meta.IdlePeers...)
@@ -133,11 +176,27 @@ func (r *Raft) getAllPeers() []string {
 }
 
 func (r *Raft) getElectionTimeout() int {
-	return r.conf.ElectionTimeout
+	return r.getTimings().ElectionTimeout
+}
+
+func (r *Raft) getPriority() int {
+	return r.conf.Priority
+}
+
+func (r *Raft) getZone() string {
+	return r.conf.Zone
 }
 
 func (r *Raft) getHeartbeatTimeout() int {
-	return r.conf.HeartbeatTimeout
+	return r.getTimings().HeartbeatTimeout
+}
+
+func (r *Raft) getAdmitDefeatHtCnt() int {
+	return r.getTimings().AdmitDefeatHtCnt
+}
+
+func (r *Raft) getPurgeBinlogInterval() int {
+	return r.getTimings().PurgeBinlogInterval
 }
 
 func (r *Raft) incViewID() {
@@ -149,13 +208,20 @@ func (r *Raft) getViewID() uint64 {
 }
 
 func (r *Raft) incEpochID() {
-	atomic.AddUint64(&r.meta.EpochID, 1)
+	epochid := atomic.AddUint64(&r.meta.EpochID, 1)
+	r.emitEpochChange(epochid-1, epochid)
 }
 
 func (r *Raft) getEpochID() uint64 {
 	return atomic.LoadUint64(&r.meta.EpochID)
 }
 
+// setVotedFor persists the vote before it is granted, so we never vote twice in one view after restart.
+func (r *Raft) setVotedFor(votedFor string) {
+	r.votedFor = votedFor
+	r.writeMeta()
+}
+
 func (r *Raft) getGTID() model.GTID {
 	return r.gtid
 }
@@ -165,5 +231,11 @@ func (r *Raft) getLeader() string {
 }
 
 func (r *Raft) setLeader(leader string) {
+	if leader != noLeader {
+		if r.lastLeader != "" && r.lastLeader != leader {
+			r.recordLeaderChange(r.lastLeader, leader)
+		}
+		r.lastLeader = leader
+	}
 	r.leader = leader
 }
@@ -51,7 +51,7 @@ func NewCandidate(r *Raft) *Candidate {
 //                   get majority votes
 // State1. CANDIDATE ------------------------> LEADER
 //
-//                   higher viewid/new leader
+//                   higher viewid/new leader/prevote fail
 // State2. CANDIDATE ------------------------> FOLLOWER
 //
 //                   timeout
@@ -60,17 +60,18 @@ func NewCandidate(r *Raft) *Candidate {
 func (r *Candidate) Loop() {
 	r.stateInit()
 	defer r.stateExit()
-
-	// reset timeout
-	r.resetElectionTimeout()
-	r.resetCheckVotesTimeout()
+	// the leader elected by the transfer keeps the mark, its heartbeats tell the members the change is planned
+	defer func() {
+		if r.getState() != LEADER {
+			r.setTransferCampaign(false)
+		}
+	}()
 
 	// broadcast voterequest
-	voteGranted := 1
-	respChan := make(chan *model.RaftRPCResponse, r.getAllMembers())
-	r.sendRequestVoteHandler(respChan)
+	voteGranted, granted, respChan := r.startElection()
 
 	switchMaster := false
+	var preVote *preVoteRound
 
 	for r.getState() == CANDIDATE {
 		select {
@@ -79,7 +80,8 @@ func (r *Candidate) Loop() {
 		case <-r.checkVotesTick.C:
 			// in one checkvotes timeout,
 			// if we granted majority votes and no **DENY**, we are the winner
-			if voteGranted >= r.getQuorums() {
+			// during a membership change, we need the majority of the NextPeers too
+			if voteGranted >= r.getQuorums() && r.isNextQuorum(granted) {
 				r.WARNING("get.enough.votes[%v]/members[%v].become.leader", voteGranted, r.getMembers())
 
 				// upgrade to LEADER
@@ -87,18 +89,35 @@ func (r *Candidate) Loop() {
 			}
 			r.resetCheckVotesTimeout()
 		case <-r.electionTick.C:
-			voteGranted = 1
-			// broadcast voterequest
-			respChan = make(chan *model.RaftRPCResponse, r.getAllMembers())
-			r.sendRequestVoteHandler(respChan)
-
-			// reset timeout
-			r.resetCheckVotesTimeout()
+			// only the majority would vote for us, we can bump the viewid again
+			// the prevote responses are collected below, the loop keeps serving meanwhile
 			r.resetElectionTimeout()
+			if preVote != nil {
+				break
+			}
+			// the transfer campaign has one election, then we campaign as usual
+			r.setTransferCampaign(false)
+			if preVote = r.startPreVote(); preVote == nil || preVote.pending == 0 {
+				if r.endPreVote(preVote) {
+					voteGranted, granted, respChan = r.startElection()
+				}
+				preVote = nil
+			}
+		case rsp := <-preVote.responses():
+			if r.processPreVoteResponse(preVote, rsp) {
+				if r.endPreVote(preVote) {
+					voteGranted, granted, respChan = r.startElection()
+				}
+				preVote = nil
+			}
 		case rsp := <-respChan:
+			votes := voteGranted
 			r.processRequestVoteResponseHandler(&voteGranted, rsp, &switchMaster)
+			if voteGranted > votes {
+				granted[rsp.GetFrom()] = true
+			}
 			members := r.getMembers()
-			if voteGranted == members {
+			if voteGranted == members && r.isNextQuorum(granted) {
 				r.WARNING("grants.unanimous.votes[%v]/members[%v].become.leader", voteGranted, members)
 
 				// upgrade to LEADER
@@ -122,6 +141,14 @@ func (r *Candidate) Loop() {
 				req := e.request.(*model.RaftRPCRequest)
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
+
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
 			default:
 				r.ERROR("get.unknown.request[%v]", e.Type)
 			}
@@ -129,6 +156,27 @@ func (r *Candidate) Loop() {
 	}
 }
 
+// startElection broadcasts the voterequest and resets the timeouts, returns the granted votes and the response channel.
+func (r *Candidate) startElection() (int, map[string]bool, chan *model.RaftRPCResponse) {
+	respChan := make(chan *model.RaftRPCResponse, r.getAllMembers())
+	r.sendRequestVoteHandler(respChan)
+
+	// reset timeout
+	r.resetCheckVotesTimeout()
+	r.resetElectionTimeout()
+	return 1, map[string]bool{r.getID(): true}, respChan
+}
+
+// endPreVote returns true if the answered prevote is granted, otherwise degrades to FOLLOWER.
+func (r *Candidate) endPreVote(preVote *preVoteRound) bool {
+	if preVote != nil && r.preVoteGranted(preVote) {
+		return true
+	}
+	r.WARNING("prevote.fail.degrade.to.follower")
+	r.degradeToFollower(degradePreVoteFail)
+	return false
+}
+
 // candidateProcessHeartbeatRequest
 // EFFECT
 // handles the heartbeat request from the leader
@@ -146,6 +194,8 @@ func (r *Candidate) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Ra
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -162,7 +212,7 @@ func (r *Candidate) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Ra
 		r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].down.to.follower", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 
 		// just down to FOLLOWER
-		r.degradeToFollower()
+		r.degradeToFollower(degradeLeaderHeartbeat)
 	}
 	return rsp
 }
@@ -178,13 +228,17 @@ func (r *Candidate) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Ra
 // 1. ErrorInvalidRequest: the request.From is not a member of this cluster
 // 2. ErrorInvalidViewID: request viewid is old
 // 3. ErrorInvalidGTID: the CANDIDATE has the smaller Read_Master_Log_Pos
-// 4. OK: give a vote
+// 4. ErrorLowerPriority: the CANDIDATE has the same GTID but the lower priority
+// 5. ErrorInvalidEpochID: the CANDIDATE has the stale membership epoch
+// 6. OK: give a vote
 func (r *Candidate) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
 	rsp := model.NewRaftRPCResponse(model.OK)
 	rsp.Raft.From = r.getID()
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -199,11 +253,17 @@ func (r *Candidate) processRequestVoteRequest(req *model.RaftRPCRequest) *model.
 			rsp.RetCode = model.ErrorInvalidViewID
 			return rsp
 		}
+
+		if !r.checkRequestEpoch(req) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.epochid[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getEpochID())
+			rsp.RetCode = model.ErrorInvalidEpochID
+			return rsp
+		}
 	}
 
 	// 2. check GTID
 	{
-		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID)
+		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID, r.conf.GTIDTieBreak)
 		if err != nil {
 			r.ERROR("process.requestvote.get.gtid.error[%v].ret.ErrorMySQLDown", err)
 			rsp.RetCode = model.ErrorMySQLDown
@@ -225,6 +285,13 @@ func (r *Candidate) processRequestVoteRequest(req *model.RaftRPCRequest) *model.
 				return rsp
 			}
 		}
+
+		// 2. I am promotable: GTID equal with you but my priority is higher
+		if r.isLowerPriority(req, &thisGTID) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v, P:%v].lower.priority", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.Raft.Priority)
+			rsp.RetCode = model.ErrorLowerPriority
+			return rsp
+		}
 	}
 
 	// 3. check viewid(req.viewid > thisnode.viewid)
@@ -234,7 +301,7 @@ func (r *Candidate) processRequestVoteRequest(req *model.RaftRPCRequest) *model.
 	{
 		if req.GetViewID() > r.getViewID() {
 			r.updateView(req.GetViewID(), noLeader)
-			r.degradeToFollower()
+			r.degradeToFollower(degradeHigherViewID)
 		} else {
 			if (r.votedFor != noVote) && (r.votedFor != req.GetFrom()) {
 				r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].already.vote.for[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.votedFor)
@@ -245,11 +312,11 @@ func (r *Candidate) processRequestVoteRequest(req *model.RaftRPCRequest) *model.
 	}
 
 	// 4. voted for this candidate
-	r.votedFor = req.GetFrom()
+	r.setVotedFor(req.GetFrom())
 	r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].vote.for.this.candidate", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 
 	// 5. a loser
-	r.degradeToFollower()
+	r.degradeToFollower(degradeVotedForOther)
 	return rsp
 }
 
@@ -259,6 +326,9 @@ func (r *Candidate) sendRequestVote(respChan chan *model.RaftRPCResponse) {
 
 	r.incViewID()
 	r.votedFor = noVote
+	r.writeMeta()
+	// the voters reset their election timeout after this, the leader lease starts from here
+	r.renewLease(r.clock.Now())
 	for _, peer := range r.peers {
 		r.wg.Add(1)
 		go func(peer *Peer) {
@@ -286,11 +356,19 @@ func (r *Candidate) processRequestVoteResponse(voteGranted *int, rsp *model.Raft
 	case model.ErrorInvalidViewID:
 		r.WARNING("get.vote.response.from[N:%v, V:%v].fail[ErrorInvalidViewID].downgrade.to.follower", rsp.GetFrom(), rsp.GetViewID())
 		r.updateView(rsp.GetViewID(), noLeader)
-		r.degradeToFollower()
+		r.degradeToFollower(degradeInvalidViewID)
 		return
 	case model.ErrorInvalidGTID:
 		r.WARNING("get.vote.response.from[N:%v, V:%v].deny[ErrorInvalidGTID].downgrade.to.follower", rsp.GetFrom(), rsp.GetViewID())
-		r.degradeToFollower()
+		r.degradeToFollower(rsp.RetCode)
+		return
+	case model.ErrorLowerPriority:
+		r.WARNING("get.vote.response.from[N:%v, V:%v].deny[ErrorLowerPriority].downgrade.to.follower", rsp.GetFrom(), rsp.GetViewID())
+		r.degradeToFollower(rsp.RetCode)
+		return
+	case model.ErrorInvalidEpochID:
+		r.WARNING("get.vote.response.from[N:%v, V:%v, E:%v].deny[ErrorInvalidEpochID].downgrade.to.follower", rsp.GetFrom(), rsp.GetViewID(), rsp.GetEpochID())
+		r.degradeToFollower(rsp.RetCode)
 		return
 	case model.ErrorMySQLDown:
 		peers := r.getMembers()
@@ -300,7 +378,7 @@ func (r *Candidate) processRequestVoteResponse(voteGranted *int, rsp *model.Raft
 			if *switchMaster {
 				*voteGranted++
 			} else {
-				time.Sleep(time.Duration(r.conf.CandidateWaitFor2Nodes) * time.Millisecond)
+				r.clock.Sleep(time.Duration(r.conf.CandidateWaitFor2Nodes) * time.Millisecond)
 				*switchMaster = true
 			}
 		}
@@ -318,6 +396,8 @@ func (r *Candidate) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPC
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
@@ -326,11 +406,16 @@ func (r *Candidate) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPC
 // 2. start the vip for public rafts
 func (r *Candidate) upgradeToLeader() {
 	r.setState(LEADER)
+	r.oldLeader = r.lastLeader
+	if r.isTransferCampaign() {
+		r.setPlannedLeader(r.getID())
+	}
 	r.setLeader(r.getID())
 	r.IncLeaderPromotes()
 }
 
-func (r *Candidate) degradeToFollower() {
+func (r *Candidate) degradeToFollower(reason string) {
+	r.emitDegrade(FOLLOWER, reason)
 	r.setState(FOLLOWER)
 }
 
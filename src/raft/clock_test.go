@@ -0,0 +1,146 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockDegradeReason(raft *Raft) string {
+	reason := ""
+	for _, event := range raft.GetHistory(0) {
+		if event.Type == EventDegrade {
+			reason = event.Reason
+		}
+	}
+	return reason
+}
+
+// TEST EFFECTS:
+// test the election on the fake clocks, only the raft whose clock advances can be the leader.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts with the fake clocks
+// 2. advance the clock of rafts[0], it wins the election
+// 3. the others are FOLLOWER of rafts[0] without advancing their clocks
+func TestRaftFakeClockElection(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, clocks, cleanup := MockRaftsWithFakeClock(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts with the fake clocks
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. advance the clock of rafts[0], it wins the election
+	leader := rafts[0]
+	won := MockAdvanceUntil(clocks[0], 5*time.Second, func() bool {
+		return leader.getState() == LEADER && rafts[1].getLeader() == leader.getID() && rafts[2].getLeader() == leader.getID()
+	})
+	assert.True(t, won)
+
+	// 3. the others are FOLLOWER of rafts[0] without advancing their clocks
+	for _, raft := range rafts[1:] {
+		assert.Equal(t, FOLLOWER, raft.getState())
+		assert.Equal(t, leader.getViewID(), raft.getViewID())
+		assert.Equal(t, uint64(0), raft.getStats().CandidatePromotes)
+	}
+	assert.Equal(t, uint64(1), leader.getStats().LeaderPromotes)
+}
+
+// TEST EFFECTS:
+// test the leader degrades on the fake clock when it loses the majority.
+//
+// TEST PROCESSES:
+// 1. rafts[0] wins the election
+// 2. stop the followers, the lease expires before the lessHtAcks
+// 3. restart with the lease disabled, the leader degrades after maxLessHtAcks heartbeats
+func TestRaftFakeClockDegrade(t *testing.T) {
+	for _, lease := range []bool{true, false} {
+		log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+		port := common.RandomPort(8000, 9000)
+		_, rafts, clocks, cleanup := MockRaftsWithFakeClock(log, port, 3, -1)
+		leader := rafts[0]
+		if !lease {
+			conf := *leader.conf
+			conf.LeaderLeaseTimeout = 0
+			leader.conf = &conf
+		}
+
+		// 1. rafts[0] wins the election
+		for _, raft := range rafts {
+			raft.Start()
+		}
+		assert.True(t, MockAdvanceUntil(clocks[0], 5*time.Second, func() bool {
+			return leader.getState() == LEADER
+		}))
+		MockAdvance(clocks[0], time.Duration(leader.getHeartbeatTimeout())*time.Millisecond)
+
+		// 2. stop the followers, the lease expires before the lessHtAcks
+		// 3. restart with the lease disabled, the leader degrades after maxLessHtAcks heartbeats
+		rafts[1].Stop()
+		rafts[2].Stop()
+		degraded := func() bool {
+			return leader.getState() == FOLLOWER
+		}
+		heartbeat := time.Duration(leader.getHeartbeatTimeout()) * time.Millisecond
+		if lease {
+			assert.True(t, MockAdvanceUntil(clocks[0], time.Duration(leader.getLeaseTimeout())*time.Millisecond+heartbeat, degraded))
+			assert.Equal(t, degradeLeaseExpired, mockDegradeReason(leader))
+		} else {
+			heartbeats := leader.conf.AdmitDefeatHtCnt
+			MockAdvance(clocks[0], time.Duration(heartbeats-1)*heartbeat)
+			assert.Equal(t, LEADER, leader.getState())
+			// the heartbeat ticks may come later but never earlier on the fake clock
+			assert.True(t, MockAdvanceUntil(clocks[0], time.Duration(heartbeats)*heartbeat, degraded))
+			assert.True(t, leader.getStats().LessHearbeatAcks >= uint64(heartbeats))
+			assert.Equal(t, degradeLessHtAcks, mockDegradeReason(leader))
+		}
+		cleanup()
+	}
+}
+
+// TEST EFFECTS:
+// test the FOLLOWER which can't ping the majority never becomes CANDIDATE.
+//
+// TEST PROCESSES:
+// 1. rafts[0] wins the election
+// 2. stop rafts[0] and rafts[2], rafts[1] is alone
+// 3. advance the clock of rafts[1] for many election timeouts, it stays FOLLOWER
+func TestRaftFakeClockBrainSplit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, clocks, cleanup := MockRaftsWithFakeClock(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. rafts[0] wins the election
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	assert.True(t, MockAdvanceUntil(clocks[0], 5*time.Second, func() bool {
+		return rafts[0].getState() == LEADER && rafts[1].getLeader() == rafts[0].getID()
+	}))
+
+	// 2. stop rafts[0] and rafts[2], rafts[1] is alone
+	rafts[0].Stop()
+	rafts[2].Stop()
+
+	// 3. advance the clock of rafts[1] for many election timeouts, it stays FOLLOWER
+	MockAdvance(clocks[1], time.Duration(10*rafts[1].getElectionTimeout())*time.Millisecond)
+	assert.Equal(t, FOLLOWER, rafts[1].getState())
+	assert.True(t, rafts[1].isBrainSplit)
+	assert.Equal(t, uint64(0), rafts[1].getStats().CandidatePromotes)
+}
@@ -0,0 +1,93 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// clusterFreezeFile is the file for storing the cluster freeze this node knows
+	clusterFreezeFile = "raft.cluster.freeze.json"
+)
+
+// recoverClusterFreeze restores the cluster freeze from the cluster freeze file,
+// so a node never becomes CANDIDATE after restart before it hears from the leader.
+func (r *Raft) recoverClusterFreeze() {
+	freezePath := filepath.Join(r.conf.MetaDatadir, clusterFreezeFile)
+	if r.clusterFreeze = r.readFreezeFile(freezePath); r.clusterFreeze != nil {
+		r.WARNING("recovery.cluster.freeze.from[%v].freeze[%+v]", freezePath, *r.clusterFreeze)
+	}
+}
+
+// getClusterFreeze returns the cluster freeze, nil if it's not set or expired.
+func (r *Raft) getClusterFreeze() *model.RaftFreeze {
+	r.failoverMutex.Lock()
+	freeze := r.clusterFreeze
+	r.failoverMutex.Unlock()
+
+	if freeze == nil || freeze.Until == 0 || r.clock.Now().UnixNano()/int64(time.Millisecond) < freeze.Until {
+		return freeze
+	}
+	r.WARNING("cluster.freeze[%+v].expired", *freeze)
+	if err := r.setClusterFreeze(nil, "expired"); err != nil {
+		r.ERROR("cluster.freeze.expired.remove.file.error[%+v]", err)
+	}
+	return nil
+}
+
+// isClusterFrozen returns true if the cluster freeze is set, the automatic failover is disabled.
+func (r *Raft) isClusterFrozen() bool {
+	return r.getClusterFreeze() != nil
+}
+
+// setClusterFreeze sets the cluster freeze and persists it, nil clears it, the reason shows in the event.
+func (r *Raft) setClusterFreeze(freeze *model.RaftFreeze, reason string) error {
+	r.failoverMutex.Lock()
+	old := r.clusterFreeze
+	if (old == nil && freeze == nil) || (old != nil && freeze != nil && *old == *freeze) {
+		r.failoverMutex.Unlock()
+		return nil
+	}
+	if err := writeFreezeFile(filepath.Join(r.conf.MetaDatadir, clusterFreezeFile), freeze); err != nil {
+		r.failoverMutex.Unlock()
+		return err
+	}
+	r.clusterFreeze = freeze
+	r.failoverMutex.Unlock()
+
+	event := model.RaftEvent{Type: EventClusterFreeze, From: "on", To: "off", Reason: reason}
+	if old != nil {
+		event.Peer = old.By
+	}
+	if freeze != nil {
+		r.WARNING("cluster.freeze.set[%+v]", *freeze)
+		event.From, event.To, event.Peer = "off", "on", freeze.By
+		if old != nil {
+			event.From = "on"
+		}
+	} else {
+		r.WARNING("cluster.freeze.cleared.reason[%v]", reason)
+	}
+	r.emit(event)
+	return nil
+}
+
+// updateClusterFreeze follows the cluster freeze the leader carries in the heartbeat.
+func (r *Raft) updateClusterFreeze(leader string, freeze *model.RaftFreeze) {
+	reason := "cleared.by.leader"
+	if freeze != nil {
+		reason = freeze.Reason
+	}
+	if err := r.setClusterFreeze(freeze, reason); err != nil {
+		r.ERROR("update.cluster.freeze.from.leader[%v].error[%+v]", leader, err)
+	}
+}
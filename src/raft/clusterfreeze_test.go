@@ -0,0 +1,129 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the cluster freeze is carried by the leader heartbeats and stops the automatic failover, the manual switchover still works.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, wait the leader eggs
+// 2. freeze on the follower is refused, freeze on the leader
+// 3. the followers get the cluster freeze from the heartbeats
+// 4. stop the leader, no leader eggs
+// 5. the manual switchover still works, the new leader carries the freeze
+// 6. unfreeze, the cluster freeze is cleared
+// 7. the cluster freeze expires
+func TestRaftClusterFreeze(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, wait the leader eggs
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	heartbeat := time.Duration(leader.getHeartbeatTimeout()) * time.Millisecond
+
+	// 2. freeze on the follower is refused, freeze on the leader
+	{
+		c, cleanup := MockGetClient(t, names[(whoisleader+1)%3])
+		defer cleanup()
+
+		method := model.RPCRaftFreeze
+		req := model.NewRaftFreezeRPCRequest()
+		rsp := model.NewRaftFreezeRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotLeader, rsp.RetCode)
+		assert.Equal(t, names[whoisleader], rsp.Leader)
+	}
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCRaftFreeze
+		req := model.NewRaftFreezeRPCRequest()
+		req.From = "operator:8801"
+		req.Reason = "network.incident"
+		rsp := model.NewRaftFreezeRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, "operator:8801", rsp.ClusterFreeze.By)
+	}
+
+	// 3. the followers get the cluster freeze from the heartbeats
+	time.Sleep(heartbeat * 3)
+	for _, raft := range rafts {
+		freeze := raft.getClusterFreeze()
+		assert.NotNil(t, freeze)
+		if freeze != nil {
+			assert.Equal(t, "operator:8801", freeze.By)
+			assert.Equal(t, "network.incident", freeze.Reason)
+			assert.Equal(t, int64(0), freeze.Until)
+		}
+	}
+
+	// 4. stop the leader, no leader eggs
+	running := mockStopLeader(rafts)
+	electionTimeout := time.Duration(leader.getElectionTimeout()) * time.Millisecond
+	assert.True(t, mockWaitNoLeader(running, electionTimeout*3))
+
+	// 5. the manual switchover still works, the new leader carries the freeze
+	{
+		rsp := model.NewHARPCResponse(model.OK)
+		assert.Nil(t, running[0].GetHARPC().HATryToLeader(model.NewHARPCRequest(), rsp))
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.NotEqual(t, -1, MockWaitLeaderEggs(running, 1))
+		assert.Equal(t, LEADER, running[0].getState())
+		time.Sleep(heartbeat * 3)
+		for _, raft := range running {
+			assert.NotNil(t, raft.getClusterFreeze())
+		}
+	}
+
+	// 6. unfreeze, the cluster freeze is cleared
+	for _, raft := range running {
+		rsp := model.NewRaftStatusRPCResponse(model.OK)
+		assert.Nil(t, raft.GetRaftRPC().Unfreeze(model.NewRaftStatusRPCRequest(), rsp))
+		assert.Equal(t, model.OK, rsp.RetCode)
+	}
+	time.Sleep(heartbeat * 3)
+	for _, raft := range running {
+		assert.Nil(t, raft.getClusterFreeze())
+	}
+
+	// 7. the cluster freeze expires
+	{
+		req := model.NewRaftFreezeRPCRequest()
+		req.Until = time.Now().Add(heartbeat*5).UnixNano() / int64(time.Millisecond)
+		rsp := model.NewRaftFreezeRPCResponse(model.OK)
+		assert.Nil(t, running[0].GetRaftRPC().Freeze(req, rsp))
+		assert.Equal(t, model.OK, rsp.RetCode)
+		time.Sleep(heartbeat * 3)
+		assert.NotNil(t, running[1].getClusterFreeze())
+		time.Sleep(heartbeat * 5)
+		for _, raft := range running {
+			assert.Nil(t, raft.getClusterFreeze())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"crypto/rand"
+	"fmt"
+	"model"
+)
+
+// newClusterID returns a random(version 4) UUID.
+func newClusterID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// getClusterID returns the cluster ID, empty before the cluster is bootstrapped.
+func (r *Raft) getClusterID() string {
+	r.clusterIDMutex.RLock()
+	defer r.clusterIDMutex.RUnlock()
+	return r.meta.ClusterID
+}
+
+// setClusterID sets the cluster ID if we have none, and persists it in the meta.
+func (r *Raft) setClusterID(id string, reason string) {
+	r.clusterIDMutex.Lock()
+	if r.meta.ClusterID != "" {
+		r.clusterIDMutex.Unlock()
+		return
+	}
+	r.meta.ClusterID = id
+	r.clusterIDMutex.Unlock()
+
+	r.WARNING("cluster.id.set.to[%v].reason[%v]", id, reason)
+	r.writeMeta()
+}
+
+// bootstrapClusterID creates the cluster ID when the first leader of the cluster is elected.
+func (r *Raft) bootstrapClusterID() {
+	if r.getClusterID() != "" {
+		return
+	}
+	id, err := newClusterID()
+	if err != nil {
+		r.ERROR("cluster.id.create.error[%v]", err)
+		return
+	}
+	r.setClusterID(id, "bootstrap")
+}
+
+// adoptClusterID follows the cluster ID of the leader if we have none, e.g. the new member or the upgraded one.
+func (r *Raft) adoptClusterID(leader string, id string) {
+	if id == "" || r.getClusterID() != "" {
+		return
+	}
+	r.setClusterID(id, fmt.Sprintf("leader[%v]", leader))
+}
+
+// checkClusterID returns false if the message comes from a foreign cluster,
+// the messages without cluster ID are accepted, the nodes learn it from the leader.
+func (r *Raft) checkClusterID(from string, id string) bool {
+	mine := r.getClusterID()
+	if id == "" || mine == "" || id == mine {
+		return true
+	}
+	r.ERROR("message.from[%v].of.foreign.cluster[%v].we.are.cluster[%v].rejected.the.endpoint.may.be.reused.by.another.xenon.cluster", from, id, mine)
+	return false
+}
+
+// checkClusterIDRequest returns the ErrorClusterIDMismatch response if the request comes from a foreign cluster, nil if accepted.
+func (r *Raft) checkClusterIDRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	if r.checkClusterID(req.GetFrom(), req.Raft.ClusterID) {
+		return nil
+	}
+	rsp := model.NewRaftRPCResponse(model.ErrorClusterIDMismatch)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.getState().String()
+	rsp.Raft.ClusterID = r.getClusterID()
+	return rsp
+}
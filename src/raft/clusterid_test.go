@@ -0,0 +1,112 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the cluster ID is created by the first leader and the messages of a foreign cluster are rejected.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, wait the leader eggs, the leader creates the cluster ID and the followers take it
+// 2. the heartbeat of a foreign cluster is rejected
+// 3. a follower is of a foreign cluster, it never takes the leadership
+// 4. the ping of the foreign follower is rejected
+func TestRaftClusterID(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, wait the leader eggs, the leader creates the cluster ID and the followers take it
+	for _, raft := range rafts {
+		assert.Equal(t, "", raft.getClusterID())
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	heartbeat := time.Duration(leader.getHeartbeatTimeout()) * time.Millisecond
+	time.Sleep(heartbeat * 3)
+	clusterID := leader.getClusterID()
+	assert.Equal(t, 36, len(clusterID))
+	for _, raft := range rafts {
+		assert.Equal(t, clusterID, raft.getClusterID())
+	}
+	meta, err := readMetaJSON(filepath.Join(leader.conf.MetaDatadir, metaFile))
+	assert.Nil(t, err)
+	assert.Equal(t, clusterID, meta.ClusterID)
+
+	// 2. the heartbeat of a foreign cluster is rejected
+	follower := (whoisleader + 1) % 3
+	{
+		c, cleanup := MockGetClient(t, names[follower])
+		defer cleanup()
+
+		method := model.RPCRaftHeartbeat
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = names[whoisleader]
+		req.Raft.ViewID = leader.getViewID() + 1
+		req.Raft.EpochID = leader.getEpochID()
+		req.Raft.ClusterID = "foreign"
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorClusterIDMismatch, rsp.RetCode)
+		assert.Equal(t, clusterID, rsp.Raft.ClusterID)
+		assert.Equal(t, FOLLOWER, rafts[follower].getState())
+	}
+
+	// 3. a follower is of a foreign cluster, it never takes the leadership
+	foreign := rafts[follower]
+	foreign.clusterIDMutex.Lock()
+	foreign.meta.ClusterID = "foreign"
+	foreign.clusterIDMutex.Unlock()
+	electionTimeout := time.Duration(leader.getElectionTimeout()) * time.Millisecond
+	time.Sleep(electionTimeout * 4)
+	assert.Equal(t, LEADER, leader.getState())
+	assert.NotEqual(t, LEADER, foreign.getState())
+	assert.Equal(t, clusterID, leader.getClusterID())
+	assert.Equal(t, "foreign", foreign.getClusterID())
+
+	// 4. the ping of the foreign follower is rejected
+	{
+		foreign.mutex.RLock()
+		peer := foreign.peers[names[whoisleader]]
+		foreign.mutex.RUnlock()
+		c := make(chan *model.RaftRPCResponse, 1)
+		peer.SendPing(c)
+		rsp := <-c
+		assert.Equal(t, model.ErrorClusterIDMismatch, rsp.RetCode)
+		assert.Equal(t, clusterID, rsp.Raft.ClusterID)
+	}
+
+	// 5. the prevote of the foreign follower is rejected, the priority and zone of the rsp are not learned
+	{
+		foreign.mutex.RLock()
+		peer := foreign.peers[names[whoisleader]]
+		foreign.mutex.RUnlock()
+		peer.setPriority(42)
+		foreign.updatePeerZone(names[whoisleader], "foreign-zone")
+		c := make(chan *model.RaftRPCResponse, 1)
+		peer.sendPreVote(foreign.getGTID(), c)
+		rsp := <-c
+		assert.Equal(t, model.ErrorClusterIDMismatch, rsp.RetCode)
+		assert.Equal(t, 42, peer.getPriority())
+		assert.Equal(t, "foreign-zone", foreign.getPeerZone(names[whoisleader]))
+	}
+}
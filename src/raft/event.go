@@ -0,0 +1,227 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// historyFile is the file for storing the raft event history
+	historyFile = "raft.history.json"
+
+	// historyVersion is the version of the history file format, bump it when the format changes.
+	historyVersion = 1
+)
+
+const (
+	// EventStateChange emits when the raft state changes.
+	EventStateChange = "state-change"
+
+	// EventVoteGranted emits when this raft grants the vote to a candidate.
+	EventVoteGranted = "vote-granted"
+
+	// EventVoteDenied emits when this raft denies the vote of a candidate, the reason is the error code.
+	EventVoteDenied = "vote-denied"
+
+	// EventDegrade emits when the LEADER or the CANDIDATE degrades, or the FOLLOWER degrades to INVALID.
+	EventDegrade = "degrade"
+
+	// EventEpochChange emits when the membership epoch changes.
+	EventEpochChange = "epoch-change"
+
+	// EventMaintenance emits when this raft enters or exits the maintenance, the reason is why.
+	EventMaintenance = "maintenance"
+
+	// EventFailoverFrozen emits when the failover limit is hit and the failover is frozen, it's the alert for the operator.
+	EventFailoverFrozen = "failover-frozen"
+
+	// EventFailoverUnfrozen emits when the operator unfreezes the failover.
+	EventFailoverUnfrozen = "failover-unfrozen"
+
+	// EventFence emits when the new leader fences the old leader, the reason is how or timeout.
+	EventFence = "fence"
+
+	// EventClusterFreeze emits when the cluster freeze is set or cleared on this raft, the reason is why.
+	EventClusterFreeze = "cluster-freeze"
+
+	// EventTimingsChange emits when the raft timings change at runtime.
+	EventTimingsChange = "timings-change"
+
+	// EventStandby emits when the standby leader changes its MySQL master to the primary leader.
+	EventStandby = "standby"
+
+	// EventDRPromote emits when the standby is detached from the primary and promoted, the reason is the last applied primary GTID.
+	EventDRPromote = "dr-promote"
+)
+
+// the degrade reasons
+const (
+	degradeLessHtAcks         = "lessHtAcks"
+	degradeMySQLDown          = "mysqlDown"
+	degradeLeaseExpired       = "leaseExpired"
+	degradeSameViewID         = "sameViewID"
+	degradeHigherViewID       = "higherViewID"
+	degradeInvalidViewID      = "invalidViewID"
+	degradeWaitUntilAfterGTID = "waitUntilAfterGTIDError"
+	degradePrePromoteHook     = "prePromoteHookAbort"
+	degradeChangeToMaster     = "changeToMasterError"
+	degradePreVoteFail        = "preVoteFail"
+	degradeLeaderHeartbeat    = "leaderHeartbeat"
+	degradeVotedForOther      = "votedForOther"
+	degradeLocalGTIDGreater   = "localGTIDGreater"
+	degradeTransferLeader     = "transferLeader"
+	degradeFenceTimeout       = "fenceTimeout"
+)
+
+// historyJSON is the on-disk format of the history file.
+type historyJSON struct {
+	Events []model.RaftEvent `json:"events"`
+}
+
+// Subscribe returns a channel which receives the raft events and a function to cancel the subscription.
+// the events are dropped if the channel is full, the raft state machine never waits for the subscribers.
+func (r *Raft) Subscribe(size int) (<-chan model.RaftEvent, func()) {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+
+	c := make(chan model.RaftEvent, size)
+	r.subscribers[c] = true
+	return c, func() {
+		r.eventMutex.Lock()
+		defer r.eventMutex.Unlock()
+		delete(r.subscribers, c)
+	}
+}
+
+// GetHistory returns the latest limit events in time order, 0 means all.
+func (r *Raft) GetHistory(limit int) []model.RaftEvent {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+
+	events := r.history
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return append([]model.RaftEvent{}, events...)
+}
+
+// emit records the event in the history and publishes it to the subscribers.
+func (r *Raft) emit(event model.RaftEvent) {
+	event.Time = r.clock.Now().UnixNano() / int64(time.Millisecond)
+	event.State = r.getState().String()
+	event.ViewID = r.getViewID()
+	event.EpochID = r.getEpochID()
+
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+
+	if size := r.conf.HistorySize; size > 0 {
+		r.history = append(r.history, event)
+		if n := len(r.history) - size; n > 0 {
+			r.history = append([]model.RaftEvent{}, r.history[n:]...)
+		}
+		r.persistHistory()
+	}
+
+	for c := range r.subscribers {
+		select {
+		case c <- event:
+		default:
+		}
+	}
+}
+
+func (r *Raft) emitStateChange(from State, to State) {
+	r.emit(model.RaftEvent{Type: EventStateChange, From: from.String(), To: to.String()})
+}
+
+func (r *Raft) emitDegrade(to State, reason string) {
+	r.emit(model.RaftEvent{Type: EventDegrade, From: r.getState().String(), To: to.String(), Reason: reason})
+}
+
+func (r *Raft) emitEpochChange(from uint64, to uint64) {
+	r.emit(model.RaftEvent{Type: EventEpochChange, From: fmt.Sprintf("%d", from), To: fmt.Sprintf("%d", to)})
+}
+
+// emitVote records the vote response to the candidate.
+func (r *Raft) emitVote(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) {
+	event := model.RaftEvent{Type: EventVoteGranted, Peer: req.GetFrom()}
+	if rsp.RetCode != model.OK {
+		event.Type = EventVoteDenied
+		event.Reason = rsp.RetCode
+	}
+	r.emit(event)
+}
+
+// recoverHistory restores the history from the history file.
+// the history is only for the diagnosis, a broken history file is ignored rather than keeping the node down.
+func (r *Raft) recoverHistory() {
+	historyPath := filepath.Join(r.conf.MetaDatadir, historyFile)
+	history := &historyJSON{}
+	ok, err := readVersionedJSON(historyPath, historyVersion, history)
+	if err != nil {
+		r.ERROR("read.history.file[%v].error[%+v].ignore.it", historyPath, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	r.history = history.Events
+	r.WARNING("recovery.history.from[%v].events[%v]", historyPath, len(r.history))
+}
+
+// persistHistory wakes up the history writer, the caller must hold the eventMutex.
+// the events are written in the background, so the disk latency never sits on the raft RPCs.
+func (r *Raft) persistHistory() {
+	r.historyDirty = true
+	if !r.historyWriting {
+		r.historyWriting = true
+		go r.writeHistoryLoop()
+	}
+}
+
+// writeHistoryLoop writes the history until there is nothing new,
+// the events emitted during a write are coalesced into the next one.
+func (r *Raft) writeHistoryLoop() {
+	for {
+		r.eventMutex.Lock()
+		if !r.historyDirty {
+			r.historyWriting = false
+			r.historyIdle.Broadcast()
+			r.eventMutex.Unlock()
+			return
+		}
+		r.historyDirty = false
+		events := append([]model.RaftEvent{}, r.history...)
+		r.eventMutex.Unlock()
+
+		r.writeHistory(events)
+	}
+}
+
+// waitHistory waits until the history writer exits, so the last events are on the disk.
+func (r *Raft) waitHistory() {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	for r.historyWriting {
+		r.historyIdle.Wait()
+	}
+}
+
+// writeHistory persists the events as the whole history.
+func (r *Raft) writeHistory(events []model.RaftEvent) {
+	historyPath := filepath.Join(r.conf.MetaDatadir, historyFile)
+	if err := writeVersionedJSON(historyPath, historyVersion, &historyJSON{Events: events}); err != nil {
+		r.ERROR("write.history.to[%v].error[%+v]", historyPath, err)
+	}
+}
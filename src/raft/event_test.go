@@ -0,0 +1,154 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHasEvent(events []model.RaftEvent, typ string, from string, to string) bool {
+	for _, event := range events {
+		if event.Type == typ && event.From == from && event.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TEST EFFECTS:
+// test the raft events are published and kept in the history.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, subscribe the events of rafts[0]
+// 2. wait the leader eggs, check the history of the leader and the followers
+// 3. stop the followers, the leader degrades with the reason
+// 4. check the subscriber got the state changes
+func TestRaftHistory(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, subscribe the events of rafts[0]
+	events, cancel := rafts[0].Subscribe(128)
+	defer cancel()
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs, check the history of the leader and the followers
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	{
+		history := leader.GetHistory(0)
+		assert.True(t, mockHasEvent(history, EventStateChange, FOLLOWER.String(), CANDIDATE.String()))
+		assert.True(t, mockHasEvent(history, EventStateChange, CANDIDATE.String(), LEADER.String()))
+		assert.Equal(t, 1, len(leader.GetHistory(1)))
+	}
+	for i, raft := range rafts {
+		if i == whoisleader {
+			continue
+		}
+		granted := false
+		for _, event := range raft.GetHistory(0) {
+			if event.Type == EventVoteGranted && event.Peer == leader.getID() {
+				granted = true
+			}
+		}
+		assert.True(t, granted)
+	}
+
+	// 3. stop the followers, the leader degrades with the reason
+	for i, raft := range rafts {
+		if i != whoisleader {
+			raft.Stop()
+		}
+	}
+	MockWaitLeaderEggs(rafts, 0)
+	MockWaitLeaderEggs(rafts, 0)
+	{
+		var degrade *model.RaftEvent
+		for _, event := range leader.GetHistory(0) {
+			if event.Type == EventDegrade {
+				e := event
+				degrade = &e
+			}
+		}
+		assert.NotNil(t, degrade)
+		assert.Equal(t, LEADER.String(), degrade.From)
+		assert.Equal(t, FOLLOWER.String(), degrade.To)
+		assert.Contains(t, []string{degradeLeaseExpired, degradeLessHtAcks}, degrade.Reason)
+	}
+
+	// 4. check the subscriber got the state changes
+	got := false
+	for !got {
+		select {
+		case event := <-events:
+			got = event.Type == EventStateChange && event.From == FOLLOWER.String()
+		case <-time.After(time.Second):
+			t.Fatal("subscriber.got.no.state.change")
+		}
+	}
+}
+
+// TEST EFFECTS:
+// test the history is bounded and survives the restart.
+//
+// TEST PROCESSES:
+// 1. emit more events than the history size
+// 2. stop and restart the raft with the same meta datadir
+// 3. the broken history file is ignored, the restart begins with the empty history
+func TestRaftHistoryRecover(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 1, -1)
+	defer cleanup()
+	raft := rafts[0]
+
+	dir, err := ioutil.TempDir("", "xenon-history")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	conf := *raft.conf
+	conf.MetaDatadir = dir
+	conf.HistorySize = 3
+	raft.conf = &conf
+	raft.Start()
+
+	// 1. emit more events than the history size
+	for i := 0; i < 5; i++ {
+		raft.incEpochID()
+	}
+	history := raft.GetHistory(0)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, EventEpochChange, history[2].Type)
+	assert.Equal(t, history[2].EpochID, raft.getEpochID())
+
+	// 2. stop and restart the raft with the same meta datadir
+	raft.Stop()
+	history = raft.GetHistory(0)
+	assert.Equal(t, EventStateChange, history[2].Type)
+	restarted := NewRaft(raft.getID(), &conf, 10000, log, raft.mysql, FOLLOWER)
+	assert.Equal(t, history, restarted.GetHistory(0))
+
+	// 3. the broken history file is ignored, the restart begins with the empty history
+	err = ioutil.WriteFile(filepath.Join(dir, historyFile), []byte(`inject`), 0644)
+	assert.Nil(t, err)
+	restarted = NewRaft(raft.getID(), &conf, 10000, log, raft.mysql, FOLLOWER)
+	assert.Equal(t, 0, len(restarted.GetHistory(0)))
+}
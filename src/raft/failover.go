@@ -0,0 +1,217 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// freezeFile is the file for storing the failover freeze of this node
+	freezeFile = "raft.freeze.json"
+
+	// freezeVersion is the version of the freeze file format, bump it when the format changes.
+	freezeVersion = 1
+)
+
+// recoverFreeze restores the failover freeze from the freeze file, so a restart never unfreezes the failover.
+func (r *Raft) recoverFreeze() {
+	freezePath := filepath.Join(r.conf.MetaDatadir, freezeFile)
+	if r.freeze = r.readFreezeFile(freezePath); r.freeze != nil {
+		r.WARNING("recovery.freeze.from[%v].freeze[%+v]", freezePath, *r.freeze)
+	}
+}
+
+// writeFreeze persists the failover freeze, nil removes the freeze file.
+// the caller must hold the failoverMutex.
+func (r *Raft) writeFreeze() error {
+	return writeFreezeFile(filepath.Join(r.conf.MetaDatadir, freezeFile), r.freeze)
+}
+
+// readFreezeFile returns the freeze in the file, nil if the file doesn't exist.
+func (r *Raft) readFreezeFile(freezePath string) *model.RaftFreeze {
+	freeze := &model.RaftFreeze{}
+	ok, err := readVersionedJSON(freezePath, freezeVersion, freeze)
+	if err != nil {
+		r.PANIC("read.freeze.file[%v].error[%+v]", freezePath, err)
+	}
+	if !ok {
+		return nil
+	}
+	return freeze
+}
+
+// writeFreezeFile persists the freeze to the file, nil removes the file.
+func writeFreezeFile(freezePath string, freeze *model.RaftFreeze) error {
+	if freeze == nil {
+		if err := os.Remove(freezePath); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+
+	return writeVersionedJSON(freezePath, freezeVersion, freeze)
+}
+
+// setPlannedLeader marks the leader elected by a leader transfer, the change to it is not counted.
+func (r *Raft) setPlannedLeader(leader string) {
+	r.failoverMutex.Lock()
+	defer r.failoverMutex.Unlock()
+	r.plannedLeader = leader
+}
+
+// recordLeaderChange records the leader change this node saw,
+// the planned change of the leader transfer and the maintenance drain is not counted.
+func (r *Raft) recordLeaderChange(from string, to string) {
+	now := r.clock.Now()
+
+	r.failoverMutex.Lock()
+	defer r.failoverMutex.Unlock()
+	if to == r.plannedLeader {
+		r.plannedLeader = ""
+		r.WARNING("leader.changed.from[%v].to[%v].is.planned.not.counted", from, to)
+		return
+	}
+	r.trimLeaderChanges(now)
+	r.leaderChanges = append(r.leaderChanges, now)
+	r.leaderChangeAt = now
+	r.WARNING("leader.changed.from[%v].to[%v].changes.in.failover.window[%v]", from, to, len(r.leaderChanges))
+}
+
+// trimLeaderChanges drops the leader changes out of the failover window, the caller must hold the failoverMutex.
+func (r *Raft) trimLeaderChanges(now time.Time) {
+	begin := now.Add(-time.Duration(r.conf.FailoverWindow) * time.Millisecond)
+	i := 0
+	for i < len(r.leaderChanges) && r.leaderChanges[i].Before(begin) {
+		i++
+	}
+	r.leaderChanges = r.leaderChanges[i:]
+}
+
+// getLeaderChanges returns the number of the leader changes in the failover window.
+func (r *Raft) getLeaderChanges() int {
+	r.failoverMutex.Lock()
+	defer r.failoverMutex.Unlock()
+	r.trimLeaderChanges(r.clock.Now())
+	return len(r.leaderChanges)
+}
+
+// getFreeze returns a copy of the failover freeze, nil if the failover is not frozen.
+func (r *Raft) getFreeze() *model.RaftFreeze {
+	r.failoverMutex.Lock()
+	defer r.failoverMutex.Unlock()
+	if r.freeze == nil {
+		return nil
+	}
+	freeze := *r.freeze
+	return &freeze
+}
+
+// isFailoverFrozen returns true if the failover is frozen or the failover-limit is hit in the failover window,
+// it's read-only, the voters check it and only the CANDIDATE freezes the failover by checkFailover.
+func (r *Raft) isFailoverFrozen() bool {
+	r.failoverMutex.Lock()
+	defer r.failoverMutex.Unlock()
+	if r.freeze != nil {
+		return true
+	}
+	r.trimLeaderChanges(r.clock.Now())
+	limit := r.conf.FailoverLimit
+	return limit > 0 && len(r.leaderChanges) >= limit
+}
+
+// checkFailover
+// EFFECT
+// checks whether this node may become the CANDIDATE.
+// if failover-limit leader changes were seen in the failover window, it freezes the failover,
+// raises the failover-frozen event and runs the on-failover-frozen hooks.
+//
+// RETURNS
+// 1. ErrorFailoverFrozen: the failover is frozen, no election until unfreeze
+// 2. ErrorFailoverCooldown: the last leader change is in the min-leader-tenure
+// 3. OK: a new election is allowed
+func (r *Raft) checkFailover() string {
+	now := r.clock.Now()
+
+	r.failoverMutex.Lock()
+	if r.freeze != nil {
+		r.failoverMutex.Unlock()
+		return model.ErrorFailoverFrozen
+	}
+
+	r.trimLeaderChanges(now)
+	var freeze *model.RaftFreeze
+	if limit := r.conf.FailoverLimit; limit > 0 && len(r.leaderChanges) >= limit {
+		freeze = &model.RaftFreeze{
+			Reason: fmt.Sprintf("%d.leader.changes.in.%dms", len(r.leaderChanges), r.conf.FailoverWindow),
+			Since:  now.UnixNano() / int64(time.Millisecond),
+		}
+		r.freeze = freeze
+		if err := r.writeFreeze(); err != nil {
+			r.ERROR("failover.frozen.write.freeze.file.error[%+v]", err)
+		}
+	}
+	tenure := time.Duration(r.conf.MinLeaderTenure) * time.Millisecond
+	cooldown := tenure > 0 && !r.leaderChangeAt.IsZero() && now.Sub(r.leaderChangeAt) < tenure
+	r.failoverMutex.Unlock()
+
+	if freeze != nil {
+		r.ERROR("failover.limit[%v].in.window[%vms].is.hit.freeze.the.failover[%+v]", r.conf.FailoverLimit, r.conf.FailoverWindow, *freeze)
+		r.emit(model.RaftEvent{Type: EventFailoverFrozen, Reason: freeze.Reason})
+		go func(lastLeader string) {
+			if err := r.runHooks(HookOnFailoverFrozen, lastLeader, noLeader); err != nil {
+				r.ERROR("on-failover-frozen.hooks.error[%v]", err)
+			}
+		}(r.lastLeader)
+		return model.ErrorFailoverFrozen
+	}
+	if cooldown {
+		return model.ErrorFailoverCooldown
+	}
+	return model.OK
+}
+
+// unfreeze clears the failover freeze and the leader changes seen, the failover window starts from scratch.
+func (r *Raft) unfreeze() error {
+	r.failoverMutex.Lock()
+	freeze := r.freeze
+	r.freeze = nil
+	if err := r.writeFreeze(); err != nil {
+		r.freeze = freeze
+		r.failoverMutex.Unlock()
+		return err
+	}
+	r.leaderChanges = nil
+	r.leaderChangeAt = time.Time{}
+	r.failoverMutex.Unlock()
+
+	if freeze != nil {
+		r.WARNING("failover.unfrozen.freeze[%+v]", *freeze)
+		r.emit(model.RaftEvent{Type: EventFailoverUnfrozen})
+	}
+	return nil
+}
+
+// frozenResponse returns the response which rejects the vote for the failover is frozen.
+func (r *Raft) frozenResponse() *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.ErrorFailoverFrozen)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.getState().String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
+	return rsp
+}
@@ -0,0 +1,222 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockWaitNoLeader waits for d and returns true if none of the running rafts is the leader.
+func mockWaitNoLeader(rafts []*Raft, d time.Duration) bool {
+	for end := time.Now().Add(d); time.Now().Before(end); {
+		for _, raft := range rafts {
+			if raft.getState() == LEADER {
+				return false
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
+// mockStopLeader stops the leader and returns the running rafts.
+func mockStopLeader(rafts []*Raft) []*Raft {
+	running := []*Raft{}
+	for _, raft := range rafts {
+		if raft.getState() == LEADER {
+			raft.Stop()
+			continue
+		}
+		running = append(running, raft)
+	}
+	return running
+}
+
+// TEST EFFECTS:
+// test the failover is frozen when the failover limit is hit, and unfrozen by the operator.
+//
+// TEST PROCESSES:
+// 1. Start 5 rafts, wait the leader eggs
+// 2. stop the leader, the new leader eggs, it's the 1st leader change
+// 3. set failover-limit 1, stop the leader again, the failover is frozen, no leader eggs
+// 4. unfreeze, the new leader eggs
+func TestRaftFailoverFreeze(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 5, -1)
+	defer cleanup()
+
+	// 1. Start 5 rafts, wait the leader eggs
+	rafts[0].conf.FailoverWindow = 1000 * 3600
+	defer func() { rafts[0].conf.FailoverLimit = 0 }()
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	MockWaitLeaderEggs(rafts, 1)
+
+	// 2. stop the leader, the new leader eggs, it's the 1st leader change
+	running := mockStopLeader(rafts)
+	assert.NotEqual(t, -1, MockWaitLeaderEggs(running, 1))
+	for _, raft := range running {
+		assert.Nil(t, raft.getFreeze())
+	}
+
+	// 3. set failover-limit 1, stop the leader again, the failover is frozen, no leader eggs
+	rafts[0].conf.FailoverLimit = 1
+	running = mockStopLeader(running)
+	electionTimeout := time.Duration(rafts[0].getElectionTimeout()) * time.Millisecond
+	assert.True(t, mockWaitNoLeader(running, electionTimeout*5))
+	frozen := 0
+	for _, raft := range running {
+		if freeze := raft.getFreeze(); freeze != nil {
+			frozen++
+			events := raft.GetHistory(0)
+			found := false
+			for _, event := range events {
+				if event.Type == EventFailoverFrozen {
+					found = true
+				}
+			}
+			assert.True(t, found)
+		}
+	}
+	assert.True(t, frozen > 0)
+
+	// 4. unfreeze, the new leader eggs
+	for _, raft := range running {
+		rsp := model.NewRaftStatusRPCResponse(model.OK)
+		assert.Nil(t, raft.GetRaftRPC().Unfreeze(model.NewRaftStatusRPCRequest(), rsp))
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Nil(t, raft.getFreeze())
+		assert.Equal(t, 0, raft.getLeaderChanges())
+	}
+	assert.NotEqual(t, -1, MockWaitLeaderEggs(running, 1))
+}
+
+// TEST EFFECTS:
+// test no election in the min leader tenure.
+//
+// TEST PROCESSES:
+// 1. Start 5 rafts with a long min-leader-tenure, wait the leader eggs
+// 2. stop the leader, the new leader eggs, it's the 1st leader change
+// 3. stop the leader again, no election in the tenure, the failover is not frozen
+// 4. disable the tenure, the new leader eggs
+func TestRaftFailoverMinLeaderTenure(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 5, -1)
+	defer cleanup()
+
+	// 1. Start 5 rafts with a long min-leader-tenure, wait the leader eggs
+	rafts[0].conf.MinLeaderTenure = 1000 * 3600
+	defer func() { rafts[0].conf.MinLeaderTenure = 0 }()
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	MockWaitLeaderEggs(rafts, 1)
+
+	// 2. stop the leader, the new leader eggs, it's the 1st leader change
+	running := mockStopLeader(rafts)
+	MockWaitLeaderEggs(running, 1)
+
+	// 3. stop the leader again, no election in the tenure, the failover is not frozen
+	running = mockStopLeader(running)
+	electionTimeout := time.Duration(rafts[0].getElectionTimeout()) * time.Millisecond
+	assert.True(t, mockWaitNoLeader(running, electionTimeout*5))
+	for _, raft := range running {
+		assert.Nil(t, raft.getFreeze())
+		assert.Equal(t, model.ErrorFailoverCooldown, raft.checkFailover())
+	}
+
+	// 4. disable the tenure, the new leader eggs
+	rafts[0].conf.MinLeaderTenure = 0
+	MockWaitLeaderEggs(running, 1)
+}
+
+// TEST EFFECTS:
+// test the voter checks the failover limit without freezing the failover.
+//
+// TEST PROCESSES:
+// 1. set failover-limit 1 and record a leader change
+// 2. the voter check is frozen, but the failover is not frozen
+// 3. the CANDIDATE check freezes the failover
+func TestRaftFailoverVoterReadOnly(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-failover")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// 1. set failover-limit 1 and record a leader change
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	conf.FailoverLimit = 1
+	conf.FailoverWindow = 1000 * 3600
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	raft := NewRaft("127.0.0.1:0101", conf, 10000, log, mysql57, FOLLOWER)
+	raft.recordLeaderChange("127.0.0.1:0202", "127.0.0.1:0303")
+	assert.Equal(t, 1, raft.getLeaderChanges())
+
+	// 2. the voter check is frozen, but the failover is not frozen
+	assert.True(t, raft.isFailoverFrozen())
+	assert.Nil(t, raft.getFreeze())
+
+	// 3. the CANDIDATE check freezes the failover
+	assert.Equal(t, model.ErrorFailoverFrozen, raft.checkFailover())
+	assert.NotNil(t, raft.getFreeze())
+}
+
+// TEST EFFECTS:
+// test the leader transfer is not counted in the failover window.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, wait the leader eggs
+// 2. transfer the leader to a FOLLOWER
+// 3. check no raft counts the leader change
+func TestRaftFailoverTransferNotCounted(t *testing.T) {
+	var whoisleader, target int
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, wait the leader eggs
+	rafts[0].conf.FailoverWindow = 1000 * 3600
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader = MockWaitLeaderEggs(rafts, 1)
+	target = (whoisleader + 1) % len(rafts)
+
+	// 2. transfer the leader to a FOLLOWER
+	{
+		rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+		rafts[whoisleader].transferLeader(names[target], rsp)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, LEADER, rafts[target].getState())
+	}
+
+	// 3. check no raft counts the leader change
+	time.Sleep(time.Millisecond * time.Duration(rafts[0].getElectionTimeout()))
+	for _, raft := range rafts {
+		assert.Equal(t, names[target], raft.getLeader())
+		assert.Equal(t, 0, raft.getLeaderChanges())
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	fencePromote = "promote"
+	fenceAbort   = "abort"
+
+	fenceBySuperReadOnly = "super-read-only"
+	fenceByCommand       = "fence-command"
+	fenceTimeout         = "timeout"
+)
+
+// getFenceTarget returns the old leader and its MySQL replication info,
+// the old leader is empty if there is nothing to fence,
+// the MySQL host is empty if we never got the heartbeat of the old leader.
+func (r *Raft) getFenceTarget() (string, model.Repl) {
+	if r.oldLeader == "" || r.oldLeader == r.getID() {
+		return "", model.Repl{}
+	}
+	if r.oldLeader != r.leaderReplFrom {
+		return r.oldLeader, model.Repl{}
+	}
+	return r.oldLeader, r.leaderRepl
+}
+
+// fenceOldLeader makes sure the old leader MySQL can't serve the writes before we do:
+// 1. set the old leader MySQL super_read_only with the admin user
+// 2. if 1 fails, run the fence-command, such as killing the remote mysqld or blocking its port
+// they are retried until the old leader is fenced or fence-timeout, then the fence-timeout-policy decides:
+// promote: continue the promotion
+// abort: returns error, the leader steps down
+// if the old leader MySQL address is unknown, 1 always fails.
+func (r *Raft) fenceOldLeader() error {
+	oldLeader, repl := r.getFenceTarget()
+	if oldLeader == "" {
+		r.WARNING("fence.no.old.leader[%v].to.fence.skip", r.oldLeader)
+		return nil
+	}
+
+	interval := time.Duration(r.getHeartbeatTimeout()) * time.Millisecond
+	deadline := r.clock.Now().Add(time.Duration(r.conf.FenceTimeout) * time.Millisecond)
+	for attempts := 1; ; attempts++ {
+		if r.getState() != LEADER {
+			return errors.Errorf("fence.old.leader[%v].i.am.not.leader[%v]", oldLeader, r.getState())
+		}
+
+		err := errors.Errorf("old.leader[%v].mysql.address.unknown", oldLeader)
+		if repl.Master_Host != "" {
+			// the attempt ends at the fence deadline, but it has one heartbeat interval at least
+			timeout := deadline.Sub(r.clock.Now())
+			if timeout < interval {
+				timeout = interval
+			}
+			err = r.mysql.SetRemoteReadOnly(repl.Master_Host, repl.Master_Port, int(timeout/time.Millisecond))
+		}
+		if err == nil {
+			r.WARNING("fence.old.leader[%v].mysql[%v:%v].set.super_read_only.done", oldLeader, repl.Master_Host, repl.Master_Port)
+			r.emit(model.RaftEvent{Type: EventFence, Peer: oldLeader, Reason: fenceBySuperReadOnly})
+			return nil
+		}
+		r.ERROR("fence.old.leader[%v].mysql[%v:%v].set.super_read_only.attempt[%v].error[%v]", oldLeader, repl.Master_Host, repl.Master_Port, attempts, err)
+
+		if r.conf.FenceCommand != "" {
+			if err := r.runFenceCommand(oldLeader, repl); err == nil {
+				r.WARNING("fence.old.leader[%v].command[%v].done", oldLeader, r.conf.FenceCommand)
+				r.emit(model.RaftEvent{Type: EventFence, Peer: oldLeader, Reason: fenceByCommand})
+				return nil
+			}
+		}
+
+		if !r.clock.Now().Add(interval).Before(deadline) {
+			break
+		}
+		r.clock.Sleep(interval)
+	}
+
+	r.emit(model.RaftEvent{Type: EventFence, Peer: oldLeader, Reason: fenceTimeout})
+	if r.conf.FenceTimeoutPolicy == fenceAbort {
+		r.ERROR("fence.old.leader[%v].timeout[%vms].policy[%v]", oldLeader, r.conf.FenceTimeout, fenceAbort)
+		return errors.Errorf("fence.old.leader[%v].timeout[%vms]", oldLeader, r.conf.FenceTimeout)
+	}
+	r.ERROR("fence.old.leader[%v].timeout[%vms].policy[%v].continue.the.promotion", oldLeader, r.conf.FenceTimeout, fencePromote)
+	return nil
+}
+
+// runFenceCommand runs the fence-command with the hook env and the old leader MySQL address.
+func (r *Raft) runFenceCommand(oldLeader string, repl model.Repl) error {
+	timeout := r.conf.FenceTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	args := []string{
+		"-c",
+		r.conf.FenceCommand,
+	}
+	env := append(r.getHookEnv(fenceByCommand, oldLeader, r.getID()),
+		fmt.Sprintf("XENON_FENCE_HOST=%s", repl.Master_Host),
+		fmt.Sprintf("XENON_FENCE_PORT=%d", repl.Master_Port))
+	if _, err := r.cmd.RunCommandWithEnv(timeout, env, bash, args); err != nil {
+		r.ERROR("fence.old.leader[%v].command[%v].error[%+v]", oldLeader, r.conf.FenceCommand, err)
+		return err
+	}
+	return nil
+}
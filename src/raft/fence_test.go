@@ -0,0 +1,141 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the new leader fences the old leader by super_read_only, the fence-command and the fence-timeout-policy.
+//
+// TEST PROCESSES:
+// 0. Start 3 rafts, wait the leader eggs
+// 1. the old leader mysql is set super_read_only
+// 2. super_read_only fails, the fence-command fences the old leader
+// 3. the old leader is not fenced, promote policy continues the promotion
+// 4. the old leader is not fenced, abort policy returns error
+// 5. no old leader, skip the fencing
+// 6. the old leader MySQL address is unknown, abort policy returns error
+// 7. the fence-timeout is on the raft clock
+func TestRaftFenceOldLeader(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "xenon-fence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	envFile := filepath.Join(dir, "env")
+
+	// 0. Start 3 rafts, wait the leader eggs
+	conf := *rafts[0].conf
+	conf.FenceTimeout = 200
+	for _, raft := range rafts {
+		raft.conf = &conf
+		raft.Start()
+	}
+	raft := rafts[MockWaitLeaderEggs(rafts, 1)]
+	raft.oldLeader = "old:8801"
+	raft.leaderReplFrom = "old:8801"
+	raft.leaderRepl = model.Repl{Master_Host: "192.168.0.2", Master_Port: 3306}
+	lastFence := func() model.RaftEvent {
+		events := raft.GetHistory(0)
+		for i := len(events) - 1; i >= 0; i-- {
+			if events[i].Type == EventFence {
+				return events[i]
+			}
+		}
+		return model.RaftEvent{}
+	}
+
+	// 1. the old leader mysql is set super_read_only
+	assert.Nil(t, raft.fenceOldLeader())
+	assert.Equal(t, "old:8801", lastFence().Peer)
+	assert.Equal(t, fenceBySuperReadOnly, lastFence().Reason)
+
+	// 2. super_read_only fails, the fence-command fences the old leader
+	mock := mysql.NewMockGTIDA()
+	mock.SetReadOnlyFn = mysql.SetReadOnlyError
+	MockSetMysqlHandler(raft, mock)
+	conf.FenceCommand = "env | grep XENON_ > " + envFile
+	assert.Nil(t, raft.fenceOldLeader())
+	assert.Equal(t, fenceByCommand, lastFence().Reason)
+	out, err := ioutil.ReadFile(envFile)
+	assert.Nil(t, err)
+	env := string(out)
+	for _, want := range []string{
+		"XENON_OLD_LEADER=old:8801",
+		"XENON_FENCE_HOST=192.168.0.2",
+		"XENON_FENCE_PORT=3306",
+	} {
+		assert.True(t, strings.Contains(env, want), want)
+	}
+
+	// 3. the old leader is not fenced, promote policy continues the promotion
+	conf.FenceCommand = "exit 1"
+	conf.FenceTimeoutPolicy = fencePromote
+	assert.Nil(t, raft.fenceOldLeader())
+	assert.Equal(t, fenceTimeout, lastFence().Reason)
+
+	// 4. the old leader is not fenced, abort policy returns error
+	conf.FenceTimeoutPolicy = fenceAbort
+	assert.NotNil(t, raft.fenceOldLeader())
+
+	// 5. no old leader, skip the fencing
+	raft.oldLeader = raft.getID()
+	before := len(raft.GetHistory(0))
+	assert.Nil(t, raft.fenceOldLeader())
+	assert.Equal(t, before, len(raft.GetHistory(0)))
+
+	// 6. the old leader MySQL address is unknown, abort policy returns error
+	MockSetMysqlHandler(raft, mysql.NewMockGTIDA())
+	raft.oldLeader = "old:8801"
+	raft.leaderReplFrom = "other:8801"
+	assert.NotNil(t, raft.fenceOldLeader())
+	assert.Equal(t, "old:8801", lastFence().Peer)
+	assert.Equal(t, fenceTimeout, lastFence().Reason)
+
+	// 7. the fence-timeout is on the raft clock
+	{
+		clockConf := conf
+		clockConf.FenceCommand = ""
+		clockConf.FenceTimeout = 60 * 60 * 1000
+		fencing := NewRaft(raft.getID(), &clockConf, 10000, log, raft.mysql, FOLLOWER)
+		clock := common.NewFakeClock(time.Now())
+		fencing.SetClock(clock)
+		fencing.setState(LEADER)
+		fencing.oldLeader = "old:8801"
+
+		done := make(chan error, 1)
+		go func() { done <- fencing.fenceOldLeader() }()
+		var err error
+		for fenced := false; !fenced; {
+			select {
+			case err = <-done:
+				fenced = true
+			case <-time.After(time.Millisecond):
+				clock.Advance(time.Minute)
+			}
+		}
+		assert.NotNil(t, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"model"
 	"strings"
 	"sync"
+	"xbase/common"
 )
 
 // Follower tuple.
@@ -52,7 +53,8 @@ func (r *Follower) Loop() {
 	r.stateInit()
 	defer r.stateExit()
 
-	r.resetElectionTimeout()
+	var preVote *preVoteRound
+	r.resetCandidacyTimeout()
 	for r.getState() == FOLLOWER {
 		select {
 		case <-r.fired:
@@ -62,13 +64,22 @@ func (r *Follower) Loop() {
 			// promotable cases:
 			// 1. MySQL is MYSQL_ALIVE
 			// 2. Slave_SQL_RNNNING is OK
-			if !r.isBrainSplit && r.mysql.Promotable() {
+			if preVote == nil && !r.isBrainSplit && r.mysql.Promotable() {
 				r.WARNING("timeout.and.ping.almost.node.successed.promote.to.candidate")
-				r.upgradeToCandidate()
+				preVote = r.upgradeToCandidate()
 			}
 
 			// reset timeout
-			r.resetElectionTimeout()
+			r.resetCandidacyTimeout()
+		case rsp := <-preVote.responses():
+			if r.processPreVoteResponse(preVote, rsp) {
+				if r.preVoteGranted(preVote) {
+					r.promoteToCandidate()
+				} else {
+					r.WARNING("prevote.fail.can.not.upgrade.to.candidate")
+				}
+				preVote = nil
+			}
 		case e := <-r.c:
 			switch e.Type {
 			case MsgRaftHeartbeat:
@@ -78,9 +89,12 @@ func (r *Follower) Loop() {
 
 				if rsp.RetCode != model.OK {
 					r.WARNING("process.heartbeat.request.RetCode.not.OK:%+v", rsp.RetCode)
+				} else {
+					// the leader is alive, give up the prevote in flight
+					preVote = nil
 				}
 				// reset timeout
-				r.resetElectionTimeout()
+				r.resetCandidacyTimeout()
 			case MsgRaftRequestVote:
 				req := e.request.(*model.RaftRPCRequest)
 				rsp := r.processRequestVoteRequestHandler(req)
@@ -88,12 +102,19 @@ func (r *Follower) Loop() {
 
 				// reset timeout
 				if rsp.RetCode == model.OK {
-					r.resetElectionTimeout()
+					preVote = nil
+					r.resetCandidacyTimeout()
 				}
 			case MsgRaftPing:
 				req := e.request.(*model.RaftRPCRequest)
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
 			default:
 				r.ERROR("get.unknown.request[%v]", e.Type)
 			}
@@ -119,7 +140,10 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.GTID = r.mysql.GetReplStatus()
 
 	r.DEBUG("get.heartbeat.from[N:%v, V:%v, E:%v]...", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 	if !r.checkRequest(req) {
@@ -136,6 +160,9 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 		rsp.RetCode = model.ErrorInvalidViewID
 
 	case viewdiff <= 0:
+		r.updateLeaderAlive()
+		r.updateClusterFreeze(req.GetFrom(), req.ClusterFreeze)
+
 		// MySQL1: disable master semi-sync because I am a slave
 		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
 			r.ERROR("mysql.DisableSemiSyncMaster.error[%v]", err)
@@ -176,7 +203,8 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 			}
 
 			r.ChangeToMasterError = false
-			r.leader = req.GetFrom()
+			r.setLeader(req.GetFrom())
+			r.leaderRepl, r.leaderReplFrom = req.Repl, req.GetFrom()
 			r.WARNING("get.heartbeat.change.to.the.new.master[%v].successed", req.GetFrom())
 		}
 
@@ -189,7 +217,7 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetWitnesses(), req.GetNextPeers())
 		}
 	}
 	return rsp
@@ -206,13 +234,17 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 // 1. ErrorInvalidRequest: the request.From is not a member of this cluster
 // 2. ErrorInvalidViewID: request viewid is old
 // 3. ErrorInvalidGTID: the CANDIDATE has the smaller Read_Master_Log_Pos
-// 4. OK: give a vote
+// 4. ErrorLowerPriority: the CANDIDATE has the same GTID but the lower priority
+// 5. ErrorInvalidEpochID: the CANDIDATE has the stale membership epoch
+// 6. OK: give a vote
 func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
 	rsp := model.NewRaftRPCResponse(model.OK)
 	rsp.Raft.From = r.getID()
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -227,6 +259,13 @@ func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.R
 			rsp.RetCode = model.ErrorInvalidViewID
 			return rsp
 		}
+
+		// the candidate with a stale membership may count the votes with the stale quorum
+		if !r.checkRequestEpoch(req) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.epochid[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getEpochID())
+			rsp.RetCode = model.ErrorInvalidEpochID
+			return rsp
+		}
 	}
 
 	// 2. check GTID
@@ -237,7 +276,7 @@ func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.R
 			r.ERROR("mysql.StopSlaveIOThread.error[%+v]", err)
 		}
 
-		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID)
+		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID, r.conf.GTIDTieBreak)
 		if err != nil {
 			r.ERROR("process.requestvote.get.gtid.error[%v].ret.ErrorMySQLDown", err)
 			rsp.RetCode = model.ErrorMySQLDown
@@ -254,6 +293,13 @@ func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.R
 				return rsp
 			}
 		}
+
+		// 2. I am promotable: GTID equal with you but my priority is higher
+		if r.isLowerPriority(req, &thisGTID) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v, P:%v].lower.priority.ret.ErrorLowerPriority", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.Raft.Priority)
+			rsp.RetCode = model.ErrorLowerPriority
+			return rsp
+		}
 	}
 
 	// 3. check viewid(req.viewid > thisnode.viewid)
@@ -273,7 +319,9 @@ func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.R
 	}
 
 	// 4. voted for this candidate
-	r.votedFor = req.GetFrom()
+	r.setVotedFor(req.GetFrom())
+	// the candidate we voted for is on the way to be the leader
+	r.updateLeaderAlive()
 	r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].vote.for.this.candidate", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 	return rsp
 }
@@ -284,6 +332,8 @@ func (r *Follower) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCR
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
@@ -318,7 +368,7 @@ func (r *Follower) startCheckBrainSplit() {
 						r.DEBUG("receive.ping.responses.from.leader[%v].skip.check.brain.split", rsp.GetFrom())
 						continue
 					}
-					if strings.Contains("FOLLOWER CANDIDATE LEARNER", rsp.Raft.State) {
+					if strings.Contains("FOLLOWER CANDIDATE LEARNER WITNESS", rsp.Raft.State) {
 						cnt++
 						r.DEBUG("receive.ping.responses[%v].from[N:%v, R:%v]", cnt, rsp.GetFrom(), rsp.Raft.State)
 					}
@@ -348,18 +398,51 @@ func (r *Follower) sendClusterPing(respChan chan *model.RaftRPCResponse) {
 	}
 }
 
-func (r *Follower) upgradeToCandidate() {
+// upgradeToCandidate starts the prevote if this node can be the CANDIDATE,
+// the state loop promotes us by promoteToCandidate once the majority would vote for us.
+func (r *Follower) upgradeToCandidate() *preVoteRound {
 	// only you
 	if len(r.peers) == 0 {
 		r.WARNING("peers.is.null.can.not.upgrade.to.candidate")
-		return
+		return nil
+	}
+
+	if r.getPriority() == 0 {
+		r.WARNING("priority.is.0.can.not.upgrade.to.candidate")
+		return nil
+	}
+
+	if r.inMaintenance() {
+		r.WARNING("in.maintenance.can.not.upgrade.to.candidate")
+		return nil
+	}
+
+	if r.isClusterFrozen() {
+		r.WARNING("cluster.is.frozen.can.not.upgrade.to.candidate")
+		return nil
+	}
+
+	if ret := r.checkFailover(); ret != model.OK {
+		r.WARNING("failover.check[%v].can.not.upgrade.to.candidate", ret)
+		return nil
 	}
 
 	if r.ChangeToMasterError {
 		r.WARNING("change.to.master.error.can.not.upgrade.to.candidate")
-		return
+		return nil
 	}
 
+	if r.isPreVoteGrantedRecently() {
+		r.WARNING("prevote.granted.to.other.recently.can.not.upgrade.to.candidate")
+		return nil
+	}
+
+	// only the majority would vote for us, we can bump the viewid
+	return r.startPreVote()
+}
+
+// promoteToCandidate becomes the CANDIDATE after the prevote is granted.
+func (r *Follower) promoteToCandidate() {
 	// stop io thread
 	// it will re-start again when heartbeat received
 	if err := r.mysql.StopSlaveIOThread(); err != nil {
@@ -369,6 +452,12 @@ func (r *Follower) upgradeToCandidate() {
 	r.IncCandidatePromotes()
 }
 
+// resetCandidacyTimeout resets the election timeout with the delay of the priority.
+func (r *Follower) resetCandidacyTimeout() {
+	common.TimerRelease(r.electionTick)
+	r.electionTick = common.NewRandomTimer(r.clock, r.getElectionTimeout()+r.getCandidacyDelay())
+}
+
 func (r *Follower) degradeToInvalid(followerGTID *model.GTID, candidateGTID *model.GTID) {
 	// only you
 	if len(r.peers) == 0 {
@@ -386,6 +475,7 @@ func (r *Follower) degradeToInvalid(followerGTID *model.GTID, candidateGTID *mod
 	greater := r.mysql.CheckGTID(followerGTID, candidateGTID)
 	if greater {
 		// degrade to INVALID
+		r.emitDegrade(INVALID, degradeLocalGTIDGreater)
 		r.setState(INVALID)
 		return
 	}
@@ -424,9 +514,9 @@ func (r *Follower) stateInit() {
 	r.WARNING("state.init")
 	r.updateStateBegin()
 	// 1. stop vip
-	if err := r.leaderStopShellCommand(); err != nil {
+	if err := r.runDemoteHooks(HookPostDemote); err != nil {
 		// TODO(array): what todo?
-		r.ERROR("stopShellCommand.error[%v]", err)
+		r.ERROR("post-demote.hooks.error[%v]", err)
 	}
 	r.setMySQLAsync()
 	r.WARNING("state.machine.run")
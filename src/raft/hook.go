@@ -0,0 +1,215 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"fmt"
+	"model"
+	"strings"
+	"time"
+)
+
+const (
+	bash = "bash"
+
+	// HookPrePromote runs before the new leader changes MySQL to master.
+	HookPrePromote = "pre-promote"
+
+	// HookPostPromote runs after the new leader sets MySQL read/write, such as START-VIP.
+	HookPostPromote = "post-promote"
+
+	// HookPreDemote runs before the leader steps down.
+	HookPreDemote = "pre-demote"
+
+	// HookPostDemote runs when the node is not the leader, such as STOP-VIP.
+	HookPostDemote = "post-demote"
+
+	// HookOnInvalid runs when the node becomes INVALID.
+	HookOnInvalid = "on-invalid"
+
+	// HookOnMySQLDown runs when the leader feels MySQL down.
+	HookOnMySQLDown = "on-mysql-down"
+
+	// HookOnFailoverFrozen runs when the failover limit is hit and the failover is frozen, such as paging the operator.
+	HookOnFailoverFrozen = "on-failover-frozen"
+
+	hookAbort          = "abort"
+	hookContinue       = "continue"
+	defaultHookTimeout = 10000 // ms
+)
+
+var hookStages = []string{
+	HookPrePromote,
+	HookPostPromote,
+	HookPreDemote,
+	HookPostDemote,
+	HookOnInvalid,
+	HookOnMySQLDown,
+	HookOnFailoverFrozen,
+}
+
+// checkHooks warns the unknown stages and failure policies of the hooks.
+func (r *Raft) checkHooks() {
+	for stage, hooks := range r.conf.Hooks {
+		known := false
+		for _, s := range hookStages {
+			if s == stage {
+				known = true
+			}
+		}
+		if !known {
+			r.WARNING("hook.stage[%v].is.unknown.must.be.one.of[%v]", stage, strings.Join(hookStages, ","))
+		}
+		for _, hook := range hooks {
+			if hook.OnFailure != "" && hook.OnFailure != hookAbort && hook.OnFailure != hookContinue {
+				r.WARNING("hook[%v/%v].on-failure[%v].is.unknown.use.continue", stage, hook.Name, hook.OnFailure)
+			}
+		}
+	}
+}
+
+// getHooks returns the hooks of the stage,
+// the leader-start-command is the last post-promote hook and the leader-stop-command is the last post-demote hook.
+func (r *Raft) getHooks(stage string) []config.HookConfig {
+	hooks := append([]config.HookConfig{}, r.conf.Hooks[stage]...)
+	switch stage {
+	case HookPostPromote:
+		if r.conf.LeaderStartCommand != "" {
+			hooks = append(hooks, config.HookConfig{Name: "leader-start-command", Command: r.conf.LeaderStartCommand})
+		}
+	case HookPostDemote:
+		if r.conf.LeaderStopCommand != "" {
+			hooks = append(hooks, config.HookConfig{Name: "leader-stop-command", Command: r.conf.LeaderStopCommand})
+		}
+	}
+	for i := range hooks {
+		if hooks[i].Name == "" {
+			hooks[i].Name = fmt.Sprintf("%s-%d", stage, i)
+		}
+	}
+	return hooks
+}
+
+// runHooks runs the hooks of the stage in order,
+// returns error if a hook with the abort policy fails.
+func (r *Raft) runHooks(stage string, oldLeader string, newLeader string) error {
+	hooks := r.getHooks(stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	env := r.getHookEnv(stage, oldLeader, newLeader)
+	for _, hook := range hooks {
+		result := r.runHook(stage, hook, env)
+		r.setHookResult(result)
+		if result.RetCode == model.OK {
+			continue
+		}
+		if hook.OnFailure == hookAbort {
+			r.ERROR("hook[%v/%v].failed[%v].abort.the.stage", stage, hook.Name, result.RetCode)
+			return fmt.Errorf("hook[%v/%v].failed[%v]", stage, hook.Name, result.RetCode)
+		}
+		r.WARNING("hook[%v/%v].failed[%v].continue", stage, hook.Name, result.RetCode)
+	}
+	return nil
+}
+
+// runHook runs the hook with the retries.
+func (r *Raft) runHook(stage string, hook config.HookConfig, env []string) model.RaftHookResult {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	args := []string{
+		"-c",
+		hook.Command,
+	}
+	hookEnv := append(append([]string{}, env...), fmt.Sprintf("XENON_HOOK_NAME=%s", hook.Name))
+
+	begin := r.clock.Now()
+	result := model.RaftHookResult{
+		Stage:   stage,
+		Name:    hook.Name,
+		StartAt: begin.UnixNano() / int64(time.Millisecond),
+	}
+	for result.Attempts <= hook.Retries {
+		if result.Attempts > 0 {
+			r.clock.Sleep(time.Duration(hook.RetryInterval) * time.Millisecond)
+		}
+		result.Attempts++
+
+		out, err := r.cmd.RunCommandWithEnv(timeout, hookEnv, bash, args)
+		result.Output = out
+		if err == nil {
+			result.RetCode = model.OK
+			break
+		}
+		result.RetCode = err.Error()
+		r.ERROR("hook[%v/%v].command[%v].attempt[%v].error[%+v]", stage, hook.Name, hook.Command, result.Attempts, err)
+	}
+	result.Cost = r.sinceMs(begin)
+	if result.RetCode == model.OK {
+		r.WARNING("hook[%v/%v].command[%v].done.attempts[%v].cost[%vms]", stage, hook.Name, hook.Command, result.Attempts, result.Cost)
+	}
+	return result
+}
+
+// getHookEnv returns the environment variables of the hooks.
+func (r *Raft) getHookEnv(stage string, oldLeader string, newLeader string) []string {
+	env := []string{
+		fmt.Sprintf("XENON_HOOK_STAGE=%s", stage),
+		fmt.Sprintf("XENON_NODE=%s", r.getID()),
+		fmt.Sprintf("XENON_OLD_LEADER=%s", oldLeader),
+		fmt.Sprintf("XENON_NEW_LEADER=%s", newLeader),
+		fmt.Sprintf("XENON_VIEWID=%d", r.getViewID()),
+		fmt.Sprintf("XENON_EPOCHID=%d", r.getEpochID()),
+	}
+
+	executed := ""
+	if !r.IsWitness() {
+		if gtid, err := r.mysql.GetGTID(); err != nil {
+			r.ERROR("hook[%v].get.gtid.error[%v]", stage, err)
+		} else {
+			executed = gtid.Executed_GTID_Set
+		}
+	}
+	return append(env, fmt.Sprintf("XENON_EXECUTED_GTID_SET=%s", executed))
+}
+
+// setHookResult keeps the last result of the hook.
+func (r *Raft) setHookResult(result model.RaftHookResult) {
+	r.hookMutex.Lock()
+	defer r.hookMutex.Unlock()
+
+	for i, last := range r.hookResults {
+		if last.Stage == result.Stage && last.Name == result.Name {
+			r.hookResults[i] = result
+			return
+		}
+	}
+	r.hookResults = append(r.hookResults, result)
+}
+
+// getHookResults returns the last results of the hooks.
+func (r *Raft) getHookResults() []model.RaftHookResult {
+	r.hookMutex.Lock()
+	defer r.hookMutex.Unlock()
+	return append([]model.RaftHookResult{}, r.hookResults...)
+}
+
+// runPromoteHooks runs the promote stage hooks, the old leader is the leader before this node.
+func (r *Raft) runPromoteHooks(stage string) error {
+	return r.runHooks(stage, r.oldLeader, r.getID())
+}
+
+// runDemoteHooks runs the non-leader stage hooks, the old leader is the last known leader.
+func (r *Raft) runDemoteHooks(stage string) error {
+	return r.runHooks(stage, r.lastLeader, r.getLeader())
+}
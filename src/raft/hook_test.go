@@ -0,0 +1,190 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the hooks of a stage run in order with the retries and the failure policies.
+//
+// TEST PROCESSES:
+// 1. a failed hook with continue policy, the following hooks go on
+// 2. a failed hook with retries and abort policy, the following hooks are skipped
+// 3. check the results and the environment variables
+func TestRaftHooks(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 1, -1)
+	defer cleanup()
+	raft := rafts[0]
+
+	dir, err := ioutil.TempDir("", "xenon-hook")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	envFile := filepath.Join(dir, "env")
+	skipFile := filepath.Join(dir, "skip")
+
+	conf := *raft.conf
+	conf.LeaderStopCommand = ""
+	conf.Hooks = map[string][]config.HookConfig{
+		HookPreDemote: {
+			{Name: "fail", Command: "exit 1"},
+			{Name: "env", Command: "env | grep XENON_ > " + envFile},
+			{Name: "abort", Command: "exit 2", Retries: 2, RetryInterval: 10, OnFailure: hookAbort},
+			{Name: "skip", Command: "touch " + skipFile},
+		},
+	}
+	raft.conf = &conf
+	raft.Start()
+
+	// 1. a failed hook with continue policy, the following hooks go on
+	// 2. a failed hook with retries and abort policy, the following hooks are skipped
+	err = raft.runHooks(HookPreDemote, "old:8801", "new:8801")
+	assert.NotNil(t, err)
+	_, err = os.Stat(skipFile)
+	assert.True(t, os.IsNotExist(err))
+
+	// 3. check the results and the environment variables
+	results := raft.getHookResults()
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "fail", results[0].Name)
+	assert.NotEqual(t, model.OK, results[0].RetCode)
+	assert.Equal(t, 1, results[0].Attempts)
+	assert.Equal(t, model.OK, results[1].RetCode)
+	assert.Equal(t, HookPreDemote, results[2].Stage)
+	assert.Equal(t, 3, results[2].Attempts)
+
+	out, err := ioutil.ReadFile(envFile)
+	assert.Nil(t, err)
+	env := string(out)
+	for _, want := range []string{
+		"XENON_HOOK_STAGE=pre-demote",
+		"XENON_HOOK_NAME=env",
+		"XENON_OLD_LEADER=old:8801",
+		"XENON_NEW_LEADER=new:8801",
+		"XENON_NODE=" + raft.getID(),
+		"XENON_EXECUTED_GTID_SET=",
+	} {
+		assert.True(t, strings.Contains(env, want), want)
+	}
+}
+
+// TEST EFFECTS:
+// test the leader runs the post-promote hooks after the promotion.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts with a post-promote hook
+// 2. wait the leader eggs and the hook done
+func TestRaftHooksPromote(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "xenon-hook")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	leaderFile := filepath.Join(dir, "leader")
+
+	// 1. Start 3 rafts with a post-promote hook
+	conf := *rafts[0].conf
+	conf.Hooks = map[string][]config.HookConfig{
+		HookPostPromote: {
+			{Name: "vip", Command: "echo -n $XENON_NEW_LEADER > " + leaderFile, Timeout: 1000},
+		},
+	}
+	for _, raft := range rafts {
+		raft.conf = &conf
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs and the hook done
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()))
+	out, err := ioutil.ReadFile(leaderFile)
+	assert.Nil(t, err)
+	assert.Equal(t, leader.getID(), string(out))
+
+	found := false
+	for _, result := range leader.getHookResults() {
+		if result.Stage == HookPostPromote && result.Name == "vip" {
+			found = true
+			assert.Equal(t, model.OK, result.RetCode)
+		}
+	}
+	assert.True(t, found)
+}
+
+// TEST EFFECTS:
+// test the leader runs the post-demote hooks after it's not LEADER.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts with a post-demote hook which marks itself and sleeps
+// 2. wait the leader eggs and stop the FOLLOWERs, the leader degrades
+// 3. check the leader is FOLLOWER while the post-demote hook is running
+func TestRaftHooksDemote(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "xenon-hook")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// 1. Start 3 rafts with a post-demote hook which marks itself and sleeps
+	conf := *rafts[0].conf
+	conf.LeaderStopCommand = ""
+	conf.Hooks = map[string][]config.HookConfig{
+		HookPostDemote: {
+			{Name: "mark", Command: "touch " + dir + "/$XENON_NODE; sleep 1", Timeout: 5000},
+		},
+	}
+	for _, raft := range rafts {
+		raft.conf = &conf
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs and stop the FOLLOWERs, the leader degrades
+	// the FOLLOWER runs the post-demote hooks too, the mark left before the leader eggs is removed
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	os.Remove(filepath.Join(dir, leader.getID()))
+	for i, raft := range rafts {
+		if i != whoisleader {
+			raft.Stop()
+		}
+	}
+
+	// 3. check the leader is FOLLOWER while the post-demote hook is running
+	marked := false
+	for i := 0; i < 100 && !marked; i++ {
+		if _, err := os.Stat(filepath.Join(dir, leader.getID())); err == nil {
+			marked = true
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.True(t, marked)
+	assert.Equal(t, FOLLOWER, leader.getState())
+}
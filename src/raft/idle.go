@@ -77,6 +77,15 @@ func (r *Idle) Loop() {
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
 
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
+
 			default:
 				r.ERROR("get.unknown.request[%v]", e.Type)
 			}
@@ -95,7 +104,10 @@ func (r *Idle) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPC
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.GTID = r.mysql.GetReplStatus()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -106,6 +118,8 @@ func (r *Idle) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPC
 	epochdiff := (int)(r.getEpochID() - req.GetEpochID())
 	switch {
 	case viewdiff <= 0:
+		r.updateClusterFreeze(req.GetFrom(), req.ClusterFreeze)
+
 		// MySQL1: disable master semi-sync because I am a slave
 		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
 			r.ERROR("mysql.DisableSemiSyncMaster.error[%v]", err)
@@ -130,7 +144,7 @@ func (r *Idle) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPC
 				rsp.RetCode = model.ErrorChangeMaster
 				return rsp
 			}
-			r.leader = req.GetFrom()
+			r.setLeader(req.GetFrom())
 		}
 
 		// view change
@@ -142,7 +156,7 @@ func (r *Idle) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPC
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetWitnesses(), req.GetNextPeers())
 		}
 	}
 	return rsp
@@ -161,6 +175,8 @@ func (r *Idle) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftR
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -171,9 +187,9 @@ func (r *Idle) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftR
 
 func (r *Idle) stateInit() {
 	// 1. stop vip
-	if err := r.leaderStopShellCommand(); err != nil {
+	if err := r.runDemoteHooks(HookPostDemote); err != nil {
 		// TODO(array): what todo?
-		r.ERROR("stopshell.error[%v]", err)
+		r.ERROR("post-demote.hooks.error[%v]", err)
 	}
 
 	// MySQL1: set readonly
@@ -193,6 +209,8 @@ func (r *Idle) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCRespo
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
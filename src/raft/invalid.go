@@ -75,6 +75,14 @@ func (r *Invalid) Loop() {
 				req := e.request.(*model.RaftRPCRequest)
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
+
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
 			default:
 				r.ERROR("get.unknow.request[%v].[%v]", r.getID(), e.Type)
 			}
@@ -93,7 +101,10 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.GTID = r.mysql.GetReplStatus()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -128,7 +139,7 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 				rsp.RetCode = model.ErrorChangeMaster
 				return rsp
 			}
-			r.leader = req.GetFrom()
+			r.setLeader(req.GetFrom())
 		}
 
 		// view change
@@ -140,7 +151,7 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetWitnesses(), req.GetNextPeers())
 		}
 	}
 	return rsp
@@ -159,15 +170,20 @@ func (r *Invalid) processRequestVoteRequest(req *model.RaftRPCRequest) *model.Ra
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	return rsp
 }
 
 func (r *Invalid) stateInit() {
 	// 1. stop vip
-	if err := r.leaderStopShellCommand(); err != nil {
+	if err := r.runDemoteHooks(HookPostDemote); err != nil {
 		// TODO(array): what todo?
-		r.ERROR("stopshell.error[%v]", err)
+		r.ERROR("post-demote.hooks.error[%v]", err)
+	}
+	if err := r.runDemoteHooks(HookOnInvalid); err != nil {
+		r.ERROR("on-invalid.hooks.error[%v]", err)
 	}
 
 	// MySQL1: set readonly
@@ -187,6 +203,8 @@ func (r *Invalid) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCRe
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
@@ -0,0 +1,132 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"mysql"
+	"sort"
+	"strconv"
+)
+
+// peerLag is the replication lag of a peer tracked by the leader.
+type peerLag struct {
+	model.RaftPeerLag
+
+	// the peer acked the heartbeat in this round
+	acked bool
+}
+
+// trackLag records the replication status of the peer in the heartbeat ack,
+// the lag in transactions is the leader executed GTID set subtracts the peer one.
+func (r *Raft) trackLag(rsp *model.RaftRPCResponse) {
+	if rsp.RetCode != model.OK || rsp.GetFrom() == "" {
+		return
+	}
+
+	lag := int64(-1)
+	seconds := int64(-1)
+	gtid := rsp.GetGTID()
+	// the WITNESS runs no mysql, it has no lag
+	if rsp.Raft.State != WITNESS.String() {
+		if master := r.getGTID().Executed_GTID_Set; master != "" {
+			if n, err := mysql.GTIDSetLag(master, gtid.Executed_GTID_Set); err != nil {
+				r.ERROR("track.lag.of.peer[%v].gtid.set.error[%v]", rsp.GetFrom(), err)
+			} else {
+				lag = int64(n)
+			}
+		}
+		if n, err := strconv.ParseInt(gtid.Seconds_Behind_Master, 10, 64); err == nil {
+			seconds = n
+		}
+	}
+
+	r.lagMutex.Lock()
+	defer r.lagMutex.Unlock()
+	l, ok := r.lags[rsp.GetFrom()]
+	if !ok {
+		l = &peerLag{}
+		r.lags[rsp.GetFrom()] = l
+	}
+	l.Peer = rsp.GetFrom()
+	l.State = rsp.Raft.State
+	l.IORunning = gtid.Slave_IO_Running_Str
+	l.SQLRunning = gtid.Slave_SQL_Running_Str
+	l.ExecutedGTIDSet = gtid.Executed_GTID_Set
+	l.LagTransactions = lag
+	l.LagSeconds = seconds
+	l.LastAckAt = r.clock.Now().UnixNano() / 1e6
+	l.AckMisses = 0
+	l.acked = true
+}
+
+// lagRound ends the heartbeat round, every peer which didn't ack in the round misses one.
+func (r *Raft) lagRound() {
+	r.lagMutex.Lock()
+	defer r.lagMutex.Unlock()
+	for _, name := range r.getLagPeers() {
+		if name == r.getID() {
+			continue
+		}
+		l, ok := r.lags[name]
+		if !ok {
+			l = &peerLag{RaftPeerLag: model.RaftPeerLag{Peer: name, LagTransactions: -1, LagSeconds: -1}}
+			r.lags[name] = l
+		}
+		if !l.acked {
+			l.AckMisses++
+		}
+		l.acked = false
+	}
+}
+
+// getLagPeers returns the peers and the idle peers the leader sends the heartbeats to.
+func (r *Raft) getLagPeers() []string {
+	peers := []string{}
+	peers = append(peers, r.getPeers()...)
+	return append(peers, r.getIdlePeers()...)
+}
+
+// resetLags clears the lags, the leader tracks them from scratch.
+func (r *Raft) resetLags() {
+	r.lagMutex.Lock()
+	defer r.lagMutex.Unlock()
+	r.lags = make(map[string]*peerLag)
+}
+
+// getLags returns the lags of the current peers sorted by the peer.
+func (r *Raft) getLags() []model.RaftPeerLag {
+	members := make(map[string]bool)
+	for _, name := range r.getLagPeers() {
+		members[name] = true
+	}
+
+	r.lagMutex.RLock()
+	defer r.lagMutex.RUnlock()
+	lags := []model.RaftPeerLag{}
+	for name, l := range r.lags {
+		if members[name] {
+			lags = append(lags, l.RaftPeerLag)
+		}
+	}
+	sort.Slice(lags, func(i, j int) bool { return lags[i].Peer < lags[j].Peer })
+	return lags
+}
+
+// getLearnerProgress returns the progress toward the automatic promotion,
+// nil if this raft is not a LEARNER or the promotion is disabled.
+func (r *Raft) getLearnerProgress() *model.RaftLearnerProgress {
+	if r.getState() != LEARNER || r.conf.LearnerPromoteAfter <= 0 {
+		return nil
+	}
+	r.learnerMutex.Lock()
+	defer r.learnerMutex.Unlock()
+	progress := r.learnerProgress
+	return &progress
+}
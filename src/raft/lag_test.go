@@ -0,0 +1,107 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"database/sql"
+	"model"
+	"mysql"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the leader tracks the replication lag of the followers from the heartbeat acks.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, the followers are 6 transactions and 3 seconds behind the leader
+// 2. wait the leader tracks the lags of the 2 followers
+// 3. stop one follower, its ack misses grow
+func TestRaftLeaderTrackLag(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, the followers are 6 transactions and 3 seconds behind the leader
+	for _, raft := range rafts {
+		h := mysql.NewMockGTIDA()
+		h.PingFn = func(db *sql.DB) (*mysql.PingEntry, error) {
+			return &mysql.PingEntry{
+				Executed_GTID_Set: `052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-30,
+    12446bf7-3219-11e5-9434-080027079e3d:8058-963126`,
+				Seconds_Behind_Master: "3",
+				Slave_IO_Running:      "Yes",
+				Slave_SQL_Running:     "Yes",
+			}, nil
+		}
+		MockSetMysqlHandler(raft, h)
+		raft.Start()
+	}
+
+	// 2. wait the leader tracks the lags of the 2 followers
+	var leader *Raft
+	MockWaitLeaderEggs(rafts, 1)
+	for _, raft := range rafts {
+		if raft.getState() == LEADER {
+			leader = raft
+		}
+	}
+	assert.NotNil(t, leader)
+
+	var lags []model.RaftPeerLag
+	for i := 0; i < 100; i++ {
+		lags = leader.getLags()
+		if len(lags) == 2 && lags[0].LagTransactions == 6 && lags[1].LagTransactions == 6 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 2, len(lags))
+	for _, lag := range lags {
+		assert.NotEqual(t, leader.getID(), lag.Peer)
+		assert.Contains(t, names, lag.Peer)
+		assert.Equal(t, "FOLLOWER", lag.State)
+		assert.Equal(t, int64(6), lag.LagTransactions)
+		assert.Equal(t, int64(3), lag.LagSeconds)
+		assert.Equal(t, "Yes", lag.IORunning)
+		assert.Equal(t, "Yes", lag.SQLRunning)
+		assert.True(t, lag.LastAckAt > 0)
+	}
+
+	// the followers track nothing
+	for _, raft := range rafts {
+		if raft != leader {
+			assert.Equal(t, 0, len(raft.getLags()))
+		}
+	}
+
+	// 3. stop one follower, its ack misses grow
+	var stopped string
+	for _, raft := range rafts {
+		if raft != leader {
+			stopped = raft.getID()
+			raft.Stop()
+			break
+		}
+	}
+	heartbeatTimeout := time.Duration(leader.getHeartbeatTimeout()) * time.Millisecond
+	time.Sleep(heartbeatTimeout * 5)
+	for _, lag := range leader.getLags() {
+		if lag.Peer == stopped {
+			assert.True(t, lag.AckMisses > 0)
+		} else {
+			assert.Equal(t, uint64(0), lag.AckMisses)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"sync"
 	"time"
 	"xbase/common"
+
+	"github.com/pkg/errors"
 )
 
 // Leader tuple.
@@ -31,9 +33,18 @@ type Leader struct {
 	// the binlog which we should purge to
 	nextPuregeBinlog string
 
-	purgeBinlogTick   *time.Ticker
-	checkSemiSyncTick *time.Ticker
-	checkGTIDTick     *time.Ticker
+	purgeBinlogTick   *common.Ticker
+	checkSemiSyncTick *common.Ticker
+	checkGTIDTick     *common.Ticker
+
+	// the purge binlog interval(ms) of the purgeBinlogTick
+	purgeBinlogInterval int
+
+	// fires when the lease may expire, nil if the lease is disabled
+	leaseTick *common.Timer
+
+	// checks the primary leader if the cluster is the standby, nil if it's not started
+	standbyTick *common.Ticker
 
 	// leader process heartbeat request handler
 	processHeartbeatRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
@@ -71,6 +82,9 @@ func NewLeader(r *Raft) *Leader {
 //                  higher viewid
 // State1. LEADER ------------------> FOLLOWER
 //
+//                  lease expired
+// State2. LEADER ------------------> FOLLOWER
+//
 func (r *Leader) Loop() {
 	r.stateInit()
 	defer r.stateExit()
@@ -80,17 +94,22 @@ func (r *Leader) Loop() {
 	ackGranted := 1
 
 	lessHtAcks := 0
-	maxLessHtAcks := r.Raft.conf.AdmitDefeatHtCnt
+	maxLessHtAcks := r.getAdmitDefeatHtCnt()
 
 	// send heartbeat
+	htSentAt := r.clock.Now()
 	respChan := make(chan *model.RaftRPCResponse, r.getAllMembers())
 	r.sendHeartbeatHandler(&mysqlDown, respChan)
 	r.resetHeartbeatTimeout()
+	leaseRenewed := r.checkLease(ackGranted, htSentAt, false)
 
 	for r.getState() == LEADER {
 		if mysqlDown {
 			r.WARNING("feel.mysql.down.degrade.to.follower")
-			r.degradeToFollower()
+			if err := r.runHooks(HookOnMySQLDown, r.getID(), noLeader); err != nil {
+				r.ERROR("on-mysql-down.hooks.error[%v]", err)
+			}
+			r.degradeToFollower(degradeMySQLDown)
 			break
 		}
 
@@ -98,6 +117,10 @@ func (r *Leader) Loop() {
 		case <-r.fired:
 			r.WARNING("state.machine.loop.got.fired")
 		case <-r.heartbeatTick.C:
+			// the timings may be changed at runtime
+			maxLessHtAcks = r.getAdmitDefeatHtCnt()
+			r.checkPurgeBinlogInterval()
+
 			if ackGranted < r.getQuorums() {
 				if r.getMembers() > 2 {
 					lessHtAcks++
@@ -106,7 +129,7 @@ func (r *Leader) Loop() {
 				r.WARNING("heartbeat.acks.granted[%v].less.than.quorums[%v].lessHtAcks[%v].maxLessHtAcks[%v]", ackGranted, r.getQuorums(), lessHtAcks, maxLessHtAcks)
 				if lessHtAcks >= maxLessHtAcks {
 					r.WARNING("degrade.to.follower.lessHtAcks[%v]>=maxLessHtAcks[%v]", lessHtAcks, maxLessHtAcks)
-					r.degradeToFollower()
+					r.degradeToFollower(degradeLessHtAcks)
 					break
 				}
 			} else {
@@ -125,12 +148,26 @@ func (r *Leader) Loop() {
 				}
 			}
 
+			r.lagRound()
 			ackGranted = 1
+			htSentAt = r.clock.Now()
 			respChan = make(chan *model.RaftRPCResponse, r.getAllMembers())
 			r.sendHeartbeatHandler(&mysqlDown, respChan)
 			r.resetHeartbeatTimeout()
+			leaseRenewed = r.checkLease(ackGranted, htSentAt, false)
 		case rsp := <-respChan:
+			r.ackEpoch(rsp)
+			r.trackLag(rsp)
+			r.updateTimings(rsp.GetFrom(), rsp.Timings)
 			r.processHeartbeatResponseHandler(&ackGranted, rsp)
+			leaseRenewed = r.checkLease(ackGranted, htSentAt, leaseRenewed)
+		case <-r.leaseExpired():
+			if remaining := r.leaseRemaining(); remaining > 0 {
+				r.resetLeaseTimeout(remaining)
+				break
+			}
+			r.WARNING("lease[%vms].expired.no.majority.heartbeat.acks.fence.myself", r.getLeaseTimeout())
+			r.fenceByLease()
 		case e := <-r.c:
 			switch e.Type {
 			// 1) Heartbeat
@@ -148,6 +185,15 @@ func (r *Leader) Loop() {
 				req := e.request.(*model.RaftRPCRequest)
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+			// 5) DRPromote
+			case MsgRaftDRPromote:
+				req := e.request.(*model.RaftDRPromoteRPCRequest)
+				e.response <- r.drPromoteAsync(req)
 			default:
 				r.ERROR("get.unknown.request[%+v]", e.Type)
 			}
@@ -172,6 +218,8 @@ func (r *Leader) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftR
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	r.IncLeaderGetHeartbeatRequests()
 	if !r.checkRequest(req) {
@@ -193,14 +241,14 @@ func (r *Leader) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftR
 		r.ERROR("get.heartbeat.from[N:%v, V:%v, E:%v].in.same.viewid", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 
 		// degrade to FOLLOWER
-		r.degradeToFollower()
+		r.degradeToFollower(degradeSameViewID)
 
 	// new leader eggs
 	case vidiff < 0:
 		r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].down.follower", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 
 		// degrade to FOLLOWER
-		r.degradeToFollower()
+		r.degradeToFollower(degradeHigherViewID)
 	}
 	return rsp
 }
@@ -217,13 +265,16 @@ func (r *Leader) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftR
 // 1. ErrorInvalidRequest: the request.From is not a member of this cluster
 // 2. ErrorInvalidViewID: request viewid is old
 // 3. ErrorInvalidGTID: the CANDIDATE has the smaller Read_Master_Log_Pos
-// 4. OK: give a vote
+// 4. ErrorInvalidEpochID: the CANDIDATE has the stale membership epoch
+// 5. OK: give a vote
 func (r *Leader) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
 	rsp := model.NewRaftRPCResponse(model.OK)
 	rsp.Raft.From = r.getID()
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	r.IncLeaderGetVoteRequests()
 	if !r.checkRequest(req) {
@@ -241,11 +292,17 @@ func (r *Leader) processRequestVoteRequest(req *model.RaftRPCRequest) *model.Raf
 			rsp.RetCode = model.ErrorInvalidViewID
 			return rsp
 		}
+
+		if !r.checkRequestEpoch(req) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.epochid[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getEpochID())
+			rsp.RetCode = model.ErrorInvalidEpochID
+			return rsp
+		}
 	}
 
 	// 2. check master GTID
 	{
-		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID)
+		greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID, r.conf.GTIDTieBreak)
 		if err != nil {
 			r.ERROR("process.requestvote.get.gtid.error[%v].ret.ErrorMySQLDown", err)
 			rsp.RetCode = model.ErrorMySQLDown
@@ -272,12 +329,12 @@ func (r *Leader) processRequestVoteRequest(req *model.RaftRPCRequest) *model.Raf
 			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].degrade.to.follower", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 			r.updateView(req.GetViewID(), noLeader)
 			// downgrade to FOLLOWER
-			r.degradeToFollower()
+			r.degradeToFollower(degradeHigherViewID)
 		}
 	}
 
 	// 4. voted for this candidate
-	r.votedFor = req.GetFrom()
+	r.setVotedFor(req.GetFrom())
 	return rsp
 }
 
@@ -317,17 +374,19 @@ func (r *Leader) processHeartbeatResponse(ackGranted *int, rsp *model.RaftRPCRes
 		if rsp.RetCode == model.ErrorInvalidViewID {
 			r.WARNING("send.heartbeat.get.rsp[N:%v, V:%v, E:%v].error[%v].degrade.to.follower", rsp.GetFrom(), rsp.GetViewID(), rsp.GetEpochID(), rsp.RetCode)
 			// downgrade to FOLLOWER
-			r.degradeToFollower()
+			r.degradeToFollower(degradeInvalidViewID)
 		}
 	} else {
 		if rsp.Raft.State != IDLE.String() {
 			*ackGranted++
 		}
-		// find the smallest binlog
-		if r.relayMasterLogFile == "" {
-			r.relayMasterLogFile = rsp.Relay_Master_Log_File
-		} else if strings.Compare(r.relayMasterLogFile, rsp.Relay_Master_Log_File) > 0 {
-			r.relayMasterLogFile = rsp.Relay_Master_Log_File
+		// find the smallest binlog, the WITNESS has no binlog
+		if rsp.Raft.State != WITNESS.String() {
+			if r.relayMasterLogFile == "" {
+				r.relayMasterLogFile = rsp.Relay_Master_Log_File
+			} else if strings.Compare(r.relayMasterLogFile, rsp.Relay_Master_Log_File) > 0 {
+				r.relayMasterLogFile = rsp.Relay_Master_Log_File
+			}
 		}
 
 		// to reset nextPuregeBinlog:
@@ -348,21 +407,24 @@ func (r *Leader) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCRes
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
-func (r *Leader) degradeToFollower() {
-	r.WARNING("degrade.to.follower.stop.the.vip...")
-	if err := r.leaderStopShellCommand(); err != nil {
-		r.ERROR("stopshell.error[%v]", err)
-	}
+func (r *Leader) degradeToFollower(reason string) {
+	r.WARNING("degrade.to.follower[%v].stop.the.vip...", reason)
+	r.emitDegrade(FOLLOWER, reason)
+	r.runPreDemoteHooks()
 
+	r.leaseStop()
 	r.purgeBinlogStop()
 	r.checkSemiSyncStop()
 	r.checkGTIDStop()
 	r.IncLeaderDegrades()
 	r.setState(FOLLOWER)
 	r.isDegradeToFollower = true
+	r.runPostDemoteHooks()
 }
 
 // prepareSettingsAsync
@@ -387,6 +449,7 @@ func (r *Leader) prepareSettingsAsync() {
 		r.SetRaftMysqlStatus(model.RAFTMYSQL_WAITUNTILAFTERGTID)
 		if err := r.mysql.WaitUntilAfterGTID(gtid.Retrieved_GTID_Set); err != nil {
 			r.ERROR("mysql.WaitUntilAfterGTID.error[%v]", err)
+			r.emitDegrade(FOLLOWER, degradeWaitUntilAfterGTID)
 			r.setState(FOLLOWER)
 			r.isDegradeToFollower = true
 			return
@@ -394,60 +457,130 @@ func (r *Leader) prepareSettingsAsync() {
 		r.ResetRaftMysqlStatus()
 		r.WARNING("mysql.WaitUntilAfterGTID.done")
 
-		// MySQL2. change to master
-		r.WARNING("2. mysql.ChangeToMaster.prepare")
-		if err := r.mysql.ChangeToMaster(); err != nil {
-			r.ERROR("mysql.ChangeToMaster.error[%v]", err)
+		// the standby leader replicates from the primary leader and stays read-only until dr-promote
+		if r.isStandby() {
+			r.WARNING("standby.of%v.replicate.from.the.primary.leader", r.conf.StandbyOf)
+			if err := r.mysql.SetReadOnly(); err != nil {
+				r.ERROR("mysql.SetReadOnly.error[%v]", err)
+			}
+			r.standbyStart()
+			return
+		}
+
+		// pre-promote hooks, abort makes us step down before MySQL changes to master
+		if err := r.runPromoteHooks(HookPrePromote); err != nil {
+			r.ERROR("pre-promote.hooks.error[%v].step.down", err)
+			r.emitDegrade(FOLLOWER, degradePrePromoteHook)
 			r.setState(FOLLOWER)
 			r.isDegradeToFollower = true
 			return
 		}
-		r.WARNING("mysql.ChangeToMaster.done")
 
-		// MySQL3. enable semi-sync on master
-		// wait slave ack
-		r.WARNING("3. mysql.EnableSemiSyncMaster.prepare")
-		if err := r.mysql.EnableSemiSyncMaster(); err != nil {
-			// WTF, what can we do?
-			r.ERROR("mysql.EnableSemiSyncMaster.error[%v]", err)
-		}
-		r.WARNING("mysql.EnableSemiSyncMaster.done")
-
-		// MySQL4. set mysql master system variables
-		r.WARNING("4.mysql.SetSysVars.prepare")
-		r.mysql.SetMasterGlobalSysVar()
-		r.WARNING("mysql.SetSysVars.done")
-
-		// MySQL5. set mysql to read/write
-		r.WARNING("5. mysql.SetReadWrite.prepare")
-		if err := r.mysql.SetReadWrite(); err != nil {
-			// WTF, what can we do?
-			r.ERROR("mysql.SetReadWrite.error[%v]", err)
+		// fence the old leader, make sure it can't serve the writes before we do
+		r.WARNING("fence.old.leader[%v].prepare", r.oldLeader)
+		if err := r.fenceOldLeader(); err != nil {
+			if r.getState() != LEADER {
+				r.ERROR("fence.old.leader.error[%v].i.am.not.leader[%v]", err, r.getState())
+				return
+			}
+			r.ERROR("fence.old.leader.error[%v].step.down", err)
+			r.emitDegrade(FOLLOWER, degradeFenceTimeout)
+			r.setState(FOLLOWER)
+			r.isDegradeToFollower = true
+			return
 		}
-		r.WARNING("mysql.SetReadWrite.done")
-		r.WARNING("6. start.vip.prepare")
-		if r.initRole == LEADER {
-			// The -r LEADER is specified at startup server, ndicates that the current node
-			// has previously executed leaderStartShellCommand，skip this one.
-			r.initRole = UNKNOW
-			r.WARNING("the.init.role.is.leader.skip")
-		} else if err := r.leaderStartShellCommand(); err != nil {
-			// TODO(array): what todo?
-			r.ERROR("leader.StartShellCommand.error[%v]", err)
+
+		if err := r.promoteMySQL(); err != nil {
+			return
 		}
-		r.WARNING("start.vip.done")
 		r.WARNING("async.setting.all.done....")
 	}()
 }
 
+// promoteMySQL changes the MySQL to the writable master and runs the post-promote hooks.
+func (r *Leader) promoteMySQL() error {
+	// MySQL2. change to master
+	r.WARNING("2. mysql.ChangeToMaster.prepare")
+	if err := r.mysql.ChangeToMaster(); err != nil {
+		r.ERROR("mysql.ChangeToMaster.error[%v]", err)
+		r.emitDegrade(FOLLOWER, degradeChangeToMaster)
+		r.setState(FOLLOWER)
+		r.isDegradeToFollower = true
+		return err
+	}
+	r.WARNING("mysql.ChangeToMaster.done")
+
+	// MySQL3. enable semi-sync on master
+	// wait slave ack
+	r.WARNING("3. mysql.EnableSemiSyncMaster.prepare")
+	if err := r.mysql.EnableSemiSyncMaster(); err != nil {
+		// WTF, what can we do?
+		r.ERROR("mysql.EnableSemiSyncMaster.error[%v]", err)
+	}
+	r.WARNING("mysql.EnableSemiSyncMaster.done")
+
+	// MySQL4. set mysql master system variables
+	r.WARNING("4.mysql.SetSysVars.prepare")
+	r.mysql.SetMasterGlobalSysVar()
+	r.WARNING("mysql.SetSysVars.done")
+
+	// MySQL5. set mysql to read/write
+	if r.getState() != LEADER {
+		r.ERROR("i.am.not.leader[%v].skip.mysql.SetReadWrite", r.getState())
+		return errors.Errorf("i.am.not.leader[%v]", r.getState())
+	}
+	r.WARNING("5. mysql.SetReadWrite.prepare")
+	if err := r.mysql.SetReadWrite(); err != nil {
+		// WTF, what can we do?
+		r.ERROR("mysql.SetReadWrite.error[%v]", err)
+	}
+	r.WARNING("mysql.SetReadWrite.done")
+	r.WARNING("6. start.vip.prepare")
+	if r.initRole == LEADER {
+		// The -r LEADER is specified at startup server, ndicates that the current node
+		// has previously executed the post-promote hooks，skip this one.
+		r.initRole = UNKNOW
+		r.WARNING("the.init.role.is.leader.skip")
+	} else if err := r.runPromoteHooks(HookPostPromote); err != nil {
+		// TODO(array): what todo?
+		r.ERROR("post-promote.hooks.error[%v]", err)
+	}
+	r.WARNING("start.vip.done")
+	return nil
+}
+
+// runPreDemoteHooks runs the pre-demote hooks before the leader steps down.
+func (r *Leader) runPreDemoteHooks() {
+	if err := r.runHooks(HookPreDemote, r.getID(), noLeader); err != nil {
+		r.ERROR("pre-demote.hooks.error[%v]", err)
+	}
+}
+
+// runPostDemoteHooks runs the post-demote hooks after the leader is not LEADER any more.
+func (r *Leader) runPostDemoteHooks() {
+	if err := r.runHooks(HookPostDemote, r.getID(), noLeader); err != nil {
+		r.ERROR("post-demote.hooks.error[%v]", err)
+	}
+}
+
 func (r *Leader) purgeBinlogStart() {
-	r.purgeBinlogTick = common.NormalTicker(r.conf.PurgeBinlogInterval)
-	go func(leader *Leader) {
-		for range leader.purgeBinlogTick.C {
+	r.purgeBinlogInterval = r.getPurgeBinlogInterval()
+	r.purgeBinlogTick = common.NewNormalTicker(r.clock, r.purgeBinlogInterval)
+	go func(leader *Leader, tick *common.Ticker) {
+		for range tick.C {
 			leader.purgeBinlog()
 		}
-	}(r)
-	r.INFO("purge.binlog.start[%vms]...", r.conf.PurgeBinlogInterval)
+	}(r, r.purgeBinlogTick)
+	r.INFO("purge.binlog.start[%vms]...", r.purgeBinlogInterval)
+}
+
+// checkPurgeBinlogInterval restarts the purge binlog ticker if the interval is changed at runtime.
+func (r *Leader) checkPurgeBinlogInterval() {
+	if interval := r.getPurgeBinlogInterval(); interval != r.purgeBinlogInterval {
+		r.WARNING("purge.binlog.interval.changed.from[%vms].to[%vms].restart", r.purgeBinlogInterval, interval)
+		r.purgeBinlogTick.Stop()
+		r.purgeBinlogStart()
+	}
 }
 
 func (r *Leader) purgeBinlogStop() {
@@ -482,7 +615,7 @@ func (r *Leader) purgeBinlog() {
 
 func (r *Leader) checkSemiSyncStart() {
 	interval := r.getElectionTimeout() / 2
-	r.checkSemiSyncTick = common.NormalTicker(interval)
+	r.checkSemiSyncTick = common.NewNormalTicker(r.clock, interval)
 	go func(leader *Leader) {
 		for range leader.checkSemiSyncTick.C {
 			leader.checkSemiSync()
@@ -503,8 +636,14 @@ func (r *Leader) checkSemiSync() {
 		return
 	}
 
+	// the standby leader is a replica of the primary, it serves no writes
+	if r.isStandby() {
+		return
+	}
+
+	// the WITNESS never acks the semi-sync
 	min := 3
-	cur := r.getMembers()
+	cur := r.getDataMembers()
 	if cur < min {
 		if err := r.mysql.SetSemiSyncMasterTimeout(r.semiSyncTimeoutFor2Nodes); err != nil {
 			r.ERROR("mysql.set.semi-sync.master.timeout.to.default.error[%v]", err)
@@ -513,7 +652,7 @@ func (r *Leader) checkSemiSync() {
 		if err := r.mysql.EnableSemiSyncMaster(); err != nil {
 			r.ERROR("mysql.enable.semi-sync.error[%v]", err)
 		}
-		if err := r.mysql.SetSemiWaitSlaveCount((cur - 1) / 2); err != nil {
+		if err := r.mysql.SetSemiWaitSlaveCount(r.getSemiSyncWaitCount(cur)); err != nil {
 			r.ERROR("mysql.set.semi.wait.slave.count.error[%v]", err)
 		}
 		if err := r.mysql.SetSemiSyncMasterTimeout(semisyncTimeout); err != nil {
@@ -524,7 +663,7 @@ func (r *Leader) checkSemiSync() {
 
 func (r *Leader) checkGTIDStart() {
 	interval := r.getElectionTimeout() / 2
-	r.checkGTIDTick = common.NormalTicker(interval)
+	r.checkGTIDTick = common.NewNormalTicker(r.clock, interval)
 	go func(leader *Leader) {
 		for range leader.checkGTIDTick.C {
 			leader.checkGTID()
@@ -547,13 +686,68 @@ func (r *Leader) checkGTID() {
 	}
 }
 
+// leaseStart starts the lease timer, the lease is renewed by the candidate when it broadcasts the voterequests.
+func (r *Leader) leaseStart() {
+	if r.getLeaseTimeout() == 0 {
+		r.INFO("lease.disabled")
+		return
+	}
+	r.resetLeaseTimeout(r.leaseRemaining())
+	r.INFO("lease.start[%vms].remaining[%v]...", r.getLeaseTimeout(), r.leaseRemaining())
+}
+
+func (r *Leader) leaseStop() {
+	common.TimerRelease(r.leaseTick)
+	r.leaseTick = nil
+}
+
+func (r *Leader) resetLeaseTimeout(d time.Duration) {
+	common.TimerRelease(r.leaseTick)
+	r.leaseTick = r.clock.NewTimer(d)
+}
+
+// leaseExpired returns the channel of the lease timer, it blocks forever if the lease is disabled.
+func (r *Leader) leaseExpired() <-chan time.Time {
+	if r.leaseTick == nil {
+		return nil
+	}
+	return r.leaseTick.C
+}
+
+// checkLease renews the lease once the heartbeat acks reach the quorums.
+// the followers reset their election timeout after htSentAt, so the lease starts from there.
+// for the cluster less than 3 nodes, the follower can't win the election without our vote,
+// the lease is always renewed.
+func (r *Leader) checkLease(ackGranted int, htSentAt time.Time, renewed bool) bool {
+	if !renewed && (ackGranted >= r.getQuorums() || r.getMembers() < 3) {
+		r.renewLease(htSentAt)
+		return true
+	}
+	return renewed
+}
+
+// fenceByLease
+// the lease expired, the majority may elect a new leader soon,
+// so set the MySQL super_read_only and run the stop command before that
+func (r *Leader) fenceByLease() {
+	r.IncLeaderLeaseExpires()
+	if err := r.mysql.SetReadOnly(); err != nil {
+		r.ERROR("lease.expired.mysql.SetReadOnly.error[%v]", err)
+	}
+	r.degradeToFollower(degradeLeaseExpired)
+}
+
 func (r *Leader) stateInit() {
 	r.WARNING("state.init")
 	r.updateStateBegin()
+	r.bootstrapClusterID()
+	r.leaseStart()
 	r.purgeBinlogStart()
 	r.checkSemiSyncStart()
 	r.checkGTIDStart()
 	r.prepareSettingsAsync()
+	r.rollbackChange()
+	r.resetLags()
 	r.isDegradeToFollower = false
 
 	r.WARNING("state.machine.run")
@@ -562,16 +756,20 @@ func (r *Leader) stateInit() {
 func (r *Leader) stateExit() {
 	if !r.isDegradeToFollower {
 		r.WARNING("state.machine.exit.stop.the.vip...")
-		if err := r.leaderStopShellCommand(); err != nil {
-			r.ERROR("stopshell.error[%v]", err)
-		}
+		r.runPreDemoteHooks()
 
 		r.purgeBinlogStop()
 		r.checkSemiSyncStop()
 		r.checkGTIDStop()
+
+		// the state has been changed, the loop exits when we are not LEADER
+		r.runPostDemoteHooks()
 	}
 	// Wait for the LEADER state-machine async work done.
 	r.wg.Wait()
+	r.standbyStop()
+	r.setTransferCampaign(false)
+	r.resetLags()
 	r.WARNING("leader.state.machine.exit.done")
 }
 
@@ -10,6 +10,8 @@ package raft
 
 import (
 	"model"
+	"mysql"
+	"time"
 )
 
 // LEARNER is a special STATE with other FOLLOWER/CANDICATE/LEADER states.
@@ -20,6 +22,9 @@ import (
 // Because of we bring LEARNER state in RaftRPCResponse as vote-request response,
 // the LEARNER vote will be filtered out by other CANDIDATEs.
 // LEARNER is one member of a RAFT cluster but without the rights to vote.
+//
+// If learner-promote-after is set, the LEARNER watches its lag behind the leader GTID in the heartbeats,
+// and promotes itself to FOLLOWER when it stays within learner-promote-lag for learner-promote-after.
 
 // Learner tuple.
 type Learner struct {
@@ -77,6 +82,15 @@ func (r *Learner) Loop() {
 				rsp := r.processPingRequestHandler(req)
 				e.response <- rsp
 
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
+
 			default:
 				r.ERROR("get.unknown.request[%v]", e.Type)
 			}
@@ -95,7 +109,10 @@ func (r *Learner) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.GTID = r.mysql.GetReplStatus()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -106,6 +123,8 @@ func (r *Learner) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 	epochdiff := (int)(r.getEpochID() - req.GetEpochID())
 	switch {
 	case viewdiff <= 0:
+		r.updateClusterFreeze(req.GetFrom(), req.ClusterFreeze)
+
 		// MySQL1: disable master semi-sync because I am a slave
 		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
 			r.ERROR("mysql.DisableSemiSyncMaster.error[%v]", err)
@@ -125,12 +144,81 @@ func (r *Learner) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetWitnesses(), req.GetNextPeers())
+		}
+
+		// promotion: caught up with the leader long enough
+		if r.trackProgress(req) {
+			r.upgradeToFollower()
 		}
 	}
 	return rsp
 }
 
+// trackProgress records the lag behind the leader GTID in the heartbeat,
+// returns true if the learner has been within learner-promote-lag for learner-promote-after.
+// the progress starts from scratch if the heartbeats stopped longer than the election timeout.
+func (r *Learner) trackProgress(req *model.RaftRPCRequest) bool {
+	if r.conf.LearnerPromoteAfter <= 0 {
+		return false
+	}
+
+	lag := int64(-1)
+	master := req.GetGTID().Executed_GTID_Set
+	gtid, err := r.mysql.GetGTID()
+	switch {
+	case err != nil:
+		r.ERROR("learner.get.gtid.error[%v]", err)
+	case !gtid.Slave_SQL_Running:
+		r.WARNING("learner.sql_thread.is.not.running")
+	case master != "":
+		if n, err := mysql.GTIDSetLag(master, gtid.Executed_GTID_Set); err != nil {
+			r.ERROR("learner.lag.of.leader[%v].gtid.set.error[%v]", req.GetFrom(), err)
+		} else {
+			lag = int64(n)
+		}
+	}
+
+	now := r.clock.Now().UnixNano() / int64(time.Millisecond)
+	r.learnerMutex.Lock()
+	defer r.learnerMutex.Unlock()
+	p := &r.learnerProgress
+	p.LagTransactions = lag
+	p.MaxLag = int64(r.conf.LearnerPromoteLag)
+	p.PromoteAfter = int64(r.conf.LearnerPromoteAfter)
+	last := r.learnerHeartbeatAt
+	r.learnerHeartbeatAt = now
+	if p.CaughtUpSince != 0 && now-last > int64(r.getElectionTimeout()) {
+		r.WARNING("learner.heartbeat.gap[%vms].exceeds.the.election.timeout.restart.the.progress", now-last)
+		p.CaughtUpSince = 0
+	}
+	if lag < 0 || lag > p.MaxLag {
+		p.CaughtUpSince = 0
+		return false
+	}
+	if p.CaughtUpSince == 0 {
+		p.CaughtUpSince = now
+	}
+	return now-p.CaughtUpSince >= p.PromoteAfter
+}
+
+// resetProgress starts the progress toward the promotion from scratch.
+func (r *Learner) resetProgress() {
+	r.learnerMutex.Lock()
+	defer r.learnerMutex.Unlock()
+	r.learnerProgress = model.RaftLearnerProgress{
+		LagTransactions: -1,
+		MaxLag:          int64(r.conf.LearnerPromoteLag),
+		PromoteAfter:    int64(r.conf.LearnerPromoteAfter),
+	}
+}
+
+// upgradeToFollower promotes the caught up learner to FOLLOWER, it votes from now on.
+func (r *Learner) upgradeToFollower() {
+	r.WARNING("learner.caught.up.with.the.leader[%+v].promote.to.follower", r.getLearnerProgress())
+	r.setState(FOLLOWER)
+}
+
 // processRequestVoteRequest
 // EFFECT
 // handles the requestvote request from other CANDIDATEs
@@ -144,6 +232,8 @@ func (r *Learner) processRequestVoteRequest(req *model.RaftRPCRequest) *model.Ra
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -158,14 +248,18 @@ func (r *Learner) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCRe
 	rsp.Raft.ViewID = r.getViewID()
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
 	return rsp
 }
 
 func (r *Learner) stateInit() {
+	r.resetProgress()
+
 	// 1. stop vip
-	if err := r.leaderStopShellCommand(); err != nil {
+	if err := r.runDemoteHooks(HookPostDemote); err != nil {
 		// TODO(array): what todo?
-		r.ERROR("stopshell.error[%v]", err)
+		r.ERROR("post-demote.hooks.error[%v]", err)
 	}
 
 	// MySQL1: set readonly
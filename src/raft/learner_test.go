@@ -0,0 +1,131 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"database/sql"
+	"model"
+	"mysql"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the LEARNER promotes itself to FOLLOWER once it stays caught up with the leader.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts with learner-promote-after, wait the leader eggs
+// 2. set a lagging follower to LEARNER, it stays LEARNER and reports the lag
+// 3. the learner catches up, it promotes itself to FOLLOWER after learner-promote-after
+func TestRaftLearnerPromote(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts with learner-promote-after, wait the leader eggs
+	heartbeat := time.Duration(rafts[0].getHeartbeatTimeout()) * time.Millisecond
+	rafts[0].conf.LearnerPromoteLag = 2
+	rafts[0].conf.LearnerPromoteAfter = int(heartbeat/time.Millisecond) * 5
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	learner := rafts[(whoisleader+1)%3]
+
+	// 2. set a lagging follower to LEARNER, it stays LEARNER and reports the lag
+	lagging := mysql.NewMockGTIDA()
+	lagging.GetSlaveGTIDFn = func(db *sql.DB) (*model.GTID, error) {
+		gtid, err := mysql.GetSlaveGTIDA(db)
+		gtid.Executed_GTID_Set = "052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-30,12446bf7-3219-11e5-9434-080027079e3d:8058-963126"
+		return gtid, err
+	}
+	MockSetMysqlHandler(learner, lagging)
+	{
+		rsp := model.NewHARPCResponse(model.OK)
+		assert.Nil(t, learner.GetHARPC().HASetLearner(model.NewHARPCRequest(), rsp))
+		assert.Equal(t, model.OK, rsp.RetCode)
+	}
+	time.Sleep(heartbeat * 8)
+	assert.Equal(t, LEARNER, learner.getState())
+	progress := learner.getLearnerProgress()
+	assert.NotNil(t, progress)
+	if progress != nil {
+		assert.Equal(t, int64(6), progress.LagTransactions)
+		assert.Equal(t, int64(2), progress.MaxLag)
+		assert.Equal(t, int64(0), progress.CaughtUpSince)
+	}
+
+	// 3. the learner catches up, it promotes itself to FOLLOWER after learner-promote-after
+	MockSetMysqlHandler(learner, mysql.NewMockGTIDA())
+	time.Sleep(heartbeat * 2)
+	assert.Equal(t, LEARNER, learner.getState())
+	time.Sleep(heartbeat * 8)
+	assert.Equal(t, FOLLOWER, learner.getState())
+	assert.Nil(t, learner.getLearnerProgress())
+	found := false
+	for _, event := range learner.GetHistory(0) {
+		if event.Type == EventStateChange && event.From == LEARNER.String() && event.To == FOLLOWER.String() {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+// TEST EFFECTS:
+// test the LEARNER progress starts from scratch after the heartbeats stopped.
+//
+// TEST PROCESSES:
+// 1. the learner is caught up in the first heartbeat
+// 2. the next heartbeat comes after a leaderless gap, the progress restarts
+// 3. the learner is promoted after learner-promote-after of the sustained heartbeats
+func TestRaftLearnerHeartbeatGap(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 1, -1)
+	defer cleanup()
+	rafts[0].Start()
+
+	conf := *rafts[0].conf
+	conf.LearnerPromoteLag = 2
+	conf.LearnerPromoteAfter = rafts[0].getElectionTimeout()
+	raft := NewRaft(names[0], &conf, 10000, log, rafts[0].mysql, FOLLOWER)
+	clock := common.NewFakeClock(time.Now())
+	raft.SetClock(clock)
+	raft.setState(LEARNER)
+	heartbeat := time.Duration(raft.getHeartbeatTimeout()) * time.Millisecond
+	election := time.Duration(raft.getElectionTimeout()) * time.Millisecond
+
+	gtid, err := raft.mysql.GetGTID()
+	assert.Nil(t, err)
+	req := model.NewRaftRPCRequest()
+	req.GTID = gtid
+
+	// 1. the learner is caught up in the first heartbeat
+	assert.False(t, raft.LN.trackProgress(req))
+	since := raft.getLearnerProgress().CaughtUpSince
+	assert.NotEqual(t, int64(0), since)
+
+	// 2. the next heartbeat comes after a leaderless gap, the progress restarts
+	clock.Advance(election * 2)
+	assert.False(t, raft.LN.trackProgress(req))
+	assert.True(t, raft.getLearnerProgress().CaughtUpSince > since)
+
+	// 3. the learner is promoted after learner-promote-after of the sustained heartbeats
+	promoted := false
+	for i := 0; i < 100 && !promoted; i++ {
+		clock.Advance(heartbeat)
+		promoted = raft.LN.trackProgress(req)
+	}
+	assert.True(t, promoted)
+}
@@ -0,0 +1,61 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"sync/atomic"
+	"time"
+)
+
+// getLeaseTimeout returns the leader lease(ms), 0 means disabled.
+// the lease must be less than the election timeout of the followers,
+// so the leader fences itself before any other node can win the election.
+func (r *Raft) getLeaseTimeout() int {
+	lease := r.conf.LeaderLeaseTimeout
+	if lease <= 0 {
+		return 0
+	}
+	if lease >= r.getElectionTimeout() {
+		lease = r.getElectionTimeout() - r.getHeartbeatTimeout()
+	}
+	if lease <= 0 {
+		return 0
+	}
+	return lease
+}
+
+// renewLease renews the lease from the time the heartbeats were sent.
+func (r *Raft) renewLease(at time.Time) {
+	atomic.StoreInt64(&r.leaseRenewAt, at.UnixNano())
+}
+
+// leaseRemaining returns the remaining time of the lease.
+func (r *Raft) leaseRemaining() time.Duration {
+	renewAt := time.Unix(0, atomic.LoadInt64(&r.leaseRenewAt))
+	remaining := time.Duration(r.getLeaseTimeout())*time.Millisecond - r.clock.Now().Sub(renewAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// getLease returns the lease timings for the raft status.
+func (r *Raft) getLease() model.RaftLease {
+	lease := model.RaftLease{
+		Timeout: int64(r.getLeaseTimeout()),
+	}
+	if renewAt := atomic.LoadInt64(&r.leaseRenewAt); renewAt > 0 {
+		lease.RenewAt = renewAt / int64(time.Millisecond)
+	}
+	if lease.Timeout > 0 && r.getState() == LEADER {
+		lease.Remaining = int64(r.leaseRemaining() / time.Millisecond)
+	}
+	return lease
+}
@@ -0,0 +1,217 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maintenanceFile is the file for storing the maintenance of this node
+	maintenanceFile = "raft.maintenance.json"
+
+	// maintenanceVersion is the version of the maintenance file format, bump it when the format changes.
+	maintenanceVersion = 1
+)
+
+// recoverMaintenance restores the maintenance from the maintenance file, so it survives the restart.
+func (r *Raft) recoverMaintenance() {
+	maintenancePath := filepath.Join(r.conf.MetaDatadir, maintenanceFile)
+	maintenance := &model.RaftMaintenance{}
+	ok, err := readVersionedJSON(maintenancePath, maintenanceVersion, maintenance)
+	if err != nil {
+		r.PANIC("read.maintenance.file[%v].error[%+v]", maintenancePath, err)
+	}
+	if !ok {
+		return
+	}
+	r.maintenance = maintenance
+	r.WARNING("recovery.maintenance.from[%v].maintenance[%+v]", maintenancePath, *r.maintenance)
+}
+
+// writeMaintenance persists the maintenance, nil removes the maintenance file.
+// the caller must hold the maintenanceMutex.
+func (r *Raft) writeMaintenance() error {
+	maintenancePath := filepath.Join(r.conf.MetaDatadir, maintenanceFile)
+	if r.maintenance == nil {
+		if err := os.Remove(maintenancePath); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+
+	return writeVersionedJSON(maintenancePath, maintenanceVersion, r.maintenance)
+}
+
+// getMaintenance returns a copy of the maintenance, nil if this node is not in maintenance.
+// the expired maintenance is cleared here, the node re-enables itself.
+func (r *Raft) getMaintenance() *model.RaftMaintenance {
+	r.maintenanceMutex.Lock()
+	maintenance := r.maintenance
+	expired := maintenance != nil && r.clock.Now().UnixNano()/int64(time.Millisecond) >= maintenance.Until
+	if expired {
+		r.maintenance = nil
+		if err := r.writeMaintenance(); err != nil {
+			r.ERROR("maintenance.expired.remove.maintenance.file.error[%+v]", err)
+		}
+	}
+	r.maintenanceMutex.Unlock()
+
+	if maintenance == nil {
+		return nil
+	}
+	if expired {
+		r.WARNING("maintenance[%+v].expired.re-enable.myself", *maintenance)
+		r.emitMaintenance(false, "expired")
+		return nil
+	}
+	m := *maintenance
+	return &m
+}
+
+// inMaintenance returns true if this node is in maintenance, it never becomes CANDIDATE then.
+func (r *Raft) inMaintenance() bool {
+	return r.getMaintenance() != nil
+}
+
+// setMaintenance sets and persists the maintenance, nil means exit.
+func (r *Raft) setMaintenance(maintenance *model.RaftMaintenance) error {
+	r.maintenanceMutex.Lock()
+	defer r.maintenanceMutex.Unlock()
+
+	old := r.maintenance
+	r.maintenance = maintenance
+	if err := r.writeMaintenance(); err != nil {
+		r.maintenance = old
+		return err
+	}
+	return nil
+}
+
+// enterMaintenance
+// EFFECT
+// puts this node in maintenance for the duration:
+// 1. if this node is the LEADER, hand the leadership over to the most caught-up FOLLOWER
+// 2. persist the maintenance, this node refuses to be CANDIDATE until it expires or exits
+//
+// RETURNS
+// 1. ErrorInvalidRequest: the duration is not positive, or this node is STOPPED/CANDIDATE
+// 2. ErrorNoTransferTarget: this node is the LEADER but no FOLLOWER can take over
+// 3. the errors of transferLeader: the leadership is unchanged, this node is not in maintenance
+// 4. OK: this node is in maintenance
+func (r *Raft) enterMaintenance(duration time.Duration, reason string, rsp *model.HAMaintenanceRPCResponse) {
+	defer func() {
+		rsp.Leader = r.getLeader()
+		rsp.Maintenance = r.getMaintenance()
+	}()
+
+	if duration <= 0 {
+		r.ERROR("enter.maintenance.duration[%v].must.be.positive", duration)
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+
+	switch state := r.getState(); state {
+	case STOPPED, CANDIDATE:
+		r.ERROR("enter.maintenance.but.i.am[%v]", state)
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+
+	now := r.clock.Now()
+	maintenance := &model.RaftMaintenance{
+		Reason: reason,
+		Since:  now.UnixNano() / int64(time.Millisecond),
+		Until:  now.Add(duration).UnixNano() / int64(time.Millisecond),
+	}
+	// set the maintenance first, we can't campaign after the leadership is handed over
+	if err := r.setMaintenance(maintenance); err != nil {
+		r.ERROR("enter.maintenance.write.maintenance.file.error[%+v]", err)
+		rsp.RetCode = err.Error()
+		return
+	}
+
+	if r.getState() == LEADER {
+		to := r.pickTransferTarget()
+		if to == "" {
+			r.ERROR("enter.maintenance.but.no.follower.can.take.over.the.leadership.lags[%+v]", r.getLags())
+			rsp.RetCode = model.ErrorNoTransferTarget
+		} else {
+			r.WARNING("enter.maintenance.transfer.leader.to[%v]", to)
+			transfer := model.NewHATransferLeaderRPCResponse(model.OK)
+			r.transferLeader(to, transfer)
+			rsp.RetCode = transfer.RetCode
+		}
+		if rsp.RetCode != model.OK {
+			if err := r.setMaintenance(nil); err != nil {
+				r.ERROR("enter.maintenance.remove.maintenance.file.error[%+v]", err)
+			}
+			return
+		}
+	}
+	r.WARNING("enter.maintenance[%+v]", *maintenance)
+	r.emitMaintenance(true, reason)
+	rsp.RetCode = model.OK
+}
+
+// exitMaintenance takes this node out of maintenance, it's ok if the node is not in maintenance.
+func (r *Raft) exitMaintenance(rsp *model.HAMaintenanceRPCResponse) {
+	defer func() {
+		rsp.Leader = r.getLeader()
+	}()
+
+	maintenance := r.getMaintenance()
+	if err := r.setMaintenance(nil); err != nil {
+		r.ERROR("exit.maintenance.remove.maintenance.file.error[%+v]", err)
+		rsp.RetCode = err.Error()
+		return
+	}
+	if maintenance != nil {
+		r.WARNING("exit.maintenance[%+v]", *maintenance)
+		r.emitMaintenance(false, "exit")
+	}
+	rsp.RetCode = model.OK
+}
+
+// pickTransferTarget returns the FOLLOWER with the least lag which acked the last heartbeat,
+// "" if there is none.
+func (r *Raft) pickTransferTarget() string {
+	to := ""
+	least := int64(-1)
+	for _, lag := range r.getLags() {
+		if lag.State != FOLLOWER.String() || lag.AckMisses > 0 || lag.LagTransactions < 0 {
+			continue
+		}
+
+		r.mutex.RLock()
+		peer, ok := r.peers[lag.Peer]
+		r.mutex.RUnlock()
+		if !ok || peer.getPriority() == 0 || r.isWitnessPeer(lag.Peer) {
+			continue
+		}
+		if to == "" || lag.LagTransactions < least {
+			to = lag.Peer
+			least = lag.LagTransactions
+		}
+	}
+	return to
+}
+
+func (r *Raft) emitMaintenance(on bool, reason string) {
+	event := model.RaftEvent{Type: EventMaintenance, From: "on", To: "off", Reason: reason}
+	if on {
+		event.From, event.To = "off", "on"
+	}
+	r.emit(event)
+}
@@ -0,0 +1,124 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the leader enters the maintenance, hands over the leadership and never be candidate.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, wait the leader eggs and tracks the lags of the followers,
+//    the priorities are learned from the heartbeat acks, the prevotes are forgotten
+// 2. the leader enters the maintenance, another node is the new leader
+// 3. the node in maintenance refuses the trytoleader
+// 4. the maintenance survives the restart and expires
+// 5. exit the maintenance
+func TestRaftMaintenance(t *testing.T) {
+	var whoisleader int
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, wait the leader eggs and tracks the lags of the followers,
+	//    the priorities are learned from the heartbeat acks, the prevotes are forgotten
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader = MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	leader.mutex.RLock()
+	for _, peer := range leader.peers {
+		peer.setPriority(0)
+	}
+	leader.mutex.RUnlock()
+	for i := 0; i < 100 && leader.pickTransferTarget() == ""; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.NotEqual(t, "", leader.pickTransferTarget())
+
+	// 2. the leader enters the maintenance, another node is the new leader
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHAEnterMaintenance
+		req := model.NewHAMaintenanceRPCRequest()
+		req.Duration = int64(time.Hour / time.Millisecond)
+		req.Reason = "patching"
+		rsp := model.NewHAMaintenanceRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.NotEqual(t, names[whoisleader], rsp.Leader)
+		assert.NotNil(t, rsp.Maintenance)
+		assert.Equal(t, "patching", rsp.Maintenance.Reason)
+	}
+	MockWaitLeaderEggs(rafts, 1)
+	assert.Equal(t, FOLLOWER, leader.getState())
+	assert.True(t, leader.inMaintenance())
+
+	// 3. the node in maintenance refuses the trytoleader
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHATryToLeader
+		req := model.NewHARPCRequest()
+		rsp := model.NewHARPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorInMaintenance, rsp.RetCode)
+		assert.Equal(t, FOLLOWER, leader.getState())
+	}
+
+	// 4. the maintenance survives the restart and expires
+	{
+		restarted := NewRaft(names[whoisleader], leader.conf, 10000, log, leader.mysql, FOLLOWER)
+		clock := common.NewFakeClock(time.Now())
+		restarted.SetClock(clock)
+		maintenance := restarted.getMaintenance()
+		assert.NotNil(t, maintenance)
+		assert.Equal(t, "patching", maintenance.Reason)
+
+		clock.Advance(2 * time.Hour)
+		assert.False(t, restarted.inMaintenance())
+		_, err := os.Stat(filepath.Join(leader.conf.MetaDatadir, maintenanceFile))
+		assert.True(t, os.IsNotExist(err))
+		events := restarted.GetHistory(1)
+		assert.Equal(t, EventMaintenance, events[0].Type)
+		assert.Equal(t, "expired", events[0].Reason)
+	}
+
+	// 5. exit the maintenance
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHAExitMaintenance
+		req := model.NewHAMaintenanceRPCRequest()
+		rsp := model.NewHAMaintenanceRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.False(t, leader.inMaintenance())
+	}
+}
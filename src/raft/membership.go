@@ -0,0 +1,322 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	membershipPollInterval = 100 // ms
+)
+
+// ProposeAddPeer adds a peer through the membership change of the leader,
+// returns the nodes which have adopted the committed epoch.
+// unless force, the peer is refused if the majority of the members would sit in one zone.
+func (r *Raft) ProposeAddPeer(connStr string, force bool) ([]string, string) {
+	return r.changePeers(connStr, true, false, force)
+}
+
+// ProposeAddWitnessPeer adds a witness peer through the membership change of the leader,
+// returns the nodes which have adopted the committed epoch.
+// unless force, the peer is refused if the majority of the members would sit in one zone.
+func (r *Raft) ProposeAddWitnessPeer(connStr string, force bool) ([]string, string) {
+	return r.changePeers(connStr, true, true, force)
+}
+
+// ProposeRemovePeer removes a peer through the membership change of the leader,
+// returns the nodes which have adopted the committed epoch.
+func (r *Raft) ProposeRemovePeer(connStr string) ([]string, string) {
+	return r.changePeers(connStr, false, false, false)
+}
+
+// ProposeAddIdlePeer adds an idle peer on the leader,
+// returns the nodes which have adopted the epoch.
+func (r *Raft) ProposeAddIdlePeer(connStr string) ([]string, string) {
+	return r.changeIdlePeers(connStr, r.AddIdlePeer)
+}
+
+// ProposeRemoveIdlePeer removes an idle peer on the leader,
+// returns the nodes which have adopted the epoch.
+func (r *Raft) ProposeRemoveIdlePeer(connStr string) ([]string, string) {
+	return r.changeIdlePeers(connStr, r.RemoveIdlePeer)
+}
+
+// changeIdlePeers changes one idle peer on the leader, the members take it with the epoch of the heartbeat.
+// the idle peers don't vote, so there is no joint epoch, we only wait for the majority acks of the epoch.
+func (r *Raft) changeIdlePeers(connStr string, change func(string) error) ([]string, string) {
+	if r.getState() != LEADER {
+		r.ERROR("change.idle.peer[%v].but.i.am.not.leader[%v]", connStr, r.getState())
+		return nil, model.ErrorNotLeader
+	}
+	if !atomic.CompareAndSwapInt32(&r.changing, 0, 1) {
+		r.ERROR("change.idle.peer[%v].but.another.change.is.in.progress", connStr)
+		return nil, model.ErrorChangeInProgress
+	}
+	defer atomic.StoreInt32(&r.changing, 0)
+
+	epoch := r.getEpochID()
+	if err := change(connStr); err != nil {
+		r.ERROR("change.idle.peer[%v].error[%v]", connStr, err)
+		return nil, err.Error()
+	}
+	if r.getEpochID() == epoch {
+		return r.getAdopted(epoch), model.OK
+	}
+
+	epoch = r.getEpochID()
+	retCode := r.waitEpochAcks(epoch)
+	adopted := r.getAdopted(epoch)
+	if retCode != model.OK {
+		r.ERROR("change.idle.peer[%v].epoch[%v].acks.error[%v].adopted.by[%v]", connStr, epoch, retCode, adopted)
+		return adopted, retCode
+	}
+	r.WARNING("change.idle.peer[%v].epoch[%v].adopted.by[%v]", connStr, epoch, adopted)
+	return adopted, model.OK
+}
+
+// changePeers changes one peer of the cluster in two epochs:
+// 1. joint: NextPeers is the new configuration, wait for the majority acks of both the old and the new peers
+// 2. commit: Peers is the new configuration, wait for the majority acks of the new peers
+// if the joint epoch can't be acked in MembershipChangeTimeout, the change is rolled back.
+func (r *Raft) changePeers(connStr string, add bool, witness bool, force bool) ([]string, string) {
+	if r.getState() != LEADER {
+		r.ERROR("change.peer[%v].but.i.am.not.leader[%v]", connStr, r.getState())
+		return nil, model.ErrorNotLeader
+	}
+	if !atomic.CompareAndSwapInt32(&r.changing, 0, 1) {
+		r.ERROR("change.peer[%v].but.another.change.is.in.progress", connStr)
+		return nil, model.ErrorChangeInProgress
+	}
+	defer atomic.StoreInt32(&r.changing, 0)
+
+	if add && !force {
+		if retCode := r.checkZonePlacement(connStr); retCode != model.OK {
+			return nil, retCode
+		}
+	}
+
+	// 1. joint epoch
+	epoch, changed := r.beginChange(connStr, add, witness)
+	if !changed {
+		return r.getAdopted(r.getEpochID()), model.OK
+	}
+	r.WARNING("change.peer[%v].add[%v].1.wait.joint.epoch[%v].peers[%v].nextpeers[%v]", connStr, add, epoch, r.getPeers(), r.getNextPeers())
+	if retCode := r.waitEpochAcks(epoch); retCode != model.OK {
+		r.ERROR("change.peer[%v].joint.epoch[%v].acks.error[%v]", connStr, epoch, retCode)
+		if retCode == model.ErrorChangeTimeout {
+			r.WARNING("change.peer[%v].roll.back.to.peers[%v]", connStr, r.getPeers())
+			r.finishChange(r.getPeers())
+		}
+		return nil, retCode
+	}
+
+	// 2. commit epoch
+	epoch = r.finishChange(r.getNextPeers())
+	r.WARNING("change.peer[%v].add[%v].2.wait.commit.epoch[%v].peers[%v]", connStr, add, epoch, r.getPeers())
+	retCode := r.waitEpochAcks(epoch)
+	adopted := r.getAdopted(epoch)
+	if retCode != model.OK {
+		r.ERROR("change.peer[%v].commit.epoch[%v].acks.error[%v].adopted.by[%v]", connStr, epoch, retCode, adopted)
+		return adopted, retCode
+	}
+	r.WARNING("change.peer[%v].committed.epoch[%v].adopted.by[%v]", connStr, epoch, adopted)
+	return adopted, model.OK
+}
+
+// beginChange sets the NextPeers and bumps the epoch,
+// returns false if there is nothing to change.
+func (r *Raft) beginChange(connStr string, add bool, witness bool) (uint64, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// we can't change ourself
+	if connStr == r.getID() {
+		r.WARNING("change.peer[%v].is.myself.skip", connStr)
+		return 0, false
+	}
+
+	exists := false
+	next := []string{}
+	for _, peer := range r.meta.Peers {
+		if peer == connStr {
+			exists = true
+			if !add {
+				continue
+			}
+		}
+		next = append(next, peer)
+	}
+
+	if add {
+		if exists || r.idlePeers[connStr] != nil {
+			r.WARNING("peer[%v].already.exists.in.peers[%+v].can't.add.repeatedly", connStr, r.meta.Peers)
+			return 0, false
+		}
+		next = append(next, connStr)
+		if r.peers[connStr] == nil {
+			r.peers[connStr] = NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+		}
+		if witness {
+			r.meta.Witnesses = append(r.meta.Witnesses, connStr)
+		}
+	} else if !exists {
+		r.WARNING("peer[%v].not.exists.in.peers[%+v]", connStr, r.meta.Peers)
+		return 0, false
+	}
+
+	r.meta.NextPeers = next
+	r.incEpochID()
+	r.writeMeta()
+	return r.getEpochID(), true
+}
+
+// finishChange makes the peers as the configuration, clears the NextPeers and bumps the epoch.
+// it commits the change with the NextPeers, or rolls back with the Peers.
+func (r *Raft) finishChange(peers []string) uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	mark := make(map[string]bool)
+	for _, name := range peers {
+		mark[name] = true
+	}
+	for name, peer := range r.peers {
+		if !mark[name] && r.idlePeers[name] == nil {
+			peer.freePeer()
+			delete(r.peers, name)
+		}
+	}
+
+	witnesses := []string{}
+	for _, name := range r.meta.Witnesses {
+		if mark[name] {
+			witnesses = append(witnesses, name)
+		}
+	}
+
+	r.meta.Peers = peers
+	r.meta.NextPeers = nil
+	r.meta.Witnesses = witnesses
+	r.incEpochID()
+	r.writeMeta()
+	return r.getEpochID()
+}
+
+// rollbackChange rolls back the uncommitted change left by the old leader.
+func (r *Raft) rollbackChange() {
+	if len(r.getNextPeers()) == 0 || atomic.LoadInt32(&r.changing) == 1 {
+		return
+	}
+	r.WARNING("uncommitted.nextpeers[%v].roll.back.to.peers[%v]", r.getNextPeers(), r.getPeers())
+	r.finishChange(r.getPeers())
+}
+
+// waitEpochAcks waits until the majority of Peers(and NextPeers if it's in the joint) acked the epoch.
+func (r *Raft) waitEpochAcks(epoch uint64) string {
+	timeout := r.clock.After(time.Duration(r.conf.MembershipChangeTimeout) * time.Millisecond)
+	for {
+		if r.getState() != LEADER {
+			r.ERROR("wait.epoch[%v].acks.but.i.am.not.leader[%v]", epoch, r.getState())
+			return model.ErrorNotLeader
+		}
+		if r.isJointQuorum(r.getEpochAcked(epoch)) {
+			return model.OK
+		}
+
+		select {
+		case <-timeout:
+			return model.ErrorChangeTimeout
+		case <-r.clock.After(membershipPollInterval * time.Millisecond):
+		}
+	}
+}
+
+// ackEpoch records the EpochID of the heartbeat response.
+func (r *Raft) ackEpoch(rsp *model.RaftRPCResponse) {
+	if rsp.RetCode != model.OK {
+		return
+	}
+	r.epochAcksMutex.Lock()
+	defer r.epochAcksMutex.Unlock()
+	r.epochAcks[rsp.GetFrom()] = rsp.GetEpochID()
+}
+
+// getEpochAcked returns the nodes which have acked the epoch, includes me.
+func (r *Raft) getEpochAcked(epoch uint64) map[string]bool {
+	r.epochAcksMutex.Lock()
+	defer r.epochAcksMutex.Unlock()
+
+	acked := map[string]bool{r.getID(): true}
+	for name, epochid := range r.epochAcks {
+		if epochid >= epoch {
+			acked[name] = true
+		}
+	}
+	return acked
+}
+
+// getAdopted returns the sorted peers which have acked the epoch.
+func (r *Raft) getAdopted(epoch uint64) []string {
+	acked := r.getEpochAcked(epoch)
+	adopted := []string{}
+	for _, name := range r.getPeers() {
+		if acked[name] {
+			adopted = append(adopted, name)
+		}
+	}
+	sort.Strings(adopted)
+	return adopted
+}
+
+// isQuorum returns true if the majority of the members is in the granted.
+func isQuorum(members []string, granted map[string]bool) bool {
+	n := 0
+	for _, name := range members {
+		if granted[name] {
+			n++
+		}
+	}
+	return n >= (len(members)/2)+1
+}
+
+// isJointQuorum returns true if the granted nodes are the majority of the Peers,
+// and the majority of the NextPeers during a membership change.
+func (r *Raft) isJointQuorum(granted map[string]bool) bool {
+	return isQuorum(r.getPeers(), granted) && r.isNextQuorum(granted)
+}
+
+// isNextQuorum returns true if there is no membership change or the granted nodes are the majority of the NextPeers.
+func (r *Raft) isNextQuorum(granted map[string]bool) bool {
+	if next := r.getNextPeers(); len(next) > 0 {
+		return isQuorum(next, granted)
+	}
+	return true
+}
+
+// checkRequestEpoch returns false if the request comes from a node with the stale membership.
+func (r *Raft) checkRequestEpoch(req *model.RaftRPCRequest) bool {
+	return req.GetEpochID() >= r.getEpochID()
+}
+
+// unionPeers returns the peers of a and b without duplicates.
+func unionPeers(a []string, b []string) []string {
+	mark := make(map[string]bool)
+	peers := []string{}
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !mark[name] {
+			mark[name] = true
+			peers = append(peers, name)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,186 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"sort"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the leader removes and adds a peer through the joint membership change.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs, the FOLLOWER can't change the membership
+// 3. leader removes one FOLLOWER, the others adopt the committed epoch
+// 4. leader adds the FOLLOWER back, all the rafts adopt the committed epoch
+func TestRaftMembershipChange(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs, the FOLLOWER can't change the membership
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	removed := rafts[(whoisleader+1)%3]
+	other := rafts[(whoisleader+2)%3]
+	{
+		adopted, retCode := other.ProposeRemovePeer(removed.getID())
+		assert.Equal(t, model.ErrorNotLeader, retCode)
+		assert.Nil(t, adopted)
+	}
+
+	// 3. leader removes one FOLLOWER, the others adopt the committed epoch
+	{
+		adopted, retCode := leader.ProposeRemovePeer(removed.getID())
+		assert.Equal(t, model.OK, retCode)
+		want := []string{leader.getID(), other.getID()}
+		sort.Strings(want)
+		assert.Equal(t, want, adopted)
+
+		for _, raft := range []*Raft{leader, other} {
+			assert.Equal(t, 2, len(raft.GetPeers()))
+			assert.Equal(t, 0, len(raft.getNextPeers()))
+			assert.Equal(t, leader.getEpochID(), raft.getEpochID())
+		}
+	}
+
+	// 4. leader adds the FOLLOWER back, all the rafts adopt the committed epoch
+	{
+		adopted, retCode := leader.ProposeAddPeer(removed.getID(), false)
+		assert.Equal(t, model.OK, retCode)
+		want := append([]string{}, ids...)
+		sort.Strings(want)
+		assert.Equal(t, want, adopted)
+
+		for _, raft := range rafts {
+			assert.Equal(t, 3, len(raft.GetPeers()))
+			assert.Equal(t, 0, len(raft.getNextPeers()))
+		}
+	}
+}
+
+// TEST EFFECTS:
+// test the membership change is rolled back if the new configuration can't get the majority acks.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs and stop one FOLLOWER
+// 3. leader adds a dead peer, the new configuration has 2 acks of 4 members
+// 4. the change timeouts and rolls back
+func TestRaftMembershipChangeRollback(t *testing.T) {
+	var deadpeer = "127.0.0.1:18888"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.conf.MembershipChangeTimeout = 1000
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs and stop one FOLLOWER
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	rafts[(whoisleader+1)%3].Stop()
+
+	// 3. leader adds a dead peer, the new configuration has 2 acks of 4 members
+	epoch := leader.getEpochID()
+	adopted, retCode := leader.ProposeAddPeer(deadpeer, false)
+	assert.Equal(t, model.ErrorChangeTimeout, retCode)
+	assert.Nil(t, adopted)
+
+	// 4. the change timeouts and rolls back
+	assert.Equal(t, LEADER, leader.getState())
+	assert.Equal(t, 3, len(leader.GetPeers()))
+	assert.Equal(t, 0, len(leader.getNextPeers()))
+	assert.Nil(t, leader.peers[deadpeer])
+	assert.Equal(t, epoch+2, leader.getEpochID())
+}
+
+// TEST EFFECTS:
+// test the leader adds and removes an idle peer, the members take it by the heartbeat.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs, the FOLLOWER can't change the idle peers
+// 3. leader adds an idle peer, the rafts adopted the epoch have it
+// 4. leader removes the idle peer, the rafts adopted the epoch don't have it
+func TestRaftMembershipChangeIdle(t *testing.T) {
+	var idlepeer = "127.0.0.1:18889"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs, the FOLLOWER can't change the idle peers
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	{
+		adopted, retCode := rafts[(whoisleader+1)%3].ProposeAddIdlePeer(idlepeer)
+		assert.Equal(t, model.ErrorNotLeader, retCode)
+		assert.Nil(t, adopted)
+	}
+
+	// 3. leader adds an idle peer, the rafts adopted the epoch have it
+	{
+		adopted, retCode := leader.ProposeAddIdlePeer(idlepeer)
+		assert.Equal(t, model.OK, retCode)
+		assert.True(t, len(adopted) >= leader.getQuorums())
+
+		for _, raft := range adoptedRafts(rafts, adopted) {
+			assert.Equal(t, []string{idlepeer}, raft.GetIdlePeers())
+		}
+	}
+
+	// 4. leader removes the idle peer, the rafts adopted the epoch don't have it
+	{
+		adopted, retCode := leader.ProposeRemoveIdlePeer(idlepeer)
+		assert.Equal(t, model.OK, retCode)
+		assert.True(t, len(adopted) >= leader.getQuorums())
+
+		for _, raft := range adoptedRafts(rafts, adopted) {
+			assert.Equal(t, 0, len(raft.GetIdlePeers()))
+		}
+	}
+}
+
+// adoptedRafts returns the rafts in the adopted list.
+func adoptedRafts(rafts []*Raft, adopted []string) []*Raft {
+	in := make(map[string]bool)
+	for _, id := range adopted {
+		in[id] = true
+	}
+	res := []*Raft{}
+	for _, raft := range rafts {
+		if in[raft.getID()] {
+			res = append(res, raft)
+		}
+	}
+	return res
+}
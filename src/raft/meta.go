@@ -0,0 +1,145 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// metaVersion is the version of the meta file format, bump it when the format changes.
+	metaVersion = 1
+)
+
+// metaJSON is the on-disk format of the raft meta file.
+type metaJSON struct {
+	Version int `json:"version"`
+	RaftMeta
+	VotedFor string `json:"voted-for"`
+}
+
+// writeMetaJSON writes the meta to a temp file in the same dir, fsyncs it and
+// renames it to path, so a crash never leaves a half-written meta file behind.
+func writeMetaJSON(path string, meta *metaJSON) error {
+	meta.Version = metaVersion
+	buf, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileAtomic(path, buf)
+}
+
+// writeFileAtomic writes the buf to a temp file in the same dir, fsyncs it and renames it to path.
+func writeFileAtomic(path string, buf []byte) error {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.WithStack(err)
+	}
+	return syncDir(dir)
+}
+
+// writeVersionedJSON writes the v with the version field atomically, v must be marshaled to a JSON object.
+func writeVersionedJSON(path string, version int, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return errors.WithStack(err)
+	}
+	fields["version"] = json.RawMessage(strconv.Itoa(version))
+	if buf, err = json.MarshalIndent(fields, "", "\t"); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileAtomic(path, buf)
+}
+
+// readVersionedJSON reads the file written by writeVersionedJSON into v, returns false if the file doesn't exist.
+// the file of a version newer than the supported one is an error, the caller decides whether the error is fatal.
+func readVersionedJSON(path string, version int, v interface{}) (bool, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	header := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal(buf, &header); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if header.Version > version {
+		return false, errors.Errorf("file[%v].version[%v].is.newer.than.supported[%v]", path, header.Version, version)
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// syncDir fsyncs the directory to make the rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func readMetaJSON(path string) (*metaJSON, error) {
+	meta := &metaJSON{}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := json.Unmarshal(buf, meta); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if meta.Version > metaVersion {
+		return nil, errors.Errorf("meta.file[%v].version[%v].is.newer.than.supported[%v]", path, meta.Version, metaVersion)
+	}
+	return meta, nil
+}
@@ -0,0 +1,180 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"path/filepath"
+	"testing"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaJson(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenon-meta")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, metaFile)
+
+	meta := &metaJSON{
+		RaftMeta: RaftMeta{
+			ViewID:    11,
+			EpochID:   3,
+			Peers:     []string{":0101", ":0202"},
+			IdlePeers: []string{":0303"},
+		},
+		VotedFor: ":0202",
+	}
+
+	// write and read back
+	{
+		err := writeMetaJSON(path, meta)
+		assert.Nil(t, err)
+
+		got, err := readMetaJSON(path)
+		assert.Nil(t, err)
+		assert.Equal(t, metaVersion, got.Version)
+		assert.Equal(t, meta.RaftMeta, got.RaftMeta)
+		assert.Equal(t, meta.VotedFor, got.VotedFor)
+
+		// no temp files left behind
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(files))
+	}
+
+	// newer version
+	{
+		err := ioutil.WriteFile(path, []byte(`{"version":99}`), 0644)
+		assert.Nil(t, err)
+		_, err = readMetaJSON(path)
+		assert.NotNil(t, err)
+	}
+
+	// json broken
+	{
+		err := ioutil.WriteFile(path, []byte(`inject`), 0644)
+		assert.Nil(t, err)
+		_, err = readMetaJSON(path)
+		want := "invalid character 'i' looking for beginning of value"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestVersionedJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenon-meta")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, historyFile)
+
+	// not exist
+	{
+		ok, err := readVersionedJSON(path, historyVersion, &historyJSON{})
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	}
+
+	// write and read back
+	{
+		want := &historyJSON{Events: []model.RaftEvent{{Type: EventEpochChange, EpochID: 3}}}
+		err := writeVersionedJSON(path, historyVersion, want)
+		assert.Nil(t, err)
+
+		buf, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Contains(t, string(buf), `"version": 1`)
+
+		got := &historyJSON{}
+		ok, err := readVersionedJSON(path, historyVersion, got)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, want, got)
+	}
+
+	// newer version
+	{
+		err := ioutil.WriteFile(path, []byte(`{"version":99}`), 0644)
+		assert.Nil(t, err)
+		_, err = readVersionedJSON(path, historyVersion, &historyJSON{})
+		assert.NotNil(t, err)
+	}
+
+	// json broken
+	{
+		err := ioutil.WriteFile(path, []byte(`inject`), 0644)
+		assert.Nil(t, err)
+		_, err = readVersionedJSON(path, historyVersion, &historyJSON{})
+		want := "invalid character 'i' looking for beginning of value"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestRaftMetaRecovery(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-meta")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	id := "127.0.0.1:0101"
+
+	// persist the view, epoch and vote
+	{
+		raft := NewRaft(id, conf, 10000, log, mysql57, FOLLOWER)
+		raft.AddPeer("127.0.0.1:0202")
+		raft.AddIdlePeer("127.0.0.1:0303")
+		raft.updateView(7, noLeader)
+		raft.setVotedFor("127.0.0.1:0202")
+	}
+
+	// restart recovers all of them
+	{
+		raft := NewRaft(id, conf, 10000, log, mysql57, FOLLOWER)
+		assert.Equal(t, uint64(7), raft.getViewID())
+		assert.Equal(t, uint64(2), raft.getEpochID())
+		assert.Equal(t, "127.0.0.1:0202", raft.votedFor)
+		assert.Equal(t, []string{id, "127.0.0.1:0202"}, raft.getPeers())
+		assert.Equal(t, []string{"127.0.0.1:0303"}, raft.getIdlePeers())
+		assert.NotNil(t, raft.peers["127.0.0.1:0202"])
+		assert.NotNil(t, raft.idlePeers["127.0.0.1:0303"])
+	}
+}
+
+func TestRaftMetaMigrateFromPeersJson(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-meta")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	legacy := `{"idlepeers":["127.0.0.1:0303"],"peers":["127.0.0.1:0101","127.0.0.1:0202"]}`
+	err = ioutil.WriteFile(filepath.Join(dir, peersFile), []byte(legacy), 0755)
+	assert.Nil(t, err)
+
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	raft := NewRaft("127.0.0.1:0101", conf, 10000, log, mysql57, FOLLOWER)
+	assert.Equal(t, []string{"127.0.0.1:0101", "127.0.0.1:0202"}, raft.getPeers())
+	assert.Equal(t, []string{"127.0.0.1:0303"}, raft.getIdlePeers())
+
+	// the legacy file is replaced by the meta file
+	_, err = os.Stat(filepath.Join(dir, peersFile))
+	assert.True(t, os.IsNotExist(err))
+	meta, err := readMetaJSON(filepath.Join(dir, metaFile))
+	assert.Nil(t, err)
+	assert.Equal(t, raft.getPeers(), meta.Peers)
+	assert.Equal(t, raft.getIdlePeers(), meta.IdlePeers)
+}
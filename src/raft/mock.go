@@ -14,6 +14,7 @@ import (
 	"model"
 	"mysql"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 	"xbase/common"
@@ -36,11 +37,15 @@ func setupRPC(rpc *xrpc.Service, raft *Raft) {
 	if err := rpc.RegisterService(raft.GetRaftRPC()); err != nil {
 		raft.PANIC("server.rpc.RegisterService.RaftRPC.error[%+v]", err)
 	}
+
+	if err := rpc.RegisterService(raft.mysql.GetMysqlRPC()); err != nil {
+		raft.PANIC("server.rpc.RegisterService.MysqlRPC.error[%+v]", err)
+	}
 }
 
 // MockRaftsWithConfig mock.
 func MockRaftsWithConfig(log *xlog.Log, conf *config.RaftConfig, port int, count int, idleStart int) ([]string, []*Raft, func()) {
-	return mockRafts(log, conf, port, count, idleStart, false)
+	return mockRafts(log, conf, port, count, idleStart, false, nil, nil)
 }
 
 // MockRafts mock.
@@ -51,7 +56,7 @@ func MockRafts(log *xlog.Log, port int, count int, idleStart int) ([]string, []*
 	conf.CandidateWaitFor2Nodes = 1000
 	conf.MetaDatadir = "/tmp/"
 
-	return mockRafts(log, conf, port, count, idleStart, false)
+	return mockRafts(log, conf, port, count, idleStart, false, nil, nil)
 }
 
 // MockRaftsWithLong mock.
@@ -60,17 +65,79 @@ func MockRaftsWithLong(log *xlog.Log, port int, count int, idleStart int) ([]str
 	conf.PurgeBinlogInterval = 1
 	conf.MetaDatadir = "/tmp/"
 
-	return mockRafts(log, conf, port, count, idleStart, true)
+	return mockRafts(log, conf, port, count, idleStart, true, nil, nil)
+}
+
+// MockRaftsWithFakeClock mock.
+// every raft and its mysql ping have their own FakeClock, the timers only fire when the test advances the clock.
+func MockRaftsWithFakeClock(log *xlog.Log, port int, count int, idleStart int) ([]string, []*Raft, []*common.FakeClock, func()) {
+	conf := config.DefaultRaftConfig()
+	conf.PurgeBinlogInterval = 1
+	conf.CandidateWaitFor2Nodes = 1000
+	conf.MetaDatadir = "/tmp/"
+
+	clocks := []*common.FakeClock{}
+	for i := 0; i < count; i++ {
+		clocks = append(clocks, common.NewFakeClock(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)))
+	}
+	ids, rafts, cleanup := mockRafts(log, conf, port, count, idleStart, false, clocks, nil)
+	return ids, rafts, clocks, cleanup
 }
 
-func mockRafts(log *xlog.Log, conf *config.RaftConfig, port int, count int, idleStart int, islong bool) ([]string, []*Raft, func()) {
+// MockRaftsWithNetwork mock.
+// the rafts talk over the in-memory network, the tests inject the faults by the network or MockNetworkScenario.
+func MockRaftsWithNetwork(log *xlog.Log, port int, count int, idleStart int) ([]string, []*Raft, *xrpc.Network, func()) {
+	conf := config.DefaultRaftConfig()
+	conf.PurgeBinlogInterval = 1
+	conf.CandidateWaitFor2Nodes = 1000
+	conf.MetaDatadir = "/tmp/"
+
+	network := xrpc.NewNetwork()
+	ids, rafts, cleanup := mockRafts(log, conf, port, count, idleStart, false, nil, network)
+	return ids, rafts, network, cleanup
+}
+
+// MockAdvance advances the clock by d in the steps of 1/10 heartbeat,
+// and gives the real time to the rafts to handle the fired timers and the rpcs after every step.
+func MockAdvance(clock *common.FakeClock, d time.Duration) {
+	step := time.Duration(shortHeartbeatTimeoutForTest/10) * time.Millisecond
+	for d > 0 {
+		if d < step {
+			step = d
+		}
+		clock.Advance(step)
+		d -= step
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// MockAdvanceUntil advances the clock step by step until the cond is true, returns false if the cond is still false after max.
+func MockAdvanceUntil(clock *common.FakeClock, max time.Duration, cond func() bool) bool {
+	step := time.Duration(shortHeartbeatTimeoutForTest/10) * time.Millisecond
+	for d := time.Duration(0); d < max; d += step {
+		if cond() {
+			return true
+		}
+		MockAdvance(clock, step)
+	}
+	return cond()
+}
+
+func mockRafts(log *xlog.Log, conf *config.RaftConfig, port int, count int, idleStart int, islong bool, clocks []*common.FakeClock, network *xrpc.Network) ([]string, []*Raft, func()) {
 	ids := []string{}
 	var raft *Raft
 	rafts := []*Raft{}
 	rpcs := []*xrpc.Service{}
 	ip, _ := common.GetLocalIP()
 
-	os.Remove("/tmp/peers.json")
+	os.Remove(filepath.Join(conf.MetaDatadir, peersFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, metaFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, historyFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, maintenanceFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, freezeFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, clusterFreezeFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, timingsFile))
+	os.Remove(filepath.Join(conf.MetaDatadir, standbyFile))
 	for i := 0; i < count; i++ {
 		id := fmt.Sprintf("%s:%d", ip, port+i)
 		ids = append(ids, id)
@@ -86,6 +153,9 @@ func mockRafts(log *xlog.Log, conf *config.RaftConfig, port int, count int, idle
 		// setup mysql
 		mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
 		mysql57.SetMysqlHandler(mysql.NewMockGTIDA())
+		if clocks != nil {
+			mysql57.SetClock(clocks[i])
+		}
 		mysql57.PingStart()
 
 		for i, id := range ids {
@@ -95,13 +165,22 @@ func mockRafts(log *xlog.Log, conf *config.RaftConfig, port int, count int, idle
 				raft = NewRaft(id, conf, 10000, log, mysql57, FOLLOWER)
 			}
 		}
+		if clocks != nil {
+			raft.SetClock(clocks[i])
+		}
+		transport := xrpc.DefaultTransport
+		if network != nil {
+			transport = network
+			raft.SetTransport(network)
+		}
 
 		// setup raft
 		rafts = append(rafts, raft)
 
 		// setup rpc
 		rpc, err := xrpc.NewService(xrpc.Log(log),
-			xrpc.ConnectionStr(id))
+			xrpc.ConnectionStr(id),
+			xrpc.UseTransport(transport))
 		if err != nil {
 			log.Panic("raftRPC.NewService.error[%+v]", err)
 		}
@@ -121,11 +200,19 @@ func mockRafts(log *xlog.Log, conf *config.RaftConfig, port int, count int, idle
 	}
 
 	return ids, rafts, func() {
-		os.Remove("peers.json")
+		os.Remove(filepath.Join(conf.MetaDatadir, peersFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, metaFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, maintenanceFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, freezeFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, clusterFreezeFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, timingsFile))
+		os.Remove(filepath.Join(conf.MetaDatadir, standbyFile))
 		for i, r := range rafts {
 			rpcs[i].Stop()
 			r.Stop()
 		}
+		// the rafts write the history when they stop
+		os.Remove(filepath.Join(conf.MetaDatadir, historyFile))
 	}
 }
 
@@ -134,6 +221,29 @@ func MockSetMysqlHandler(raft *Raft, h mysql.MysqlHandler) {
 	raft.mysql.SetMysqlHandler(h)
 }
 
+// MockSetPriority used to set the election priority of the raft for test.
+// the rafts share the config in mock, so the raft gets its own copy.
+func MockSetPriority(raft *Raft, priority int) {
+	conf := *raft.conf
+	conf.Priority = priority
+	raft.conf = &conf
+}
+
+// MockSetZone used to set the zone label of the raft for test.
+func MockSetZone(raft *Raft, zone string) {
+	conf := *raft.conf
+	conf.Zone = zone
+	raft.conf = &conf
+}
+
+// MockSetWitness used to set the rafts[witness] as a WITNESS for test, it must be called before start.
+func MockSetWitness(rafts []*Raft, witness int) {
+	rafts[witness].initRole = WITNESS
+	for _, raft := range rafts {
+		raft.meta.Witnesses = append(raft.meta.Witnesses, rafts[witness].getID())
+	}
+}
+
 // MockWaitMySQLPingTimeout used to wait mysql ping timeout.
 func MockWaitMySQLPingTimeout() {
 	pingTimeout := config.DefaultMysqlConfig().PingTimeout * 6
@@ -236,3 +346,128 @@ func (r *Raft) mockLeaderSendHeartbeat(mysqlDown *bool, c chan *model.RaftRPCRes
 func (r *Raft) mockLeaderProcessSendHeartbeatResponse(ackGranted *int, rsp *model.RaftRPCResponse) {
 	r.DEBUG("mock.send.heartbeat.get.rsp[N:%v, V:%v, E:%v].retcode[%v]", rsp.GetFrom(), rsp.GetViewID(), rsp.GetEpochID(), rsp.RetCode)
 }
+
+// MockScenario scripts the network faults between the rafts over the in-memory network,
+// the steps run in order, for example the old leader is isolated and comes back:
+//
+//	MockNetworkScenario(network, rafts).Isolate(leader).WaitLeaders(2).Heal().WaitLeaders(1)
+type MockScenario struct {
+	network *xrpc.Network
+	rafts   []*Raft
+}
+
+// MockNetworkScenario creates the scenario of the rafts from MockRaftsWithNetwork.
+func MockNetworkScenario(network *xrpc.Network, rafts []*Raft) *MockScenario {
+	return &MockScenario{network: network, rafts: rafts}
+}
+
+func (s *MockScenario) id(i int) string {
+	return s.rafts[i].getID()
+}
+
+// Cut loses the messages of rafts[from]->rafts[to], the other direction still works.
+func (s *MockScenario) Cut(from int, to int) *MockScenario {
+	s.network.Cut(s.id(from), s.id(to))
+	return s
+}
+
+// Isolate cuts rafts[i] from all the others in both directions.
+func (s *MockScenario) Isolate(i int) *MockScenario {
+	for j := range s.rafts {
+		if j != i {
+			s.Cut(i, j).Cut(j, i)
+		}
+	}
+	return s
+}
+
+// Partition splits the rafts into the groups, the rafts in different groups can't talk to each other.
+func (s *MockScenario) Partition(groups ...[]int) *MockScenario {
+	for g, group := range groups {
+		for _, other := range groups[g+1:] {
+			for _, i := range group {
+				for _, j := range other {
+					s.Cut(i, j).Cut(j, i)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// Delay delays the messages of rafts[from]->rafts[to] by d.
+func (s *MockScenario) Delay(from int, to int, d time.Duration) *MockScenario {
+	s.network.Delay(s.id(from), s.id(to), d)
+	return s
+}
+
+// Drop loses percent of the messages of rafts[from]->rafts[to].
+func (s *MockScenario) Drop(from int, to int, percent int) *MockScenario {
+	s.network.Drop(s.id(from), s.id(to), percent)
+	return s
+}
+
+// Heal removes all the faults.
+func (s *MockScenario) Heal() *MockScenario {
+	s.network.HealAll()
+	return s
+}
+
+// Flap isolates rafts[i] and heals it every period for times.
+func (s *MockScenario) Flap(i int, period time.Duration, times int) *MockScenario {
+	for n := 0; n < times; n++ {
+		s.Isolate(i).Sleep(period)
+		s.Heal().Sleep(period)
+	}
+	return s
+}
+
+// Sleep waits d for the rafts to react.
+func (s *MockScenario) Sleep(d time.Duration) *MockScenario {
+	time.Sleep(d)
+	return s
+}
+
+// WaitLeaders waits until there are n LEADERs, see MockWaitLeaderEggs.
+func (s *MockScenario) WaitLeaders(n int) *MockScenario {
+	MockWaitLeaderEggs(s.rafts, n)
+	return s
+}
+
+// Wait waits until the cond is true, at most 60 seconds like MockWaitLeaderEggs.
+func (s *MockScenario) Wait(cond func() bool) *MockScenario {
+	for start := time.Now(); !cond() && time.Since(start) < 60*time.Second; {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return s
+}
+
+// WaitStable waits until there is one LEADER and all the rafts follow it.
+func (s *MockScenario) WaitStable() *MockScenario {
+	return s.Wait(s.Stable)
+}
+
+// Stable returns true if there is one LEADER and all the rafts follow it.
+func (s *MockScenario) Stable() bool {
+	leaders := s.Leaders()
+	if len(leaders) != 1 {
+		return false
+	}
+	for _, raft := range s.rafts {
+		if raft.getLeader() != s.id(leaders[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Leaders returns the indexes of the LEADERs.
+func (s *MockScenario) Leaders() []int {
+	leaders := []int{}
+	for i, raft := range s.rafts {
+		if raft.getState() == LEADER {
+			leaders = append(leaders, i)
+		}
+	}
+	return leaders
+}
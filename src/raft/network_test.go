@@ -0,0 +1,117 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the isolated leader degrades and the majority elects a new leader.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+// 2. isolate the leader, the majority elects a new leader
+// 3. heal the network, all the rafts follow one leader again
+func TestRaftNetworkIsolateLeader(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, network, cleanup := MockRaftsWithNetwork(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	scenario := MockNetworkScenario(network, rafts)
+	old := scenario.WaitStable().Leaders()
+	assert.Equal(t, 1, len(old))
+	electionTimeout := time.Duration(rafts[0].getElectionTimeout()) * time.Millisecond
+
+	// 2. isolate the leader, the majority elects a new leader
+	scenario.Isolate(old[0]).Wait(func() bool {
+		leaders := scenario.Leaders()
+		return len(leaders) == 1 && leaders[0] != old[0]
+	})
+	leaders := scenario.Leaders()
+	assert.Equal(t, 1, len(leaders))
+	assert.NotEqual(t, old[0], leaders[0])
+	assert.Equal(t, degradeLeaseExpired, mockDegradeReason(rafts[old[0]]))
+
+	// 3. heal the network, all the rafts follow one leader again
+	scenario.Heal().WaitStable().Sleep(electionTimeout).WaitStable()
+	assert.True(t, scenario.Stable())
+}
+
+// TEST EFFECTS:
+// test the follower can't hear the leader in one direction won't depose the leader.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+// 2. cut the leader->follower, the follower still reaches the others
+// 3. the prevotes of the follower fail, the leader stays
+func TestRaftNetworkAsymmetricPartition(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, network, cleanup := MockRaftsWithNetwork(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	scenario := MockNetworkScenario(network, rafts)
+	assert.True(t, scenario.WaitStable().Stable())
+	leader := scenario.Leaders()[0]
+	follower := (leader + 1) % len(rafts)
+	electionTimeout := time.Duration(rafts[0].getElectionTimeout()) * time.Millisecond
+
+	// 2. cut the leader->follower, the follower still reaches the others
+	// 3. the prevotes of the follower fail, the leader stays
+	scenario.Cut(leader, follower).Sleep(electionTimeout * 5)
+	assert.Equal(t, []int{leader}, scenario.Leaders())
+	assert.Equal(t, uint64(0), rafts[leader].getStats().LeaderDegrades)
+	assert.True(t, rafts[follower].getStats().PreVoteFails > 0)
+}
+
+// TEST EFFECTS:
+// test the flapping follower won't depose the leader.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+// 2. isolate and heal a follower for many times
+// 3. the leader stays
+func TestRaftNetworkFlapping(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, network, cleanup := MockRaftsWithNetwork(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts on the in-memory network, wait the leader eggs
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	scenario := MockNetworkScenario(network, rafts)
+	assert.True(t, scenario.WaitStable().Stable())
+	leader := scenario.Leaders()[0]
+	follower := (leader + 1) % len(rafts)
+	electionTimeout := time.Duration(rafts[0].getElectionTimeout()) * time.Millisecond
+
+	// 2. isolate and heal a follower for many times
+	// 3. the leader stays
+	scenario.Flap(follower, electionTimeout, 5).Sleep(electionTimeout)
+	assert.Equal(t, []int{leader}, scenario.Leaders())
+	assert.Equal(t, rafts[leader].getID(), rafts[follower].getLeader())
+	assert.Equal(t, uint64(0), rafts[leader].getStats().LeaderDegrades)
+}
@@ -9,7 +9,9 @@
 package raft
 
 import (
+	"fmt"
 	"model"
+	"sync/atomic"
 	"xbase/xrpc"
 )
 
@@ -19,6 +21,8 @@ type Peer struct {
 	requestTimeout   int // peer client request timneout
 	heartbeatTimeout int
 	connectionStr    string // peer connection string
+	priority         int32  // peer election priority we last heard, 0 if unknown or unreachable
+	client           *xrpc.Session
 }
 
 // NewPeer creates new Peer.
@@ -28,6 +32,7 @@ func NewPeer(raft *Raft, connectionStr string, requestTimeout int, heartbeatTime
 		connectionStr:    connectionStr,
 		requestTimeout:   requestTimeout,
 		heartbeatTimeout: heartbeatTimeout,
+		client:           xrpc.NewSession(raft.transport, raft.getID(), connectionStr, requestTimeout),
 	}
 }
 
@@ -42,29 +47,39 @@ func (p *Peer) sendHeartbeat(c chan *model.RaftRPCResponse) {
 	req.Raft.EpochID = p.raft.getEpochID()
 	req.Raft.ViewID = p.raft.getViewID()
 	req.Raft.From = p.raft.getID()
+	req.Raft.Priority = p.raft.getPriority()
+	req.Raft.Zone = p.raft.getZone()
 	req.Raft.To = p.getID()
 	req.Raft.Leader = p.raft.getLeader()
+	req.Raft.ClusterID = p.raft.getClusterID()
+	req.Raft.Transfer = p.raft.isTransferCampaign()
 	req.Peers = p.raft.getPeers()
 	req.IdlePeers = p.raft.getIdlePeers()
+	req.Witnesses = p.raft.getWitnesses()
+	req.NextPeers = p.raft.getNextPeers()
 	req.GTID = p.raft.getGTID()
 	req.Repl = p.raft.mysql.GetRepl()
-	client, cleanup, err := p.NewClient()
-	if err != nil {
-		p.raft.ERROR("send.heartbeat.to.peer[%v].new.client.error[%v]", p.getID(), err)
-		rsp.RetCode = model.ErrorRPCCall
-		c <- rsp
-		return
-	}
-	defer cleanup()
+	req.ClusterFreeze = p.raft.getClusterFreeze()
+	timings := p.raft.getTimings()
+	req.Timings = &timings
+	req.Standby = p.raft.getStandby()
 
 	method := model.RPCRaftHeartbeat
-	err = client.CallTimeout(p.requestTimeout*2, method, req, rsp)
+	err := p.client.CallTimeout(p.requestTimeout*2, method, req, rsp)
 	if err != nil {
 		p.raft.ERROR("send.heartbeat.to.peer[%v].client.call.error[%v]", p.getID(), err)
+		p.setPriority(0)
 		rsp.RetCode = model.ErrorRPCCall
 		c <- rsp
 		return
 	}
+	p.checkClusterID(rsp)
+	if rsp.RetCode == model.ErrorClusterIDMismatch {
+		c <- rsp
+		return
+	}
+	// the leader learns the priorities from the acks, the followers may never see its prevote
+	p.setPriority(rsp.Raft.Priority)
 	p.raft.DEBUG("send.heartbeat.to.peer[%v].client.call.ok.rsp[%v].my.gtid.is[%v]", p.getID(), rsp, req.GTID)
 	c <- rsp
 }
@@ -82,8 +97,12 @@ func (p *Peer) sendRequestVote(c chan *model.RaftRPCResponse) {
 	req.Raft.EpochID = p.raft.meta.EpochID
 	req.Raft.ViewID = p.raft.meta.ViewID
 	req.Raft.From = p.raft.getID()
+	req.Raft.Priority = p.raft.getPriority()
+	req.Raft.Zone = p.raft.getZone()
 	req.Raft.To = p.connectionStr
 	req.Raft.Leader = p.raft.getLeader()
+	req.Raft.ClusterID = p.raft.getClusterID()
+	req.Raft.Transfer = p.raft.isTransferCampaign()
 	req.GTID, err = p.raft.mysql.GetGTID()
 	if err != nil {
 		p.raft.ERROR("send.requestvote.to.peer[%v].get.gtid.error[%v]", p.getID(), err)
@@ -93,26 +112,62 @@ func (p *Peer) sendRequestVote(c chan *model.RaftRPCResponse) {
 	}
 	p.raft.WARNING("send.requestvote.to.peer[%v].request.gtid[%v]", p.getID(), req.GTID)
 
-	client, cleanup, err := p.NewClient()
+	method := model.RPCRaftRequestVote
+	err = p.client.CallTimeout(p.requestTimeout, method, req, rsp)
 	if err != nil {
-		p.raft.ERROR("send.requestvote.to.peer[%v].new.client.error[%v]", p.getID(), err)
+		p.raft.ERROR("send.requestvote.to.peer[%v].client.call.error[%v]", p.getID(), err)
 		rsp.RetCode = model.ErrorRPCCall
 		c <- rsp
 		return
 	}
-	defer cleanup()
+	p.checkClusterID(rsp)
+	c <- rsp
+}
 
-	method := model.RPCRaftRequestVote
-	err = client.CallTimeout(p.requestTimeout, method, req, rsp)
+// sendPreVote
+// send prevote rpc request, asks the peer whether it would vote for us in the next view
+func (p *Peer) sendPreVote(gtid model.GTID, c chan *model.RaftRPCResponse) {
+	// response
+	rsp := model.NewRaftRPCResponse(model.OK)
+
+	// request body
+	req := model.NewRaftRPCRequest()
+	req.Raft.EpochID = p.raft.getEpochID()
+	req.Raft.ViewID = p.raft.getViewID() + 1
+	req.Raft.From = p.raft.getID()
+	req.Raft.Priority = p.raft.getPriority()
+	req.Raft.Zone = p.raft.getZone()
+	req.Raft.To = p.getID()
+	req.Raft.Leader = p.raft.getLeader()
+	req.Raft.ClusterID = p.raft.getClusterID()
+	req.GTID = gtid
+
+	method := model.RPCRaftPreVote
+	err := p.client.CallTimeout(p.requestTimeout, method, req, rsp)
 	if err != nil {
-		p.raft.ERROR("send.requestvote.to.peer[%v].client.call.error[%v]", p.getID(), err)
+		p.raft.ERROR("send.prevote.to.peer[%v].client.call.error[%v]", p.getID(), err)
+		p.setPriority(0)
 		rsp.RetCode = model.ErrorRPCCall
 		c <- rsp
 		return
 	}
+	p.checkClusterID(rsp)
+	if rsp.RetCode == model.ErrorClusterIDMismatch {
+		c <- rsp
+		return
+	}
+	p.setPriority(rsp.Raft.Priority)
+	p.raft.updatePeerZone(p.getID(), rsp.Raft.Zone)
 	c <- rsp
 }
 
+// checkClusterID fails the response of the peer from a foreign cluster.
+func (p *Peer) checkClusterID(rsp *model.RaftRPCResponse) {
+	if !p.raft.checkClusterID(p.getID(), rsp.Raft.ClusterID) {
+		rsp.RetCode = model.ErrorClusterIDMismatch
+	}
+}
+
 // follower SendPing
 func (p *Peer) SendPing(c chan *model.RaftRPCResponse) {
 	// response
@@ -120,42 +175,76 @@ func (p *Peer) SendPing(c chan *model.RaftRPCResponse) {
 
 	// request body
 	req := model.NewRaftRPCRequest()
+	req.Raft.EpochID = p.raft.getEpochID()
+	req.Raft.ViewID = p.raft.getViewID()
+	req.Raft.From = p.raft.getID()
+	req.Raft.To = p.getID()
+	req.Raft.ClusterID = p.raft.getClusterID()
 
-	client, cleanup, err := p.NewClient()
+	method := model.RPCRaftPing
+	err := p.client.CallTimeout(p.requestTimeout, method, req, rsp)
 	if err != nil {
-		p.raft.ERROR("send.ping.to.peer[%v].new.client.error[%v]", p.getID(), err)
+		p.raft.ERROR("send.ping.to.peer[%v].client.call.error[%v]", p.getID(), err)
+		p.setPriority(0)
 		rsp.RetCode = model.ErrorRPCCall
 		c <- rsp
 		return
 	}
-	defer cleanup()
-
-	method := model.RPCRaftPing
-	err = client.CallTimeout(p.requestTimeout, method, req, rsp)
-	if err != nil {
-		p.raft.ERROR("send.ping.to.peer[%v].client.call.error[%v]", p.getID(), err)
-		rsp.RetCode = model.ErrorRPCCall
+	p.checkClusterID(rsp)
+	if rsp.RetCode == model.ErrorClusterIDMismatch {
 		c <- rsp
 		return
 	}
+	p.setPriority(rsp.Raft.Priority)
+	p.raft.updatePeerZone(p.getID(), rsp.Raft.Zone)
 	p.raft.DEBUG("send.ping.to.peer[%v].client.call.ok.rsp[%v]", p.getID(), rsp)
 	c <- rsp
 }
 
-// NewClient creates new client.
-func (p *Peer) NewClient() (*xrpc.Client, func(), error) {
-	client, err := xrpc.NewClient(p.connectionStr, p.requestTimeout)
-	if err != nil {
-		return nil, nil, err
+// getMysqlGTID
+// get the peer's MySQL GTID info
+func (p *Peer) getMysqlGTID() (*model.GTID, error) {
+	method := model.RPCMysqlStatus
+	req := model.NewMysqlStatusRPCRequest()
+	req.From = p.raft.getID()
+	rsp := model.NewMysqlStatusRPCResponse(model.OK)
+	if err := p.client.CallTimeout(p.requestTimeout, method, req, rsp); err != nil {
+		return nil, err
+	}
+	if rsp.RetCode != model.OK {
+		return nil, fmt.Errorf("%s", rsp.RetCode)
+	}
+	return &rsp.GTID, nil
+}
+
+// sendTryToLeader
+// propose the peer to be a candidate
+func (p *Peer) sendTryToLeader() (string, error) {
+	method := model.RPCHATryToLeader
+	req := model.NewHARPCRequest()
+	req.From = p.raft.getID()
+	rsp := model.NewHARPCResponse(model.OK)
+	if err := p.client.CallTimeout(p.requestTimeout, method, req, rsp); err != nil {
+		return "", err
 	}
-	return client, func() {
-		client.Close()
-	}, nil
+	return rsp.RetCode, nil
 }
 
 // attributes
 func (p *Peer) freePeer() {
-	// nop
+	p.client.Close()
+}
+
+func (p *Peer) healthy() bool {
+	return p.client.Healthy()
+}
+
+func (p *Peer) getPriority() int {
+	return int(atomic.LoadInt32(&p.priority))
+}
+
+func (p *Peer) setPriority(priority int) {
+	atomic.StoreInt32(&p.priority, int32(priority))
 }
 
 func (p *Peer) getID() string {
@@ -15,26 +15,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-func writePeersJSON(path string, peers []string, idlePeers []string) error {
-	allPeers := make(map[string][]string)
-
-	allPeers["peers"] = peers
-	allPeers["idlepeers"] = idlePeers
-
-	jsonStr, err := json.Marshal(allPeers)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	if err := ioutil.WriteFile(path, []byte(jsonStr), 0755); err != nil {
-		return errors.WithStack(err)
-	}
-	return nil
-}
-
+// readPeersJSON reads the legacy peers.json which only holds the peers and
+// idle peers, it is used to migrate the old file to the meta file.
 func readPeersJSON(path string) ([]string, []string, error) {
-	//var peers []string
-	//var idlePeers []string
 	allPeers := make(map[string][]string)
 
 	buf, err := ioutil.ReadFile(path)
@@ -10,6 +10,7 @@ package raft
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -20,12 +21,8 @@ func TestPeersJson(t *testing.T) {
 	path := "/tmp/test.peersjson"
 	peers := []string{":0101", ":0202"}
 	idlePeers := []string{":0303", ":0404"}
-
-	{
-		err := writePeersJSON(path, peers, idlePeers)
-		assert.Nil(t, err)
-		os.Remove(path)
-	}
+	legacy := `{"idlepeers":[":0303",":0404"],"peers":[":0101",":0202"]}`
+	os.Remove(path)
 
 	// read error
 	{
@@ -35,9 +32,9 @@ func TestPeersJson(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 
-	// write json
+	// write legacy json
 	{
-		err := writePeersJSON(path, peers, idlePeers)
+		err := ioutil.WriteFile(path, []byte(legacy), 0755)
 		assert.Nil(t, err)
 	}
 
@@ -0,0 +1,224 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"sync/atomic"
+	"time"
+)
+
+// processPreVoteRequest
+// EFFECT
+// handles the prevote request from the FOLLOWER who wants to be a CANDIDATE
+// it never changes the state, viewid or votedFor of this node
+//
+// RETURN
+// 1. ErrorInvalidRequest: the request.From is not a member of this cluster
+// 2. ErrorLeaderAlive: I am the LEADER, or I still get the heartbeat from a live leader
+// 3. ErrorVoteNotGranted: I am not a voter(IDLE/INVALID/LEARNER)
+//    the WITNESS compares the request GTID with the other candidates, it has no MySQL
+// 4. ErrorFailoverFrozen: the failover is frozen
+// 5. ErrorInvalidViewID: request viewid is old
+//    ErrorInvalidEpochID: request has the stale membership epoch
+// 6. ErrorMySQLDown: can't get my GTID
+// 7. ErrorInvalidGTID: the request GTID is smaller than mine
+// 8. ErrorLowerPriority: the request GTID is same with mine but the priority is lower
+// 9. OK: I would vote for you
+func (r *Raft) processPreVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	state := r.getState()
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
+	rsp.Raft.Leader = r.getLeader()
+
+	if !r.checkRequest(req) {
+		rsp.RetCode = model.ErrorInvalidRequest
+		return rsp
+	}
+
+	// 1. check state and leader
+	switch state {
+	case LEADER:
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].i.am.leader.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.RetCode = model.ErrorLeaderAlive
+		return rsp
+	case FOLLOWER, WITNESS:
+		if r.isLeaderAlive() {
+			r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].leader[%v].is.alive.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getLeader())
+			rsp.RetCode = model.ErrorLeaderAlive
+			return rsp
+		}
+	case CANDIDATE:
+	default:
+		rsp.RetCode = model.ErrorVoteNotGranted
+		return rsp
+	}
+
+	// 2. check the failover is not frozen
+	if r.isFailoverFrozen() {
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].failover.is.frozen.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.RetCode = model.ErrorFailoverFrozen
+		return rsp
+	}
+
+	// 3. check viewid
+	if req.GetViewID() < r.getViewID() {
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].stale.viewid.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.RetCode = model.ErrorInvalidViewID
+		return rsp
+	}
+	if !r.checkRequestEpoch(req) {
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].stale.epochid[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getEpochID())
+		rsp.RetCode = model.ErrorInvalidEpochID
+		return rsp
+	}
+
+	// 4. check GTID, the WITNESS has no MySQL, compares with the other candidates
+	if state == WITNESS {
+		if !r.W.checkCandidateGTID(req) {
+			rsp.RetCode = model.ErrorInvalidGTID
+			return rsp
+		}
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].witness.would.vote.for.this.candidate", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		atomic.StoreInt64(&r.preVoteGrantedAt, r.clock.Now().UnixNano())
+		return rsp
+	}
+	greater, thisGTID, err := r.mysql.GTIDGreaterThan(&req.GTID, r.conf.GTIDTieBreak)
+	if err != nil {
+		r.ERROR("process.prevote.get.gtid.error[%v].ret.ErrorMySQLDown", err)
+		rsp.RetCode = model.ErrorMySQLDown
+		return rsp
+	}
+	rsp.GTID = thisGTID
+	if greater && r.mysql.Promotable() {
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].stale.GTID.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.RetCode = model.ErrorInvalidGTID
+		return rsp
+	}
+	if r.isLowerPriority(req, &thisGTID) {
+		r.WARNING("get.prevote.from[N:%v, V:%v, E:%v, P:%v].lower.priority.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.Raft.Priority)
+		rsp.RetCode = model.ErrorLowerPriority
+		return rsp
+	}
+
+	r.WARNING("get.prevote.from[N:%v, V:%v, E:%v].would.vote.for.this.candidate", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+	atomic.StoreInt64(&r.preVoteGrantedAt, r.clock.Now().UnixNano())
+	return rsp
+}
+
+// preVoteRound is the prevote in flight, its responses are collected by the state loop,
+// so the loop keeps serving the heartbeats and the votes while the peers answer.
+type preVoteRound struct {
+	respChan chan *model.RaftRPCResponse
+	pending  int
+	granted  int
+	denied   bool
+}
+
+// responses returns the channel of the prevote responses, nil if there is no prevote in flight.
+func (round *preVoteRound) responses() chan *model.RaftRPCResponse {
+	if round == nil {
+		return nil
+	}
+	return round.respChan
+}
+
+// startPreVote
+// asks all the peers whether they would vote for us in the next view
+// the viewid is not changed, so a partitioned node can't disrupt the cluster when it rejoins
+//
+// RETURN
+// the round to collect the responses by processPreVoteResponse, nil if we can't get our GTID
+func (r *Raft) startPreVote() *preVoteRound {
+	gtid, err := r.mysql.GetGTID()
+	if err != nil {
+		r.ERROR("prevote.get.gtid.error[%v]", err)
+		r.IncPreVoteFails()
+		return nil
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	round := &preVoteRound{
+		respChan: make(chan *model.RaftRPCResponse, len(r.peers)),
+		pending:  len(r.peers),
+		granted:  1,
+	}
+	for _, peer := range r.peers {
+		go func(peer *Peer) {
+			peer.sendPreVote(gtid, round.respChan)
+		}(peer)
+	}
+	return round
+}
+
+// processPreVoteResponse
+// counts the prevote response in the state loop
+//
+// RETURN
+// true if all the peers have answered, the result is by preVoteGranted
+func (r *Raft) processPreVoteResponse(round *preVoteRound, rsp *model.RaftRPCResponse) bool {
+	round.pending--
+	switch rsp.RetCode {
+	case model.OK:
+		if rsp.Raft.State != IDLE.String() {
+			round.granted++
+		}
+	case model.ErrorMySQLDown:
+		// 2 nodes, the vote request will wait CandidateWaitFor2Nodes for the peer
+		if r.getMembers() < 3 {
+			round.granted++
+		}
+	case model.ErrorInvalidGTID, model.ErrorLowerPriority, model.ErrorInvalidEpochID:
+		// same as the vote, the CANDIDATE degrades if someone has the greater GTID, the higher priority or the newer membership
+		round.denied = true
+	case model.ErrorInvalidViewID:
+		// keep up with the latest viewid, it's the real view of the peer
+		if rsp.GetViewID() > r.getViewID() {
+			r.updateView(rsp.GetViewID(), noLeader)
+		}
+	}
+	r.WARNING("get.prevote.response.from[N:%v, V:%v, R:%v].retcode[%v].granted[%v]", rsp.GetFrom(), rsp.GetViewID(), rsp.Raft.State, rsp.RetCode, round.granted)
+	return round.pending <= 0
+}
+
+// preVoteGranted returns true if the answered round gets the majority prevotes and no one denies.
+func (r *Raft) preVoteGranted(round *preVoteRound) bool {
+	quorums := r.getQuorums()
+	if round.denied || round.granted < quorums {
+		r.WARNING("prevote.granted[%v].quorums[%v].denied[%v].fail", round.granted, quorums, round.denied)
+		r.IncPreVoteFails()
+		return false
+	}
+	r.WARNING("prevote.granted[%v]/members[%v]", round.granted, r.getMembers())
+	return true
+}
+
+// updateLeaderAlive records the time we heard from the leader or the candidate we voted for.
+func (r *Raft) updateLeaderAlive() {
+	atomic.StoreInt64(&r.leaderAliveAt, r.clock.Now().UnixNano())
+}
+
+// isLeaderAlive returns true if we heard from the leader in the half of the election timeout.
+func (r *Raft) isLeaderAlive() bool {
+	last := time.Unix(0, atomic.LoadInt64(&r.leaderAliveAt))
+	return r.clock.Now().Sub(last) < time.Duration(r.getElectionTimeout()/2)*time.Millisecond
+}
+
+// isPreVoteGrantedRecently returns true if we granted a prevote to other node in the half of the election timeout,
+// that node is on the way to be a candidate, give it a chance.
+func (r *Raft) isPreVoteGrantedRecently() bool {
+	last := time.Unix(0, atomic.LoadInt64(&r.preVoteGrantedAt))
+	return r.clock.Now().Sub(last) < time.Duration(r.getElectionTimeout()/2)*time.Millisecond
+}
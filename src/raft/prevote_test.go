@@ -0,0 +1,69 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"testing"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the prevote responses are counted by the state loop one by one.
+//
+// TEST PROCESSES:
+// 1. the round is answered after all the peers respond, the newer viewid is taken
+// 2. the majority without denial is granted
+// 3. one denial fails the round
+func TestRaftPreVoteRound(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-prevote")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	names := []string{"127.0.0.1:0101", "127.0.0.1:0202", "127.0.0.1:0303"}
+	raft := NewRaft(names[0], conf, 10000, log, mysql57, FOLLOWER)
+	raft.AddPeer(names[1])
+	raft.AddPeer(names[2])
+	viewID := raft.getViewID()
+
+	response := func(from string, retCode string, viewID uint64) *model.RaftRPCResponse {
+		rsp := model.NewRaftRPCResponse(retCode)
+		rsp.Raft.From = from
+		rsp.Raft.ViewID = viewID
+		rsp.Raft.State = FOLLOWER.String()
+		return rsp
+	}
+
+	// 1. the round is answered after all the peers respond, the newer viewid is taken
+	round := &preVoteRound{pending: 2, granted: 1}
+	assert.False(t, raft.processPreVoteResponse(round, response(names[1], model.OK, viewID)))
+	assert.True(t, raft.processPreVoteResponse(round, response(names[2], model.ErrorInvalidViewID, viewID+3)))
+	assert.Equal(t, viewID+3, raft.getViewID())
+
+	// 2. the majority without denial is granted
+	assert.True(t, raft.preVoteGranted(round))
+
+	// 3. one denial fails the round
+	fails := raft.getStats().PreVoteFails
+	round = &preVoteRound{pending: 2, granted: 1}
+	raft.processPreVoteResponse(round, response(names[1], model.OK, viewID))
+	raft.processPreVoteResponse(round, response(names[2], model.ErrorLowerPriority, viewID))
+	assert.False(t, raft.preVoteGranted(round))
+	assert.Equal(t, fails+1, raft.getStats().PreVoteFails)
+}
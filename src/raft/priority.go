@@ -0,0 +1,44 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"mysql"
+)
+
+// getCandidacyDelay returns the delay(ms) before this node becomes CANDIDATE.
+// every reachable peer with the higher priority delays us a half of the election timeout,
+// so the highest priority node campaigns first, the zone delay is added at last.
+func (r *Raft) getCandidacyDelay() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	higher := 0
+	for _, peer := range r.peers {
+		if peer.getPriority() > r.getPriority() {
+			higher++
+		}
+	}
+	return higher*r.getElectionTimeout()/2 + r.getZoneDelay()
+}
+
+// isLowerPriority returns true if the candidate has the same GTID with us but the lower priority,
+// we would rather be the leader ourself.
+// the candidate of a leader transfer is never vetoed, the leader has chosen it.
+func (r *Raft) isLowerPriority(req *model.RaftRPCRequest, thisGTID *model.GTID) bool {
+	if req.Raft.Transfer || r.getPriority() <= req.Raft.Priority {
+		return false
+	}
+
+	if relation, _, _, err := mysql.CompareGTIDSet(thisGTID, &req.GTID); err != nil || relation != mysql.GTIDEqual {
+		return false
+	}
+	return r.mysql.Promotable()
+}
@@ -18,11 +18,16 @@ import (
 	"time"
 	"xbase/common"
 	"xbase/xlog"
+	"xbase/xrpc"
 )
 
 const (
 	// metaFile is the file for storing raft metadata
-	metaFile = "peers.json"
+	metaFile = "raft.meta.json"
+
+	// peersFile is the legacy file which only stored the peers, it will be
+	// migrated to metaFile on startup
+	peersFile = "peers.json"
 )
 
 type ev struct {
@@ -33,14 +38,27 @@ type ev struct {
 
 // RaftMeta tuple.
 type RaftMeta struct {
-	ViewID  uint64
-	EpochID uint64
+	ViewID  uint64 `json:"viewid"`
+	EpochID uint64 `json:"epochid"`
 
 	// The Peers(endpoint) expect SuperIDLE
-	Peers []string
+	Peers []string `json:"peers"`
 
 	// The SuperIDLE Peers(endpoint)
-	IdlePeers []string
+	IdlePeers []string `json:"idlepeers"`
+
+	// The WITNESS Peers(endpoint), they are in Peers too
+	Witnesses []string `json:"witnesses,omitempty"`
+
+	// The Peers(endpoint) of the new configuration during a membership change,
+	// the majorities of both Peers and NextPeers are required until it's committed
+	NextPeers []string `json:"nextpeers,omitempty"`
+
+	// The zone labels of the peers(endpoint -> zone)
+	Zones map[string]string `json:"zones,omitempty"`
+
+	// The cluster ID created by the first leader, the messages of the other clusters are rejected
+	ClusterID string `json:"cluster-id,omitempty"`
 }
 
 // Raft tuple.
@@ -48,6 +66,8 @@ type Raft struct {
 	log                      *xlog.Log
 	mysql                    *mysql.Mysql
 	cmd                      common.Command
+	clock                    common.Clock
+	transport                xrpc.Transport
 	conf                     *config.RaftConfig
 	initRole                 State // The temporary role specified on the first startup
 	leader                   string
@@ -57,11 +77,24 @@ type Raft struct {
 	state                    State
 	meta                     *RaftMeta
 	mutex                    sync.RWMutex
+	metaMutex                sync.Mutex   // serializes the meta file writes
+	zoneMutex                sync.RWMutex // protects meta.Zones, it's updated by the peer responses
+	epochAcksMutex           sync.Mutex   // protects epochAcks
+	hookMutex                sync.Mutex   // protects hookResults, the last results of the hooks
+	eventMutex               sync.Mutex   // protects history and subscribers
+	lagMutex                 sync.RWMutex // protects lags
+	maintenanceMutex         sync.Mutex   // protects maintenance
+	failoverMutex            sync.Mutex   // protects leaderChanges, leaderChangeAt, freeze and clusterFreeze
+	learnerMutex             sync.Mutex   // protects learnerProgress and learnerHeartbeatAt
+	timingsMutex             sync.RWMutex // protects timings
+	clusterIDMutex           sync.RWMutex // protects meta.ClusterID
+	standbyMutex             sync.Mutex   // protects standby
+	standbySyncMutex         sync.Mutex   // serializes the standby sync and the dr-promote
 	lock                     sync.WaitGroup
-	heartbeatTick            *time.Timer
-	electionTick             *time.Timer
-	checkBrainSplitTick      *time.Timer
-	checkVotesTick           *time.Timer
+	heartbeatTick            *common.Timer
+	electionTick             *common.Timer
+	checkBrainSplitTick      *common.Timer
+	checkVotesTick           *common.Timer
 	stateBegin               time.Time
 	c                        chan *ev
 	L                        *Leader
@@ -70,14 +103,43 @@ type Raft struct {
 	I                        *Idle
 	IV                       *Invalid
 	LN                       *Learner
-	peers                    map[string]*Peer // all peers expect SuperIDLE
-	idlePeers                map[string]*Peer // all SuperIDLE peers
+	W                        *Witness
+	peers                    map[string]*Peer    // all peers expect SuperIDLE
+	idlePeers                map[string]*Peer    // all SuperIDLE peers
+	epochAcks                map[string]uint64   // the EpochID acked by the peers in the heartbeat responses
+	lags                     map[string]*peerLag // the replication lag of the peers tracked by the leader
 	stats                    model.RaftStats
 	skipPurgeBinlog          bool   // if true, purge binlog will skipped
 	skipCheckSemiSync        bool   // if true, check semi-sync will skipped
 	semiSyncTimeoutFor2Nodes uint64 // It only works if peers are 2
 	isBrainSplit             bool   // if true, follower can upgrade to candidate
 	gtid                     model.GTID
+	transferring             int32 // if 1, a leader transfer is in progress
+	transferCampaign         int32 // if 1, this node campaigns for or holds the leadership of a leader transfer
+	changing                 int32 // if 1, a membership change is in progress
+	leaderAliveAt            int64 // the last time(UnixNano) we heard from the leader or the candidate we voted for
+	preVoteGrantedAt         int64 // the last time(UnixNano) we granted a prevote to other node
+	leaseRenewAt             int64 // the last time(UnixNano) the leader renewed the lease
+	hookResults              []model.RaftHookResult
+	lastLeader               string     // the last known leader, it's kept after the leader is lost
+	oldLeader                string     // the last known leader before this node was promoted
+	leaderRepl               model.Repl // the MySQL replication info of the leader leaderReplFrom, it's the fencing target
+	leaderReplFrom           string
+	history                  []model.RaftEvent
+	subscribers              map[chan model.RaftEvent]bool
+	historyDirty             bool       // the history has the events not persisted yet
+	historyWriting           bool       // the history writer is running
+	historyIdle              *sync.Cond // broadcasts on eventMutex when the history writer exits
+	maintenance              *model.RaftMaintenance    // nil if this node is not in maintenance
+	leaderChanges            []time.Time               // the times of the leader changes this node saw in the failover window
+	leaderChangeAt           time.Time                 // the time of the last leader change this node saw
+	plannedLeader            string                    // the leader elected by a leader transfer, the change to it is not counted
+	freeze                   *model.RaftFreeze         // nil if the failover is not frozen
+	clusterFreeze            *model.RaftFreeze         // the cluster freeze set by the operator, nil if it's not set
+	learnerProgress          model.RaftLearnerProgress // the LEARNER progress toward the automatic promotion
+	learnerHeartbeatAt       int64                     // the time(unix ms) of the last heartbeat the LEARNER tracked
+	timings                  model.RaftTimings         // the timings in use, they can be changed at runtime
+	standby                  model.RaftStandby         // the disaster recovery standby state, only if conf.StandbyOf is set
 }
 
 // NewRaft creates the new raft.
@@ -87,6 +149,8 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 		conf:                     conf,
 		log:                      log,
 		cmd:                      common.NewLinuxCommand(log),
+		clock:                    common.RealClock,
+		transport:                xrpc.DefaultTransport,
 		mysql:                    mysql,
 		initRole:                 state,
 		leader:                   noLeader,
@@ -94,10 +158,15 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 		meta:                     &RaftMeta{},
 		peers:                    make(map[string]*Peer),
 		idlePeers:                make(map[string]*Peer),
+		epochAcks:                make(map[string]uint64),
+		lags:                     make(map[string]*peerLag),
+		subscribers:              make(map[chan model.RaftEvent]bool),
 		skipCheckSemiSync:        false,
 		semiSyncTimeoutFor2Nodes: semiSyncTimeout,
 	}
 
+	r.historyIdle = sync.NewCond(&r.eventMutex)
+
 	// state handler
 	r.L = NewLeader(r)
 	r.C = NewCandidate(r)
@@ -105,19 +174,27 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 	r.I = NewIdle(r)
 	r.IV = NewInvalid(r)
 	r.LN = NewLearner(r)
+	r.W = NewWitness(r)
 
 	// setup raft timeout
+	r.recoverTimings()
 	r.resetHeartbeatTimeout()
 	r.resetElectionTimeout()
 	r.resetCheckVotesTimeout()
 
-	// setup peers
-	r.initPeers()
-
 	// setup meta datadir
 	if err := os.MkdirAll(r.conf.MetaDatadir, 0777); err != nil {
 		log.Panic("create.meta.dir[%v].error[%v]", r.conf.MetaDatadir, err)
 	}
+	r.recoverHistory()
+	r.recoverMaintenance()
+	r.recoverFreeze()
+	r.recoverClusterFreeze()
+	r.recoverStandby()
+
+	// setup peers
+	r.initPeers()
+	r.checkHooks()
 	return r
 }
 
@@ -128,6 +205,10 @@ func (r *Raft) Start() error {
 	r.c = make(chan *ev)
 
 	// state
+	if from := r.getState(); from == STOPPED {
+		r.state = FOLLOWER
+		r.emitStateChange(from, FOLLOWER)
+	}
 	if r.conf.SuperIDLE {
 		r.setState(IDLE)
 		r.WARNING("start.as.super.IDLE")
@@ -140,6 +221,7 @@ func (r *Raft) Start() error {
 	switch r.initRole {
 	case LEADER:
 		r.setState(LEADER)
+		r.oldLeader = r.lastLeader
 		r.setLeader(r.getID())
 		r.IncLeaderPromotes()
 	case FOLLOWER:
@@ -148,6 +230,12 @@ func (r *Raft) Start() error {
 		r.setState(IDLE)
 	}
 
+	// WITNESS is a permanent role, no matter what the init role is
+	if r.IsWitness() {
+		r.setState(WITNESS)
+		r.WARNING("start.as.WITNESS")
+	}
+
 	// state loops
 	r.lock.Add(1)
 	go func() {
@@ -176,6 +264,7 @@ func (r *Raft) Stop() error {
 	// wait all goroutine stopped
 	r.lock.Wait()
 	r.freePeers()
+	r.waitHistory()
 	r.WARNING("raft.stopped...")
 	return nil
 }
@@ -185,18 +274,10 @@ func (r *Raft) initPeers() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	metaPath := filepath.Join(r.conf.MetaDatadir, metaFile)
-	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
-		r.WARNING("peers.json.file[%v].does.not.exist", metaPath)
-	} else {
-		peers, idlePeers, _ := readPeersJSON(filepath.Join(r.conf.MetaDatadir, metaFile))
-		r.meta.Peers = append(r.meta.Peers, peers...)
-		r.meta.IdlePeers = append(r.meta.IdlePeers, idlePeers...)
-		r.WARNING("prepare.to.recovery.peers.from.[%v].peers[%v].idlePeers[%v]", r.conf.MetaDatadir, r.meta.Peers, r.meta.IdlePeers)
-	}
+	r.recoverMeta()
 
 	// create peers
-	for _, connStr := range r.meta.Peers {
+	for _, connStr := range unionPeers(r.meta.Peers, r.meta.NextPeers) {
 		if connStr != r.getID() {
 			p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
 			r.peers[connStr] = p
@@ -224,13 +305,19 @@ func (r *Raft) initPeers() {
 
 // free all peers
 func (r *Raft) freePeers() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	for _, peer := range r.peers {
 		peer.freePeer()
 	}
+	for _, peer := range r.idlePeers {
+		peer.freePeer()
+	}
 }
 
 // send command to state machine(F/C/L/I/S) loop with maxSendTime timeout
 // (F/C/L/I/S)-loop should handle it and return
+// the timeout is on the wall clock, it bounds the rpc but not the raft timing
 func (r *Raft) send(t int, request interface{}, maxSendTime int) (interface{}, error) {
 	if !r.running() {
 		return nil, errStop
@@ -279,6 +366,8 @@ func (r *Raft) stateLoop() {
 			r.IV.Loop()
 		case LEARNER:
 			r.LN.Loop()
+		case WITNESS:
+			r.W.Loop()
 		}
 		state = r.getState()
 	}
@@ -295,18 +384,19 @@ func (r *Raft) updateView(viewid uint64, leader string) {
 	r.WARNING("do.updateViewID[FROM:%v TO:%v]", r.meta.ViewID, viewid)
 
 	// update leader and viewid
-	r.leader = leader
+	r.setLeader(leader)
 	r.votedFor = noVote
 	r.meta.ViewID = viewid
+	r.writeMeta()
 }
 
-func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string) {
+func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string, witnesses []string, nextPeers []string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	mark := make(map[string]bool)
 
-	// update peers
-	for _, name := range peers {
+	// update peers, the peers of the new configuration are included during a membership change
+	for _, name := range unionPeers(peers, nextPeers) {
 		if r.peers[name] == nil {
 			if name != r.getID() {
 				p := NewPeer(r, name, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
@@ -342,48 +432,110 @@ func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string) {
 		}
 	}
 	r.meta.IdlePeers = idlePeers
+	r.meta.Witnesses = witnesses
+	r.meta.NextPeers = nextPeers
 
-	r.meta.EpochID = epochid
-	r.writePeersJSON()
+	if from := r.getEpochID(); from != epochid {
+		r.meta.EpochID = epochid
+		r.emitEpochChange(from, epochid)
+	}
+	r.writeMeta()
 }
 
-func (r *Raft) writePeersJSON() {
+// recoverMeta restores the raft meta and votedFor from the meta file,
+// if there is only a legacy peers.json, migrates it to the meta file.
+func (r *Raft) recoverMeta() {
 	metaPath := filepath.Join(r.conf.MetaDatadir, metaFile)
-	if err := writePeersJSON(metaPath, r.meta.Peers, r.meta.IdlePeers); err != nil {
-		r.PANIC("writePeers[%v].to[%v].error[%+v]", metaPath, r.meta.Peers, err)
+	peersPath := filepath.Join(r.conf.MetaDatadir, peersFile)
+
+	if _, err := os.Stat(metaPath); err == nil {
+		meta, err := readMetaJSON(metaPath)
+		if err != nil {
+			r.PANIC("read.meta.file[%v].error[%+v]", metaPath, err)
+		}
+		*r.meta = meta.RaftMeta
+		r.votedFor = meta.VotedFor
+		r.WARNING("prepare.to.recovery.meta.from.[%v].meta[%+v].votedFor[%v]", metaPath, *r.meta, r.votedFor)
+		return
+	}
+	r.WARNING("meta.file[%v].does.not.exist", metaPath)
+
+	if _, err := os.Stat(peersPath); err == nil {
+		peers, idlePeers, err := readPeersJSON(peersPath)
+		if err != nil {
+			r.PANIC("read.legacy.peers.file[%v].error[%+v]", peersPath, err)
+		}
+		r.meta.Peers = append(r.meta.Peers, peers...)
+		r.meta.IdlePeers = append(r.meta.IdlePeers, idlePeers...)
+		r.writeMeta()
+		if err := os.Remove(peersPath); err != nil {
+			r.ERROR("remove.legacy.peers.file[%v].error[%+v]", peersPath, err)
+		}
+		r.WARNING("migrate.legacy.peers.file[%v].to[%v].peers[%v].idlePeers[%v]", peersPath, metaPath, r.meta.Peers, r.meta.IdlePeers)
 	}
+}
+
+// writeMeta persists the raft meta and votedFor to the meta file.
+func (r *Raft) writeMeta() {
+	r.metaMutex.Lock()
+	defer r.metaMutex.Unlock()
 
-	// Check the meta path.
-	fileInfo, err := os.Lstat(metaPath)
-	if err != nil {
-		r.ERROR("check.peers.json[%s].error[%+v]", metaPath, err)
+	metaPath := filepath.Join(r.conf.MetaDatadir, metaFile)
+	meta := &metaJSON{
+		RaftMeta: RaftMeta{
+			ViewID:    r.getViewID(),
+			EpochID:   r.getEpochID(),
+			Peers:     r.meta.Peers,
+			IdlePeers: r.meta.IdlePeers,
+			Witnesses: r.meta.Witnesses,
+			NextPeers: r.meta.NextPeers,
+			Zones:     r.getPeerZones(),
+			ClusterID: r.getClusterID(),
+		},
+		VotedFor: r.votedFor,
 	}
-	r.INFO("check.peers.json.file[%s].stat[name:%v, mode:%v, size:%v, lastmodified:%v]", metaPath, fileInfo.Name(), fileInfo.Mode(), fileInfo.Size(), fileInfo.ModTime())
+	if err := writeMetaJSON(metaPath, meta); err != nil {
+		r.PANIC("write.meta[%+v].to[%v].error[%+v]", *meta, metaPath, err)
+	}
+	r.DEBUG("write.meta[%+v].to[%v].done", *meta, metaPath)
 }
 
 func (r *Raft) updateStateBegin() {
-	r.stateBegin = time.Now()
+	r.stateBegin = r.clock.Now()
+}
+
+// SetClock used to set the clock of the raft timers, it must be called before Start.
+func (r *Raft) SetClock(clock common.Clock) {
+	r.clock = clock
+	r.resetHeartbeatTimeout()
+	r.resetElectionTimeout()
+	r.resetCheckVotesTimeout()
+}
+
+// SetTransport used to set the transport of the peer sessions, it must be called before the peers are added or Start.
+func (r *Raft) SetTransport(transport xrpc.Transport) {
+	r.transport = transport
 }
 
 func (r *Raft) resetHeartbeatTimeout() {
-	common.NormalTimerRelaese(r.heartbeatTick)
-	r.heartbeatTick = common.NormalTimeout(r.getHeartbeatTimeout())
+	common.TimerRelease(r.heartbeatTick)
+	r.heartbeatTick = common.NewNormalTimer(r.clock, r.getHeartbeatTimeout())
 }
 
 func (r *Raft) resetElectionTimeout() {
-	common.NormalTimerRelaese(r.electionTick)
-	r.electionTick = common.RandomTimeout(r.getElectionTimeout())
+	common.TimerRelease(r.electionTick)
+	r.electionTick = common.NewRandomTimer(r.clock, r.getElectionTimeout())
 }
 
 func (r *Raft) resetCheckBrainSplitTimeout() {
-	common.NormalTimerRelaese(r.checkBrainSplitTick)
-	r.checkBrainSplitTick = common.NormalTimeout(r.getElectionTimeout() / 2)
+	common.TimerRelease(r.checkBrainSplitTick)
+	r.checkBrainSplitTick = common.NewNormalTimer(r.clock, r.getElectionTimeout()/2)
 }
 
 func (r *Raft) resetCheckVotesTimeout() {
 	// timeout is 1/2 of electiontimout
-	common.NormalTimerRelaese(r.checkVotesTick)
-	r.checkVotesTick = common.NormalTimeout(r.getElectionTimeout() / 2)
+	common.TimerRelease(r.checkVotesTick)
+	r.checkVotesTick = common.NewNormalTimer(r.clock, r.getElectionTimeout()/2)
 }
 
 // SetSkipPurgeBinlog used to set purge binlog or not.
@@ -367,6 +367,11 @@ func TestRaftDoubleClusterDiffraction(t *testing.T) {
 	_, rafts2, cleanup2 := MockRafts(log, port, 2, -1)
 	defer cleanup2()
 
+	// the two clusters are diffracted from one, they have the same cluster ID
+	for _, raft := range append(append([]*Raft{}, rafts1...), rafts2...) {
+		raft.setClusterID("0dd3f5ee-3d2e-4a4e-9d0b-5a2f0b0c8e61", testName)
+	}
+
 	// Start cluster1
 	{
 		for _, raft := range rafts1 {
@@ -1952,6 +1957,110 @@ func TestRaftLeaderAckLessThanQuorum(t *testing.T) {
 	}
 }
 
+// TEST EFFECTS:
+// test the leader fences itself when the lease expired.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs
+// 3. mock the leader can't get the heartbeat acks
+// 4. check the leader fenced itself in the lease
+func TestRaftLeaderLeaseExpired(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. wait the leader eggs
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	assert.Equal(t, LEADER, leader.getState())
+
+	// 3. mock the leader can't get the heartbeat acks
+	leader.L.setProcessHeartbeatResponseHandler(leader.mockLeaderProcessSendHeartbeatResponse)
+
+	// 4. check the leader fenced itself before the followers election timeout
+	{
+		time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()))
+		assert.NotEqual(t, LEADER, leader.getState())
+		assert.Equal(t, uint64(1), leader.getStats().LeaderLeaseExpires)
+		assert.Equal(t, mysql.MysqlReadonly, leader.mysql.GetOption())
+	}
+}
+
+// TEST EFFECTS:
+// test the highest priority node wins the election when the GTIDs are equal.
+//
+// TEST PROCESSES:
+// 1. set rafts[2] with the highest priority
+// 2. Start 3 rafts state as FOLLOWER
+// 3. wait rafts[2] elected as leader
+func TestRaftPriorityElection(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts[2] with the highest priority
+	MockSetPriority(rafts[2], 10)
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. wait rafts[2] elected as leader
+	{
+		whoisleader := MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, 2, whoisleader)
+	}
+}
+
+// TEST EFFECTS:
+// test the node with priority 0 votes but never becomes CANDIDATE.
+//
+// TEST PROCESSES:
+// 1. set rafts[0] with priority 0
+// 2. Start 3 rafts state as FOLLOWER
+// 3. wait the leader eggs, it's not rafts[0]
+// 4. Stop the leader
+// 5. wait the new leader eggs with the vote of rafts[0]
+func TestRaftPriorityZeroNeverCandidate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts[0] with priority 0
+	MockSetPriority(rafts[0], 0)
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. wait the leader eggs, it's not rafts[0]
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	assert.NotEqual(t, 0, whoisleader)
+
+	// 4. Stop the leader
+	rafts[whoisleader].Stop()
+
+	// 5. wait the new leader eggs with the vote of rafts[0]
+	{
+		newleader := MockWaitLeaderEggs(rafts, 1)
+		assert.NotEqual(t, 0, newleader)
+		assert.NotEqual(t, whoisleader, newleader)
+		assert.Equal(t, FOLLOWER, rafts[0].getState())
+		assert.Equal(t, uint64(0), rafts[0].getStats().CandidatePromotes)
+	}
+}
+
 // TEST EFFECTS:
 // test the leader state init WaitUntilAfterGTID failed.
 //
@@ -2186,3 +2295,74 @@ func TestRaftElectionUnderFollowerAndCandidateAlternate(t *testing.T) {
 		assert.Equal(t, whoisleader, 1)
 	}
 }
+
+// TEST EFFECTS:
+// test the 2 data nodes and 1 witness cluster.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, rafts[2] as WITNESS
+// 2. wait the leader eggs from the data nodes
+// 3. Stop the leader
+// 4. wait the new leader eggs with the witness vote
+func TestRaftWitness(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, rafts[2] as WITNESS
+	{
+		MockSetWitness(rafts, 2)
+		for _, raft := range rafts {
+			raft.Start()
+		}
+		assert.Equal(t, WITNESS, rafts[2].getState())
+		assert.Equal(t, 2, rafts[0].getDataMembers())
+	}
+
+	// 2. wait the leader eggs from the data nodes
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	assert.NotEqual(t, 2, whoisleader)
+
+	// 3. Stop the leader
+	rafts[whoisleader].Stop()
+
+	// 4. wait the new leader eggs with the witness vote
+	{
+		newleader := MockWaitLeaderEggs(rafts, 1)
+		assert.NotEqual(t, 2, newleader)
+		assert.NotEqual(t, whoisleader, newleader)
+		assert.Equal(t, WITNESS, rafts[2].getState())
+		assert.Equal(t, rafts[newleader].getID(), rafts[2].getLeader())
+	}
+}
+
+// TEST EFFECTS:
+// test the witness rejects the candidate with the smaller GTID than the other candidate.
+func TestRaftWitnessCandidateGTID(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	MockSetWitness(rafts, 2)
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	witness := rafts[2].W
+
+	req0 := model.NewRaftRPCRequest()
+	req0.Raft.From = ids[0]
+	req0.GTID = model.GTID{Executed_GTID_Set: "6127a668-gtid-x555-a28d-5254335479b2:1-3"}
+	req1 := model.NewRaftRPCRequest()
+	req1.Raft.From = ids[1]
+	req1.GTID = model.GTID{Executed_GTID_Set: "6127a668-gtid-x555-a28d-5254335479b2:1-2"}
+
+	assert.True(t, witness.checkCandidateGTID(req0))
+	assert.False(t, witness.checkCandidateGTID(req1))
+	assert.True(t, witness.checkCandidateGTID(req0))
+
+	// the greater one is forgotten once the leader eggs
+	witness.resetCandidateGTID()
+	assert.True(t, witness.checkCandidateGTID(req1))
+}
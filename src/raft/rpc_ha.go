@@ -10,6 +10,7 @@ package raft
 
 import (
 	"model"
+	"time"
 )
 
 // HARPC tuple.
@@ -103,7 +104,26 @@ func (h *HARPC) HATryToLeader(req *model.HARPCRequest, rsp *model.HARPCResponse)
 	case INVALID:
 		rsp.RetCode = model.ErrorInvalidRequest
 		return nil
+	case WITNESS:
+		rsp.RetCode = model.ErrorInvalidRequest
+		return nil
 	}
+	if h.raft.getPriority() == 0 {
+		h.raft.WARNING("RPC.TryToLeader.priority.is.0.can.not.promote.to.candidate")
+		rsp.RetCode = model.ErrorNeverPromote
+		return nil
+	}
+	if h.raft.inMaintenance() {
+		h.raft.WARNING("RPC.TryToLeader.in.maintenance.can.not.promote.to.candidate")
+		rsp.RetCode = model.ErrorInMaintenance
+		return nil
+	}
+	if h.raft.isFailoverFrozen() {
+		h.raft.WARNING("RPC.TryToLeader.failover.is.frozen.can.not.promote.to.candidate")
+		rsp.RetCode = model.ErrorFailoverFrozen
+		return nil
+	}
+
 	// promotable cases:
 	// 1. MySQL is MYSQL_ALIVE
 	// 2. Slave_SQL_RNNNING is OK
@@ -116,6 +136,8 @@ func (h *HARPC) HATryToLeader(req *model.HARPCRequest, rsp *model.HARPCResponse)
 			rsp.RetCode = err.Error()
 			return nil
 		}
+		// the leader transfer or the operator proposes us, the voters skip the priority veto in the first election
+		h.raft.setTransferCampaign(true)
 		h.raft.setState(CANDIDATE)
 		h.raft.loopFired()
 		h.raft.IncCandidatePromotes()
@@ -127,6 +149,27 @@ func (h *HARPC) HATryToLeader(req *model.HARPCRequest, rsp *model.HARPCResponse)
 	return nil
 }
 
+// HATransferLeader rpc.
+func (h *HARPC) HATransferLeader(req *model.HATransferLeaderRPCRequest, rsp *model.HATransferLeaderRPCResponse) error {
+	h.raft.WARNING("RPC.HATransferLeader.to[%v].call.from[%v]", req.GetTo(), req.GetFrom())
+	h.raft.transferLeader(req.GetTo(), rsp)
+	return nil
+}
+
+// HAEnterMaintenance rpc.
+func (h *HARPC) HAEnterMaintenance(req *model.HAMaintenanceRPCRequest, rsp *model.HAMaintenanceRPCResponse) error {
+	h.raft.WARNING("RPC.HAEnterMaintenance.duration[%vms].reason[%v].call.from[%v]", req.Duration, req.Reason, req.GetFrom())
+	h.raft.enterMaintenance(time.Duration(req.Duration)*time.Millisecond, req.Reason, rsp)
+	return nil
+}
+
+// HAExitMaintenance rpc.
+func (h *HARPC) HAExitMaintenance(req *model.HAMaintenanceRPCRequest, rsp *model.HAMaintenanceRPCResponse) error {
+	h.raft.WARNING("RPC.HAExitMaintenance.call.from[%v]", req.GetFrom())
+	h.raft.exitMaintenance(rsp)
+	return nil
+}
+
 // GetHARPC returns HARPC.
 func (s *Raft) GetHARPC() *HARPC {
 	return &HARPC{s}
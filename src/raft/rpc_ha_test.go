@@ -9,6 +9,7 @@
 package raft
 
 import (
+	"database/sql"
 	"model"
 	"mysql"
 	"testing"
@@ -843,3 +844,233 @@ func TestRaftSuperIDLEEnableHA(t *testing.T) {
 	idlerLeader2 := idler.getLeader()
 	assert.NotEqual(t, idlerLeader1, idlerLeader2)
 }
+
+// TEST EFFECTS:
+// test HATransferLeader RPC call from the client
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs
+// 3. send transfer leader to the follower
+// 4. check the follower is the new leader
+func TestRaftRPCHATransferLeader(t *testing.T) {
+	var whoisleader, target int
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. check
+	{
+		MockWaitLeaderEggs(rafts, 1)
+
+		var got State
+		want := (LEADER + FOLLOWER + FOLLOWER)
+		for i, raft := range rafts {
+			got += raft.getState()
+			if raft.getState() == LEADER {
+				whoisleader = i
+			}
+		}
+		// [LEADER, FOLLOWER, FOLLOWER]
+		assert.Equal(t, want, got)
+		target = (whoisleader + 1) % len(rafts)
+	}
+
+	// 3. send transfer leader to the follower
+	{
+		c, cleanup := MockGetClient(t, names[target])
+		defer cleanup()
+
+		method := model.RPCHATransferLeader
+		req := model.NewHATransferLeaderRPCRequest()
+		req.To = names[whoisleader]
+		rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorInvalidRequest, rsp.RetCode)
+	}
+
+	// 4. transfer leader to the target
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHATransferLeader
+		req := model.NewHATransferLeaderRPCRequest()
+		req.To = names[target]
+		rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.True(t, rsp.TotalTime >= rsp.CatchUpTime+rsp.ElectionTime)
+	}
+
+	// 5. check
+	{
+		MockWaitLeaderEggs(rafts, 1)
+
+		var got State
+		want := (LEADER + FOLLOWER + FOLLOWER)
+		for _, raft := range rafts {
+			got += raft.getState()
+		}
+		// [LEADER, FOLLOWER, FOLLOWER]
+		assert.Equal(t, want, got)
+		assert.Equal(t, LEADER, rafts[target].getState())
+	}
+}
+
+// TEST EFFECTS:
+// test HATransferLeader RPC to the follower with the lower priority
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs
+// 3. set the target priority lower than the others
+// 4. transfer leader to the target
+// 5. check the target is the new leader, the priority veto is skipped
+func TestRaftRPCHATransferLeaderToLowerPriority(t *testing.T) {
+	var whoisleader, target int
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. check
+	{
+		MockWaitLeaderEggs(rafts, 1)
+
+		for i, raft := range rafts {
+			if raft.getState() == LEADER {
+				whoisleader = i
+			}
+		}
+		target = (whoisleader + 1) % len(rafts)
+	}
+
+	// 3. set the target priority lower than the others
+	for i, raft := range rafts {
+		if i == target {
+			MockSetPriority(raft, 1)
+		} else {
+			MockSetPriority(raft, 10)
+		}
+	}
+
+	// 4. transfer leader to the target
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHATransferLeader
+		req := model.NewHATransferLeaderRPCRequest()
+		req.To = names[target]
+		rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, names[target], rsp.Leader)
+	}
+
+	// 5. check the target is the new leader, the priority veto is skipped
+	{
+		MockWaitLeaderEggs(rafts, 1)
+
+		var got State
+		want := (LEADER + FOLLOWER + FOLLOWER)
+		for _, raft := range rafts {
+			got += raft.getState()
+		}
+		// [LEADER, FOLLOWER, FOLLOWER]
+		assert.Equal(t, want, got)
+		assert.Equal(t, LEADER, rafts[target].getState())
+		// the leader keeps the transfer mark, the members don't count the planned change
+		assert.True(t, rafts[target].isTransferCampaign())
+	}
+}
+
+// TEST EFFECTS:
+// test HATransferLeader RPC failed when the target can't catch up
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as FOLLOWER
+// 2. wait the leader eggs
+// 3. set the leader GTID_SUBTRACT always returns the missing GTID
+// 4. send transfer leader to the follower
+// 5. check the leader is unchanged
+func TestRaftRPCHATransferLeaderFail_CatchUp(t *testing.T) {
+	var whoisleader, target int
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.conf.TransferLeaderTimeout = 1000
+		raft.Start()
+	}
+
+	// 2. check
+	{
+		MockWaitLeaderEggs(rafts, 1)
+
+		for i, raft := range rafts {
+			if raft.getState() == LEADER {
+				whoisleader = i
+			}
+		}
+		target = (whoisleader + 1) % len(rafts)
+	}
+
+	// 3. set the leader GTID_SUBTRACT always returns the missing GTID
+	{
+		handler := mysql.NewMockGTIDA()
+		handler.GetGTIDSubtractFn = func(db *sql.DB, subsetGTID string, setGTID string) (string, error) {
+			return "c78e798a-cccc-cccc-cccc-525433e8e796:3", nil
+		}
+		rafts[whoisleader].mysql.SetMysqlHandler(handler)
+	}
+
+	// 4. transfer leader to the target
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCHATransferLeader
+		req := model.NewHATransferLeaderRPCRequest()
+		req.To = names[target]
+		rsp := model.NewHATransferLeaderRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorCatchUpTimeout, rsp.RetCode)
+		assert.Equal(t, names[whoisleader], rsp.Leader)
+		assert.True(t, rsp.CatchUpTime >= 1000)
+	}
+
+	// 5. check the leader is unchanged
+	{
+		var got State
+		want := (LEADER + FOLLOWER + FOLLOWER)
+		for _, raft := range rafts {
+			got += raft.getState()
+		}
+		// [LEADER, FOLLOWER, FOLLOWER]
+		assert.Equal(t, want, got)
+		assert.Equal(t, LEADER, rafts[whoisleader].getState())
+	}
+}
@@ -11,6 +11,7 @@ package raft
 import (
 	"model"
 	"strconv"
+	"time"
 )
 
 // RaftRPC tuple.
@@ -21,31 +22,83 @@ type RaftRPC struct {
 // Ping rpc.
 // send MsgRaftPing
 func (r *RaftRPC) Ping(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	if ret := r.raft.checkClusterIDRequest(req); ret != nil {
+		*rsp = *ret
+		return nil
+	}
 	ret, err := r.raft.send(MsgRaftPing, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.ClusterID = r.raft.getClusterID()
 	return nil
 }
 
 // Heartbeat rpc.
+// the receiver follows the leader timings if they are newer, and returns its timings for the leader to follow,
+// the receiver without cluster ID takes the leader one, the standby receiver follows the leader detach
 func (r *RaftRPC) Heartbeat(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	if ret := r.raft.checkClusterIDRequest(req); ret != nil {
+		*rsp = *ret
+		return nil
+	}
+	if req.Raft.Transfer {
+		r.raft.setPlannedLeader(req.GetFrom())
+	}
 	ret, err := r.raft.send(MsgRaftHeartbeat, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	if rsp.RetCode == model.OK {
+		r.raft.adoptClusterID(req.GetFrom(), req.Raft.ClusterID)
+		r.raft.updateTimings(req.GetFrom(), req.Timings)
+		r.raft.updateStandby(req.GetFrom(), req.Standby)
+	}
+	timings := r.raft.getTimings()
+	rsp.Timings = &timings
+	rsp.Raft.ClusterID = r.raft.getClusterID()
 	return nil
 }
 
 // RequestVote rpc.
+// no vote is granted if the failover is frozen or the candidate is of a foreign cluster
 func (r *RaftRPC) RequestVote(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	if ret := r.raft.checkClusterIDRequest(req); ret != nil {
+		*rsp = *ret
+		r.raft.emitVote(req, rsp)
+		return nil
+	}
+	if r.raft.isFailoverFrozen() {
+		r.raft.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].failover.is.frozen.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		*rsp = *r.raft.frozenResponse()
+		r.raft.emitVote(req, rsp)
+		return nil
+	}
 	ret, err := r.raft.send(MsgRaftRequestVote, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.ClusterID = r.raft.getClusterID()
+	r.raft.emitVote(req, rsp)
+	return nil
+}
+
+// PreVote rpc.
+// send MsgRaftPreVote, it's handled by the state machine loop like the vote
+func (r *RaftRPC) PreVote(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	if ret := r.raft.checkClusterIDRequest(req); ret != nil {
+		*rsp = *ret
+		return nil
+	}
+	ret, err := r.raft.send(MsgRaftPreVote, req, r.raft.getHeartbeatTimeout())
+	if err != nil {
+		return err
+	}
+	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.ClusterID = r.raft.getClusterID()
 	return nil
 }
 
@@ -54,10 +107,129 @@ func (r *RaftRPC) Status(req *model.RaftStatusRPCRequest, rsp *model.RaftStatusR
 	rsp.RetCode = model.OK
 	rsp.State = r.raft.GetState().String()
 	rsp.Stats = r.raft.getStats()
+	rsp.Lease = r.raft.getLease()
+	rsp.Hooks = r.raft.getHookResults()
+	rsp.Lags = r.raft.getLags()
+	rsp.Maintenance = r.raft.getMaintenance()
+	rsp.Freeze = r.raft.getFreeze()
+	rsp.ClusterFreeze = r.raft.getClusterFreeze()
+	rsp.Learner = r.raft.getLearnerProgress()
+	rsp.Timings = r.raft.getTimings()
+	rsp.Standby = r.raft.getStandby()
+	rsp.LeaderChanges = r.raft.getLeaderChanges()
 	rsp.IdleCount, _ = strconv.ParseUint(strconv.Itoa(len(r.raft.getIdlePeers())), 10, 64)
 	return nil
 }
 
+// History rpc.
+func (r *RaftRPC) History(req *model.RaftHistoryRPCRequest, rsp *model.RaftHistoryRPCResponse) error {
+	rsp.RetCode = model.OK
+	rsp.Events = r.raft.GetHistory(req.Limit)
+	return nil
+}
+
+// Unfreeze rpc.
+// clears the failover freeze and the cluster freeze of this node
+func (r *RaftRPC) Unfreeze(req *model.RaftStatusRPCRequest, rsp *model.RaftStatusRPCResponse) error {
+	r.raft.WARNING("RPC.Unfreeze.call")
+	if err := r.raft.unfreeze(); err != nil {
+		r.raft.ERROR("RPC.Unfreeze.error[%+v]", err)
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	if err := r.raft.setClusterFreeze(nil, "unfreeze"); err != nil {
+		r.raft.ERROR("RPC.Unfreeze.cluster.freeze.error[%+v]", err)
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.RetCode = model.OK
+	return nil
+}
+
+// SetTimings rpc.
+// changes the timings on the leader, the leader carries them to all the members in the heartbeats
+func (r *RaftRPC) SetTimings(req *model.RaftTimingsRPCRequest, rsp *model.RaftTimingsRPCResponse) error {
+	r.raft.WARNING("RPC.SetTimings.call.from[%v].timings[%+v]", req.From, req.Timings)
+	rsp.Leader = r.raft.getLeader()
+	if r.raft.getState() != LEADER {
+		r.raft.WARNING("RPC.SetTimings.i.am.not.leader[%v]", r.raft.getState())
+		rsp.Timings = r.raft.getTimings()
+		rsp.RetCode = model.ErrorNotLeader
+		return nil
+	}
+
+	timings, err := r.raft.setTimings(req.From, req.Timings)
+	rsp.Timings = timings
+	if err != nil {
+		r.raft.ERROR("RPC.SetTimings.error[%v]", err)
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.RetCode = model.OK
+	return nil
+}
+
+// Primary rpc.
+// returns the MySQL replication info of the leader, the standby leader replicates from it
+func (r *RaftRPC) Primary(req *model.RaftPrimaryRPCRequest, rsp *model.RaftPrimaryRPCResponse) error {
+	if !r.raft.isStandbyNode(req.From) {
+		r.raft.WARNING("RPC.Primary.call.from[%v].not.a.standby.node%v", req.From, r.raft.conf.StandbyNodes)
+		rsp.RetCode = model.ErrorStandbyNotAllowed
+		return nil
+	}
+	rsp.Leader = r.raft.getLeader()
+	if r.raft.getState() != LEADER {
+		rsp.RetCode = model.ErrorNotLeader
+		return nil
+	}
+	rsp.Repl = r.raft.mysql.GetRepl()
+	rsp.RetCode = model.OK
+	return nil
+}
+
+// DRPromote rpc.
+// send MsgRaftDRPromote, the leader state machine loop starts it and we wait it done,
+// detaches the standby from the primary and makes the leader MySQL writable
+func (r *RaftRPC) DRPromote(req *model.RaftDRPromoteRPCRequest, rsp *model.RaftDRPromoteRPCResponse) error {
+	r.raft.WARNING("RPC.DRPromote.call.from[%v]", req.From)
+	ret, err := r.raft.send(MsgRaftDRPromote, req, r.raft.getHeartbeatTimeout())
+	if err != nil {
+		return err
+	}
+	*rsp = *<-ret.(chan *model.RaftDRPromoteRPCResponse)
+	if rsp.RetCode != model.OK {
+		r.raft.ERROR("RPC.DRPromote.error[%v]", rsp.RetCode)
+	}
+	return nil
+}
+
+// Freeze rpc.
+// sets the cluster freeze on the leader, the leader carries it to all the nodes in the heartbeats
+func (r *RaftRPC) Freeze(req *model.RaftFreezeRPCRequest, rsp *model.RaftFreezeRPCResponse) error {
+	r.raft.WARNING("RPC.Freeze.call.from[%v].until[%v].reason[%v]", req.From, req.Until, req.Reason)
+	rsp.Leader = r.raft.getLeader()
+	if r.raft.getState() != LEADER {
+		r.raft.WARNING("RPC.Freeze.i.am.not.leader[%v]", r.raft.getState())
+		rsp.RetCode = model.ErrorNotLeader
+		return nil
+	}
+
+	freeze := &model.RaftFreeze{
+		Reason: req.Reason,
+		Since:  r.raft.clock.Now().UnixNano() / int64(time.Millisecond),
+		By:     req.From,
+		Until:  req.Until,
+	}
+	if err := r.raft.setClusterFreeze(freeze, req.Reason); err != nil {
+		r.raft.ERROR("RPC.Freeze.error[%+v]", err)
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.ClusterFreeze = freeze
+	rsp.RetCode = model.OK
+	return nil
+}
+
 // EnablePurgeBinlog rpc.
 func (r *RaftRPC) EnablePurgeBinlog(req *model.RaftStatusRPCRequest, rsp *model.RaftStatusRPCResponse) error {
 	r.raft.SetSkipPurgeBinlog(false)
@@ -53,6 +53,11 @@ func TestRaftRPCStatus(t *testing.T) {
 		want := 1
 		got := int(rsp.Stats.LeaderPromotes)
 		assert.Equal(t, want, got)
+
+		// lease = election-timeout - heartbeat-timeout
+		assert.Equal(t, int64(shortHeartbeatTimeoutForTest*2), rsp.Lease.Timeout)
+		assert.True(t, rsp.Lease.Remaining > 0)
+		assert.True(t, rsp.Lease.RenewAt > 0)
 	}
 }
 
@@ -112,6 +117,170 @@ func TestRaftRPCs(t *testing.T) {
 	}
 }
 
+// TEST EFFECTS:
+// test the prevote rpc when the leader is alive
+//
+// TEST PROCESSES:
+// 1. start 3 rafts and wait the leader eggs
+// 2. send prevote to the LEADER, ErrorLeaderAlive
+// 3. send prevote to the FOLLOWER who gets the heartbeat, ErrorLeaderAlive
+// 4. check the viewid is unchanged
+func TestRaftRPCPreVoteLeaderAlive(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, scleanup := MockRafts(log, port, 3, -1)
+	defer scleanup()
+
+	// 1. start and wait the leader eggs
+	var whoisleader int
+	{
+		for _, raft := range rafts {
+			raft.Start()
+		}
+		whoisleader = MockWaitLeaderEggs(rafts, 1)
+	}
+	follower1 := (whoisleader + 1) % 3
+	follower2 := (whoisleader + 2) % 3
+	viewid := rafts[whoisleader].getViewID()
+
+	// 2. prevote to the LEADER
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCRaftPreVote
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = names[follower1]
+		req.Raft.ViewID = viewid + 1
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+
+		want := model.ErrorLeaderAlive
+		got := rsp.RetCode
+		assert.Equal(t, want, got)
+	}
+
+	// 3. prevote to the FOLLOWER
+	{
+		c, cleanup := MockGetClient(t, names[follower2])
+		defer cleanup()
+
+		method := model.RPCRaftPreVote
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = names[follower1]
+		req.Raft.ViewID = viewid + 1
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+
+		want := model.ErrorLeaderAlive
+		got := rsp.RetCode
+		assert.Equal(t, want, got)
+	}
+
+	// 4. check the viewid
+	{
+		for _, raft := range rafts {
+			assert.Equal(t, viewid, raft.getViewID())
+		}
+	}
+}
+
+// TEST EFFECTS:
+// test the prevote rpc when there is no leader
+//
+// TEST PROCESSES:
+// 1. start 1 raft with a mock peer, no leader
+// 2. send prevote with stale viewid, ErrorInvalidViewID
+// 3. send prevote with stale epochid, ErrorInvalidEpochID
+// 4. send prevote with larger viewid, OK
+// 5. check the viewid and votedFor are unchanged
+func TestRaftRPCPreVoteNoLeader(t *testing.T) {
+	mockHost := ":6666"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, scleanup := MockRafts(log, port, 1, -1)
+	defer scleanup()
+
+	// 1. start
+	{
+		for _, raft := range rafts {
+			raft.Start()
+		}
+		rafts[0].AddPeer(mockHost)
+		rafts[0].updateView(5, noLeader)
+	}
+	gtid, err := rafts[0].mysql.GetGTID()
+	assert.Nil(t, err)
+
+	// 2. prevote with stale viewid
+	{
+		c, cleanup := MockGetClient(t, names[0])
+		defer cleanup()
+
+		method := model.RPCRaftPreVote
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = mockHost
+		req.Raft.ViewID = 1
+		req.Raft.EpochID = rafts[0].getEpochID()
+		req.GTID = gtid
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+
+		want := model.ErrorInvalidViewID
+		got := rsp.RetCode
+		assert.Equal(t, want, got)
+	}
+
+	// 3. prevote with stale epochid
+	{
+		c, cleanup := MockGetClient(t, names[0])
+		defer cleanup()
+
+		method := model.RPCRaftPreVote
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = mockHost
+		req.Raft.ViewID = 6
+		req.Raft.EpochID = rafts[0].getEpochID() - 1
+		req.GTID = gtid
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+
+		want := model.ErrorInvalidEpochID
+		got := rsp.RetCode
+		assert.Equal(t, want, got)
+	}
+
+	// 4. prevote with larger viewid
+	{
+		c, cleanup := MockGetClient(t, names[0])
+		defer cleanup()
+
+		method := model.RPCRaftPreVote
+		req := model.NewRaftRPCRequest()
+		req.Raft.From = mockHost
+		req.Raft.ViewID = 6
+		req.Raft.EpochID = rafts[0].getEpochID()
+		req.GTID = gtid
+		rsp := model.NewRaftRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+
+		want := model.OK
+		got := rsp.RetCode
+		assert.Equal(t, want, got)
+	}
+
+	// 5. check the viewid and votedFor
+	{
+		assert.Equal(t, uint64(5), rafts[0].getViewID())
+		assert.Equal(t, noVote, rafts[0].votedFor)
+	}
+}
+
 func TestRaftRPCPurgeBinlog(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	port := common.RandomPort(8000, 9000)
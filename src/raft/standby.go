@@ -0,0 +1,297 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"path/filepath"
+	"strings"
+	"time"
+	"xbase/common"
+	"xbase/xrpc"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// standbyFile is the file for storing the detach of the disaster recovery standby
+	standbyFile = "raft.standby.json"
+
+	// standbyVersion is the version of the standby file format, bump it when the format changes.
+	standbyVersion = 1
+)
+
+// recoverStandby initializes the standby from the config, and restores the detach from the standby file,
+// so a detached standby never replicates from the primary again after restart.
+func (r *Raft) recoverStandby() {
+	if len(r.conf.StandbyOf) == 0 {
+		return
+	}
+	r.standby = model.RaftStandby{Primary: r.conf.StandbyOf}
+
+	standbyPath := filepath.Join(r.conf.MetaDatadir, standbyFile)
+	standby := &model.RaftStandby{}
+	ok, err := readVersionedJSON(standbyPath, standbyVersion, standby)
+	if err != nil {
+		r.PANIC("read.standby.file[%v].error[%+v]", standbyPath, err)
+	}
+	if !ok {
+		return
+	}
+	r.standby.DetachedAt = standby.DetachedAt
+	r.standby.DetachedBy = standby.DetachedBy
+	r.standby.DetachedGTID = standby.DetachedGTID
+	r.WARNING("recovery.standby.from[%v].standby[%+v]", standbyPath, r.standby)
+}
+
+// getStandby returns the standby state, nil if the cluster is not the disaster recovery standby.
+func (r *Raft) getStandby() *model.RaftStandby {
+	if len(r.conf.StandbyOf) == 0 {
+		return nil
+	}
+	r.standbyMutex.Lock()
+	defer r.standbyMutex.Unlock()
+	standby := r.standby
+	return &standby
+}
+
+// isStandby returns true if the cluster is the standby and it's not detached from the primary.
+func (r *Raft) isStandby() bool {
+	standby := r.getStandby()
+	return standby != nil && standby.DetachedAt == 0
+}
+
+// setStandbyPrimary records the primary leader the standby leader replicates from and the last error.
+func (r *Raft) setStandbyPrimary(leader string, master string, lastError string) {
+	r.standbyMutex.Lock()
+	defer r.standbyMutex.Unlock()
+	r.standby.PrimaryLeader = leader
+	r.standby.Master = master
+	r.standby.LastError = lastError
+}
+
+// detachStandby detaches the standby from the primary and persists it.
+func (r *Raft) detachStandby(at int64, by string, gtid string) error {
+	r.standbyMutex.Lock()
+	standby := r.standby
+	standby.DetachedAt, standby.DetachedBy, standby.DetachedGTID = at, by, gtid
+	if err := writeVersionedJSON(filepath.Join(r.conf.MetaDatadir, standbyFile), standbyVersion, &standby); err != nil {
+		r.standbyMutex.Unlock()
+		return errors.WithStack(err)
+	}
+	r.standby = standby
+	r.standbyMutex.Unlock()
+
+	r.WARNING("standby.detached.from.the.primary%v.by[%v].last.applied.gtid[%v]", standby.Primary, by, gtid)
+	return nil
+}
+
+// updateStandby follows the detach the leader carries in the heartbeat,
+// so the new leader never replicates from the primary after dr-promote.
+func (r *Raft) updateStandby(leader string, standby *model.RaftStandby) {
+	if standby == nil || standby.DetachedAt == 0 || !r.isStandby() {
+		return
+	}
+	if err := r.detachStandby(standby.DetachedAt, standby.DetachedBy, standby.DetachedGTID); err != nil {
+		r.ERROR("update.standby.from.leader[%v].error[%+v]", leader, err)
+	}
+}
+
+// discoverPrimary asks the primary nodes for their leader, the leader hints are followed,
+// it returns the primary leader and its MySQL replication info.
+func (r *Raft) discoverPrimary() (string, model.Repl, error) {
+	var errs []string
+	tried := make(map[string]bool)
+	nodes := append([]string{}, r.conf.StandbyOf...)
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		if tried[node] {
+			continue
+		}
+		tried[node] = true
+
+		rsp, err := r.callPrimary(node)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v:%v", node, err))
+			continue
+		}
+		if rsp.RetCode == model.OK {
+			return node, rsp.Repl, nil
+		}
+		errs = append(errs, fmt.Sprintf("%v:%v", node, rsp.RetCode))
+		if rsp.RetCode == model.ErrorNotLeader && rsp.Leader != noLeader {
+			nodes = append(nodes, rsp.Leader)
+		}
+	}
+	return noLeader, model.Repl{}, errors.Errorf("primary.leader.not.found[%v]", strings.Join(errs, ","))
+}
+
+func (r *Raft) callPrimary(node string) (*model.RaftPrimaryRPCResponse, error) {
+	client, err := xrpc.NewTransportClient(r.transport, r.getID(), node, r.conf.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	req := model.NewRaftPrimaryRPCRequest()
+	req.From = r.getID()
+	rsp := model.NewRaftPrimaryRPCResponse(model.OK)
+	if err := client.CallTimeout(r.conf.RequestTimeout, model.RPCRaftPrimary, req, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// standbyStart starts replicating from the primary leader, the primary leader is checked every election timeout,
+// so the standby follows the primary failovers.
+func (r *Leader) standbyStart() {
+	interval := r.getElectionTimeout()
+	r.standbyTick = common.NewNormalTicker(r.clock, interval)
+	go func(leader *Leader, tick *common.Ticker) {
+		leader.syncStandby()
+		for range tick.C {
+			leader.syncStandby()
+		}
+	}(r, r.standbyTick)
+	r.INFO("standby.sync.start[%vms]...", interval)
+}
+
+func (r *Leader) standbyStop() {
+	if r.standbyTick != nil {
+		r.standbyTick.Stop()
+		r.standbyTick = nil
+		r.setStandbyPrimary(noLeader, "", "")
+		r.INFO("standby.sync.stop...")
+	}
+}
+
+// syncStandby changes the MySQL master to the primary leader if it changes.
+func (r *Leader) syncStandby() {
+	r.standbySyncMutex.Lock()
+	defer r.standbySyncMutex.Unlock()
+	if r.getState() != LEADER || !r.isStandby() {
+		return
+	}
+
+	standby := r.getStandby()
+	leader, repl, err := r.discoverPrimary()
+	if err != nil {
+		r.ERROR("standby.discover.primary%v.error[%v]", standby.Primary, err)
+		r.setStandbyPrimary(standby.PrimaryLeader, standby.Master, err.Error())
+		return
+	}
+	master := fmt.Sprintf("%s:%d", repl.Master_Host, repl.Master_Port)
+	if leader == standby.PrimaryLeader && master == standby.Master {
+		r.setStandbyPrimary(leader, master, "")
+		return
+	}
+
+	r.WARNING("standby.primary.leader.changed.from[%v].to[%v].change.mysql.master.to[%v]", standby.PrimaryLeader, leader, master)
+	if err := r.mysql.ChangeMasterTo(&repl); err != nil {
+		r.ERROR("standby.change.master.to[%v].error[%v]", master, err)
+		r.setStandbyPrimary(standby.PrimaryLeader, standby.Master, err.Error())
+		return
+	}
+	r.setStandbyPrimary(leader, master, "")
+	r.emit(model.RaftEvent{Type: EventStandby, Peer: leader, Reason: master})
+}
+
+// isStandbyNode returns true if the caller of the primary is allowed to get the replication info,
+// it's one of the standby-nodes or the rpc calls are signed by the cluster secret.
+func (r *Raft) isStandbyNode(from string) bool {
+	if _, ok := r.transport.(*xrpc.AuthTransport); ok {
+		return true
+	}
+	for _, node := range r.conf.StandbyNodes {
+		if node == from {
+			return true
+		}
+	}
+	return false
+}
+
+// drPromoteNotLeader answers the dr-promote sent to the state machine loop of a non-leader.
+func (r *Raft) drPromoteNotLeader() chan *model.RaftDRPromoteRPCResponse {
+	c := make(chan *model.RaftDRPromoteRPCResponse, 1)
+	rsp := model.NewRaftDRPromoteRPCResponse(model.ErrorNotLeader)
+	rsp.Leader = r.getLeader()
+	c <- rsp
+	return c
+}
+
+// drPromoteAsync starts the dr-promote from the leader state machine loop,
+// it's asynchronous like prepareSettingsAsync since WAIT_UNTIL_SQL_THREAD_AFTER_GTIDS maybe long,
+// the loop exit waits it done.
+func (r *Leader) drPromoteAsync(req *model.RaftDRPromoteRPCRequest) chan *model.RaftDRPromoteRPCResponse {
+	c := make(chan *model.RaftDRPromoteRPCResponse, 1)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+		rsp.Leader = r.getID()
+		gtid, err := r.drPromote(req.From)
+		rsp.GTID = gtid
+		if err != nil {
+			r.ERROR("dr.promote.error[%+v]", err)
+			rsp.RetCode = err.Error()
+		}
+		c <- rsp
+	}()
+	return c
+}
+
+// drPromote detaches the standby from the primary and makes the MySQL writable,
+// the received primary transactions are applied before, it returns the last applied primary GTID.
+func (r *Leader) drPromote(from string) (string, error) {
+	r.standbySyncMutex.Lock()
+	defer r.standbySyncMutex.Unlock()
+	if !r.isStandby() {
+		return "", errors.New(model.ErrorNotStandby)
+	}
+	r.WARNING("dr.promote.by[%v].detach.from.the.primary%v...", from, r.conf.StandbyOf)
+
+	if err := r.runPromoteHooks(HookPrePromote); err != nil {
+		return "", errors.Wrap(err, "pre-promote.hooks.abort")
+	}
+
+	// stop receiving from the primary and apply what we received
+	if err := r.mysql.StopSlaveIOThread(); err != nil {
+		return "", err
+	}
+	gtid, err := r.mysql.GetGTID()
+	if err != nil {
+		return "", err
+	}
+	r.SetRaftMysqlStatus(model.RAFTMYSQL_WAITUNTILAFTERGTID)
+	err = r.mysql.WaitUntilAfterGTID(gtid.Retrieved_GTID_Set)
+	r.ResetRaftMysqlStatus()
+	if err != nil {
+		return "", err
+	}
+	if gtid, err = r.mysql.GetGTID(); err != nil {
+		return "", err
+	}
+
+	// we stepped down while applying, the new leader is still the standby
+	if r.getState() != LEADER {
+		return "", errors.New(model.ErrorNotLeader)
+	}
+
+	// the detach is persisted before the MySQL is writable, and carried to the members in the heartbeats
+	if err := r.detachStandby(r.clock.Now().UnixNano()/int64(time.Millisecond), from, gtid.Executed_GTID_Set); err != nil {
+		return "", err
+	}
+	if err := r.promoteMySQL(); err != nil {
+		return gtid.Executed_GTID_Set, err
+	}
+	r.emit(model.RaftEvent{Type: EventDRPromote, Peer: from, Reason: gtid.Executed_GTID_Set})
+	r.WARNING("dr.promote.done.last.applied.primary.gtid[%v]", gtid.Executed_GTID_Set)
+	return gtid.Executed_GTID_Set, nil
+}
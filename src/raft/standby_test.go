@@ -0,0 +1,200 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+	"xbase/xrpc"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the standby cluster replicates from the primary leader and the dr-promote detaches it.
+//
+// TEST PROCESSES:
+// 1. Start 3 primary rafts and 3 standby rafts, the standby leader replicates from the primary leader
+// 1.1 the primary refuses the caller not in standby-nodes
+// 2. the primary leader is down, the standby leader follows the new primary leader
+// 3. dr-promote on the primary or the standby follower is refused
+// 4. dr-promote on the standby leader detaches it, the standby followers follow the detach
+func TestRaftStandby(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	conf := config.DefaultRaftConfig()
+	conf.PurgeBinlogInterval = 1
+	conf.CandidateWaitFor2Nodes = 1000
+	conf.MetaDatadir = "/tmp/"
+	conf.StandbyOf = names
+	standbyNames, standbys, standbyCleanup := MockRaftsWithConfig(log, conf, port+3, 3, -1)
+	defer standbyCleanup()
+
+	waitFor := func(cond func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if cond() {
+				return true
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return false
+	}
+
+	// 1. Start 3 primary rafts and 3 standby rafts, the standby leader replicates from the primary leader
+	for _, raft := range rafts {
+		assert.Nil(t, raft.getStandby())
+		raft.conf.StandbyNodes = standbyNames
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	for _, raft := range standbys {
+		assert.True(t, raft.isStandby())
+		raft.Start()
+	}
+	standbyLeader := standbys[MockWaitLeaderEggs(standbys, 1)]
+	assert.True(t, waitFor(func() bool {
+		return standbyLeader.getStandby().PrimaryLeader == names[whoisleader]
+	}))
+	standby := standbyLeader.getStandby()
+	assert.Equal(t, "127.0.0.1:3306", standby.Master)
+	assert.Equal(t, "", standby.LastError)
+	assert.Equal(t, names, standby.Primary)
+	events := standbyLeader.GetHistory(0)
+	assert.Equal(t, EventStandby, events[len(events)-1].Type)
+	assert.Equal(t, names[whoisleader], events[len(events)-1].Peer)
+
+	// 1.1 the primary refuses the caller not in standby-nodes
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCRaftPrimary
+		req := model.NewRaftPrimaryRPCRequest()
+		req.From = "127.0.0.1:1"
+		rsp := model.NewRaftPrimaryRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorStandbyNotAllowed, rsp.RetCode)
+		assert.Equal(t, "", rsp.Repl.Repl_User)
+	}
+
+	// 2. the primary leader is down, the standby leader follows the new primary leader
+	rafts[whoisleader].Stop()
+	rest := []*Raft{rafts[(whoisleader+1)%3], rafts[(whoisleader+2)%3]}
+	newLeader := rest[MockWaitLeaderEggs(rest, 1)].getID()
+	assert.True(t, waitFor(func() bool {
+		return standbyLeader.getStandby().PrimaryLeader == newLeader
+	}))
+
+	// 3. dr-promote on the primary or the standby follower is refused
+	{
+		c, cleanup := MockGetClient(t, newLeader)
+		defer cleanup()
+
+		method := model.RPCRaftDRPromote
+		req := model.NewRaftDRPromoteRPCRequest()
+		rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotStandby, rsp.RetCode)
+	}
+	var follower string
+	for _, name := range standbyNames {
+		if name != standbyLeader.getID() {
+			follower = name
+		}
+	}
+	{
+		c, cleanup := MockGetClient(t, follower)
+		defer cleanup()
+
+		method := model.RPCRaftDRPromote
+		req := model.NewRaftDRPromoteRPCRequest()
+		rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotLeader, rsp.RetCode)
+		assert.Equal(t, standbyLeader.getID(), rsp.Leader)
+	}
+
+	// 4. dr-promote on the standby leader detaches it, the standby followers follow the detach
+	{
+		c, cleanup := MockGetClient(t, standbyLeader.getID())
+		defer cleanup()
+
+		method := model.RPCRaftDRPromote
+		req := model.NewRaftDRPromoteRPCRequest()
+		req.From = "operator"
+		rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Contains(t, rsp.GTID, "052077a5-b6f4-ee1b-61ec-d80a8b27d749:1-36")
+	}
+	assert.False(t, standbyLeader.isStandby())
+	standby = standbyLeader.getStandby()
+	assert.Equal(t, "operator", standby.DetachedBy)
+	events = standbyLeader.GetHistory(0)
+	assert.Equal(t, EventDRPromote, events[len(events)-1].Type)
+	assert.Equal(t, standby.DetachedGTID, events[len(events)-1].Reason)
+	for _, raft := range standbys {
+		raft := raft
+		assert.True(t, waitFor(func() bool { return !raft.isStandby() }))
+		assert.Equal(t, standby.DetachedAt, raft.getStandby().DetachedAt)
+	}
+	saved := &model.RaftStandby{}
+	ok, err := readVersionedJSON(filepath.Join(conf.MetaDatadir, standbyFile), standbyVersion, saved)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, standby.DetachedGTID, saved.DetachedGTID)
+
+	// the detached cluster refuses the second dr-promote
+	{
+		c, cleanup := MockGetClient(t, standbyLeader.getID())
+		defer cleanup()
+
+		method := model.RPCRaftDRPromote
+		req := model.NewRaftDRPromoteRPCRequest()
+		rsp := model.NewRaftDRPromoteRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotStandby, rsp.RetCode)
+	}
+}
+
+// TEST EFFECTS:
+// test the primary answers the standby-nodes, or any caller with the signed rpc calls.
+func TestRaftStandbyNode(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-standby")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	conf.StandbyNodes = []string{"127.0.0.1:0202"}
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	raft := NewRaft("127.0.0.1:0101", conf, 10000, log, mysql57, FOLLOWER)
+	assert.True(t, raft.isStandbyNode("127.0.0.1:0202"))
+	assert.False(t, raft.isStandbyNode("127.0.0.1:0303"))
+
+	raft.SetTransport(xrpc.NewAuthTransport(xrpc.DefaultTransport, "secret"))
+	assert.True(t, raft.isStandbyNode("127.0.0.1:0303"))
+}
@@ -11,7 +11,6 @@ package raft
 import (
 	"model"
 	"sync/atomic"
-	"time"
 )
 
 // IncLeaderPromotes counter.
@@ -54,6 +53,16 @@ func (s *Raft) IncCandidatePromotes() {
 	atomic.AddUint64(&s.stats.CandidatePromotes, 1)
 }
 
+// IncPreVoteFails counter.
+func (s *Raft) IncPreVoteFails() {
+	atomic.AddUint64(&s.stats.PreVoteFails, 1)
+}
+
+// IncLeaderLeaseExpires counter.
+func (s *Raft) IncLeaderLeaseExpires() {
+	atomic.AddUint64(&s.stats.LeaderLeaseExpires, 1)
+}
+
 // IncCandidateDegrades counter.
 func (s *Raft) IncCandidateDegrades() {
 	atomic.AddUint64(&s.stats.CandidateDegrades, 1)
@@ -81,7 +90,9 @@ func (s *Raft) getStats() *model.RaftStats {
 		LessHearbeatAcks:           atomic.LoadUint64(&s.stats.LessHearbeatAcks),
 		CandidatePromotes:          atomic.LoadUint64(&s.stats.CandidatePromotes),
 		CandidateDegrades:          atomic.LoadUint64(&s.stats.CandidateDegrades),
-		StateUptimes:               uint64(time.Since(s.stateBegin).Seconds()),
+		PreVoteFails:               atomic.LoadUint64(&s.stats.PreVoteFails),
+		LeaderLeaseExpires:         atomic.LoadUint64(&s.stats.LeaderLeaseExpires),
+		StateUptimes:               uint64(s.clock.Now().Sub(s.stateBegin).Seconds()),
 		RaftMysqlStatus:            s.stats.RaftMysqlStatus,
 	}
 }
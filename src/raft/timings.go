@@ -0,0 +1,162 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// timingsFile is the file for storing the raft timings changed at runtime
+	timingsFile = "raft.timings.json"
+
+	// timingsVersion is the version of the timings file format, bump it when the format changes.
+	timingsVersion = 1
+
+	// minElectionHeartbeatRatio is the min election-timeout/heartbeat-timeout,
+	// the followers must miss some heartbeats before the election.
+	minElectionHeartbeatRatio = 3
+)
+
+// recoverTimings initializes the timings from the config, and restores the runtime changed ones from the timings file,
+// so a restart never brings back the old timings.
+func (r *Raft) recoverTimings() {
+	r.timings = model.RaftTimings{
+		HeartbeatTimeout:    r.conf.HeartbeatTimeout,
+		ElectionTimeout:     r.conf.ElectionTimeout,
+		AdmitDefeatHtCnt:    r.conf.AdmitDefeatHtCnt,
+		PurgeBinlogInterval: r.conf.PurgeBinlogInterval,
+	}
+
+	timingsPath := filepath.Join(r.conf.MetaDatadir, timingsFile)
+	timings := &model.RaftTimings{}
+	ok, err := readVersionedJSON(timingsPath, timingsVersion, timings)
+	if err != nil {
+		r.PANIC("read.timings.file[%v].error[%+v]", timingsPath, err)
+	}
+	if !ok {
+		return
+	}
+	if err := checkTimings(timings); err != nil {
+		r.PANIC("timings.file[%v].timings[%+v].invalid[%v]", timingsPath, *timings, err)
+	}
+	r.timings = *timings
+	r.WARNING("recovery.timings.from[%v].timings[%+v]", timingsPath, r.timings)
+}
+
+// writeTimings persists the timings, the caller must hold the timingsMutex.
+func (r *Raft) writeTimings() error {
+	return writeVersionedJSON(filepath.Join(r.conf.MetaDatadir, timingsFile), timingsVersion, &r.timings)
+}
+
+// checkTimings returns error if the timings are inconsistent.
+func checkTimings(t *model.RaftTimings) error {
+	if t.HeartbeatTimeout <= 0 || t.ElectionTimeout <= 0 || t.AdmitDefeatHtCnt <= 0 || t.PurgeBinlogInterval <= 0 {
+		return errors.Errorf("timings[%+v].must.be.positive", *t)
+	}
+	if t.ElectionTimeout < t.HeartbeatTimeout*minElectionHeartbeatRatio {
+		return errors.Errorf("election-timeout[%v].must.be.at.least.%d.times.heartbeat-timeout[%v]", t.ElectionTimeout, minElectionHeartbeatRatio, t.HeartbeatTimeout)
+	}
+	return nil
+}
+
+// getTimings returns the timings in use.
+func (r *Raft) getTimings() model.RaftTimings {
+	r.timingsMutex.RLock()
+	defer r.timingsMutex.RUnlock()
+	return r.timings
+}
+
+// setTimings changes the timings on the leader, the zero fields are unchanged,
+// the new revision is carried to all the members in the heartbeats.
+func (r *Raft) setTimings(from string, change model.RaftTimings) (model.RaftTimings, error) {
+	r.timingsMutex.Lock()
+	timings := r.timings
+	if change.HeartbeatTimeout != 0 {
+		timings.HeartbeatTimeout = change.HeartbeatTimeout
+	}
+	if change.ElectionTimeout != 0 {
+		timings.ElectionTimeout = change.ElectionTimeout
+	}
+	if change.AdmitDefeatHtCnt != 0 {
+		timings.AdmitDefeatHtCnt = change.AdmitDefeatHtCnt
+	}
+	if change.PurgeBinlogInterval != 0 {
+		timings.PurgeBinlogInterval = change.PurgeBinlogInterval
+	}
+	if err := checkTimings(&timings); err != nil {
+		r.timingsMutex.Unlock()
+		return r.getTimings(), err
+	}
+	// the revision is the change time, it's always greater than the old one
+	timings.Revision = r.clock.Now().UnixNano() / int64(time.Millisecond)
+	if timings.Revision <= r.timings.Revision {
+		timings.Revision = r.timings.Revision + 1
+	}
+	err := r.applyTimings(timings)
+	r.timingsMutex.Unlock()
+	if err != nil {
+		return r.getTimings(), err
+	}
+
+	r.WARNING("timings.changed.by[%v].to[%+v]", from, timings)
+	r.emitTimingsChange(from, timings)
+	return timings, nil
+}
+
+// updateTimings follows the timings from the other member if its revision is higher,
+// so all the members converge to the latest timings.
+func (r *Raft) updateTimings(from string, timings *model.RaftTimings) {
+	if timings == nil {
+		return
+	}
+
+	r.timingsMutex.Lock()
+	if timings.Revision <= r.timings.Revision {
+		r.timingsMutex.Unlock()
+		return
+	}
+	if err := checkTimings(timings); err != nil {
+		r.timingsMutex.Unlock()
+		r.ERROR("timings[%+v].from[%v].invalid[%v]", *timings, from, err)
+		return
+	}
+	err := r.applyTimings(*timings)
+	r.timingsMutex.Unlock()
+	if err != nil {
+		r.ERROR("update.timings.from[%v].error[%+v]", from, err)
+		return
+	}
+
+	r.WARNING("timings.updated.from[%v].to[%+v]", from, *timings)
+	r.emitTimingsChange(from, *timings)
+}
+
+// applyTimings persists and uses the timings, the timers pick them up when they are reset.
+// the caller must hold the timingsMutex.
+func (r *Raft) applyTimings(timings model.RaftTimings) error {
+	old := r.timings
+	r.timings = timings
+	if err := r.writeTimings(); err != nil {
+		r.timings = old
+		return err
+	}
+	return nil
+}
+
+func (r *Raft) emitTimingsChange(from string, timings model.RaftTimings) {
+	reason := fmt.Sprintf("heartbeat-timeout=%d,election-timeout=%d,admit-defeat-hearbeat-count=%d,purge-binlog-interval=%d",
+		timings.HeartbeatTimeout, timings.ElectionTimeout, timings.AdmitDefeatHtCnt, timings.PurgeBinlogInterval)
+	r.emit(model.RaftEvent{Type: EventTimingsChange, Peer: from, Reason: reason})
+}
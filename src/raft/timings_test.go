@@ -0,0 +1,119 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the timings change at runtime and the replication to all the members.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts, wait the leader eggs
+// 2. set timings on the follower is refused
+// 3. set invalid timings on the leader is refused
+// 4. set timings on the leader, all the members follow the revision
+// 5. the timings survive the restart
+func TestRaftSetTimings(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	names, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. Start 3 rafts, wait the leader eggs
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	old := leader.getTimings()
+	heartbeat := time.Duration(old.HeartbeatTimeout) * time.Millisecond
+
+	// 2. set timings on the follower is refused
+	{
+		c, cleanup := MockGetClient(t, names[(whoisleader+1)%3])
+		defer cleanup()
+
+		method := model.RPCRaftSetTimings
+		req := model.NewRaftTimingsRPCRequest()
+		req.Timings.AdmitDefeatHtCnt = old.AdmitDefeatHtCnt + 1
+		rsp := model.NewRaftTimingsRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotLeader, rsp.RetCode)
+		assert.Equal(t, names[whoisleader], rsp.Leader)
+	}
+
+	// 3. set invalid timings on the leader is refused
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCRaftSetTimings
+		req := model.NewRaftTimingsRPCRequest()
+		req.Timings.ElectionTimeout = old.HeartbeatTimeout
+		rsp := model.NewRaftTimingsRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.NotEqual(t, model.OK, rsp.RetCode)
+		assert.Equal(t, old, leader.getTimings())
+	}
+
+	// 4. set timings on the leader, all the members follow the revision
+	var timings model.RaftTimings
+	{
+		c, cleanup := MockGetClient(t, names[whoisleader])
+		defer cleanup()
+
+		method := model.RPCRaftSetTimings
+		req := model.NewRaftTimingsRPCRequest()
+		req.From = "operator:8801"
+		req.Timings.ElectionTimeout = old.ElectionTimeout + old.HeartbeatTimeout
+		req.Timings.AdmitDefeatHtCnt = old.AdmitDefeatHtCnt + 1
+		rsp := model.NewRaftTimingsRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		timings = rsp.Timings
+		assert.True(t, timings.Revision > old.Revision)
+		assert.Equal(t, old.HeartbeatTimeout, timings.HeartbeatTimeout)
+		assert.Equal(t, old.ElectionTimeout+old.HeartbeatTimeout, timings.ElectionTimeout)
+		assert.Equal(t, old.AdmitDefeatHtCnt+1, timings.AdmitDefeatHtCnt)
+	}
+	time.Sleep(heartbeat * 3)
+	for _, raft := range rafts {
+		assert.Equal(t, timings, raft.getTimings())
+		assert.Equal(t, timings.ElectionTimeout, raft.getElectionTimeout())
+		events := raft.GetHistory(0)
+		found := false
+		for _, event := range events {
+			if event.Type == EventTimingsChange {
+				found = true
+			}
+		}
+		assert.True(t, found)
+	}
+	_, err := os.Stat(filepath.Join(leader.conf.MetaDatadir, timingsFile))
+	assert.Nil(t, err)
+
+	// 5. the timings survive the restart
+	{
+		restarted := NewRaft(names[whoisleader], leader.conf, 10000, log, leader.mysql, FOLLOWER)
+		assert.Equal(t, timings, restarted.getTimings())
+	}
+}
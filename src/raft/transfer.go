@@ -0,0 +1,224 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the interval(ms) to poll the transfer target
+	transferPollInterval = 100
+)
+
+// transferLeader
+// EFFECT
+// hands the leadership over to the peer 'to' without losing writes:
+// 1. set the MySQL read_only, no more writes on this leader
+// 2. wait until the executed GTID set of the target covers ours
+// 3. propose the target to be a candidate and step down to FOLLOWER
+// 4. wait for the target to win the election, if it fails roll back to this node
+//
+// RETURNS
+// 1. ErrorInvalidRequest: this node is not the LEADER, the target is not a member or a transfer is in progress
+// 2. ErrorMySQLDown: can't get the GTID of this node or the target
+// 3. ErrorCatchUpTimeout: the target can't catch up with us, the leadership is unchanged
+// 4. ErrorElectionTimeout: the target can't win the election, the leadership rolls back to this node
+// 5. OK: the target is the new leader
+func (r *Raft) transferLeader(to string, rsp *model.HATransferLeaderRPCResponse) {
+	begin := r.clock.Now()
+	defer func() {
+		rsp.Leader = r.getLeader()
+		rsp.TotalTime = r.sinceMs(begin)
+	}()
+
+	if !atomic.CompareAndSwapInt32(&r.transferring, 0, 1) {
+		r.ERROR("transfer.leader.to[%v].another.transfer.is.in.progress", to)
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+	defer atomic.StoreInt32(&r.transferring, 0)
+
+	if r.getState() != LEADER {
+		r.ERROR("transfer.leader.to[%v].but.i.am.not.leader[%v]", to, r.getState())
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+
+	r.mutex.RLock()
+	peer, ok := r.peers[to]
+	r.mutex.RUnlock()
+	if !ok {
+		r.ERROR("transfer.leader.to[%v].is.not.a.member.of.peers[%+v]", to, r.getPeers())
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+	if r.isWitnessPeer(to) {
+		r.ERROR("transfer.leader.to[%v].is.a.witness", to)
+		rsp.RetCode = model.ErrorInvalidRequest
+		return
+	}
+
+	// 1. stop the writes
+	r.WARNING("transfer.leader.to[%v].1.set.mysql.readonly", to)
+	if err := r.mysql.SetReadOnly(); err != nil {
+		r.ERROR("transfer.leader.to[%v].mysql.SetReadOnly.error[%v]", to, err)
+		rsp.RetCode = model.ErrorMySQLDown
+		return
+	}
+
+	// 2. wait the target to catch up
+	r.WARNING("transfer.leader.to[%v].2.wait.target.catch.up", to)
+	catchUpBegin := r.clock.Now()
+	rsp.RetCode = r.waitTransferCatchUp(peer)
+	rsp.CatchUpTime = r.sinceMs(catchUpBegin)
+	if rsp.RetCode != model.OK {
+		r.ERROR("transfer.leader.to[%v].wait.catch.up.error[%v].set.mysql.readwrite.back", to, rsp.RetCode)
+		if r.getState() == LEADER {
+			if err := r.mysql.SetReadWrite(); err != nil {
+				r.ERROR("transfer.leader.to[%v].mysql.SetReadWrite.error[%v]", to, err)
+			}
+		}
+		return
+	}
+	r.WARNING("transfer.leader.to[%v].target.caught.up.in[%vms]", to, rsp.CatchUpTime)
+
+	// 3. propose the target to be a candidate and step down
+	r.WARNING("transfer.leader.to[%v].3.propose.target.to.candidate", to)
+	electionBegin := r.clock.Now()
+	retCode, err := peer.sendTryToLeader()
+	if err != nil || retCode != model.OK {
+		r.ERROR("transfer.leader.to[%v].send.trytoleader.ret[%v].error[%v].set.mysql.readwrite.back", to, retCode, err)
+		if err := r.mysql.SetReadWrite(); err != nil {
+			r.ERROR("transfer.leader.to[%v].mysql.SetReadWrite.error[%v]", to, err)
+		}
+		rsp.RetCode = model.ErrorRPCCall
+		if err == nil {
+			rsp.RetCode = retCode
+		}
+		return
+	}
+	if r.getState() == LEADER {
+		r.WARNING("transfer.leader.to[%v].step.down.to.follower", to)
+		r.emitDegrade(FOLLOWER, degradeTransferLeader)
+		r.setState(FOLLOWER)
+		r.loopFired()
+	}
+
+	// 4. wait the target to win the election
+	r.WARNING("transfer.leader.to[%v].4.wait.target.to.be.leader", to)
+	won := r.waitTransferElection(peer)
+	rsp.ElectionTime = r.sinceMs(electionBegin)
+	if !won {
+		rsp.RetCode = model.ErrorElectionTimeout
+		if r.getLeader() == noLeader && r.getState() == FOLLOWER {
+			r.ERROR("transfer.leader.to[%v].target.election.timeout.roll.back.to.me", to)
+			r.setState(CANDIDATE)
+			r.loopFired()
+			r.IncCandidatePromotes()
+			r.waitTransferRollback()
+		} else {
+			r.ERROR("transfer.leader.to[%v].target.election.timeout.leader.is[%v]", to, r.getLeader())
+		}
+		return
+	}
+	r.WARNING("transfer.leader.to[%v].done.in[%vms]", to, r.sinceMs(begin))
+	rsp.RetCode = model.OK
+}
+
+// waitTransferCatchUp waits until the executed GTID set of the peer covers ours.
+func (r *Raft) waitTransferCatchUp(peer *Peer) string {
+	this, err := r.mysql.GetGTID()
+	if err != nil {
+		r.ERROR("transfer.leader.get.my.gtid.error[%v]", err)
+		return model.ErrorMySQLDown
+	}
+
+	timeout := r.clock.After(time.Duration(r.conf.TransferLeaderTimeout) * time.Millisecond)
+	for {
+		if r.getState() != LEADER {
+			r.ERROR("transfer.leader.wait.catch.up.but.i.am.not.leader[%v]", r.getState())
+			return model.ErrorInvalidRequest
+		}
+
+		gtid, err := peer.getMysqlGTID()
+		if err != nil {
+			r.ERROR("transfer.leader.get.peer[%v].gtid.error[%v]", peer.getID(), err)
+		} else {
+			missing, err := r.mysql.GetGTIDSubtract(this.Executed_GTID_Set, gtid.Executed_GTID_Set)
+			if err != nil {
+				r.ERROR("transfer.leader.get.gtid.subtract.error[%v]", err)
+				return model.ErrorMySQLDown
+			}
+			if missing == "" {
+				return model.OK
+			}
+			r.WARNING("transfer.leader.peer[%v].missing.gtid[%v]", peer.getID(), missing)
+		}
+
+		select {
+		case <-timeout:
+			return model.ErrorCatchUpTimeout
+		case <-r.clock.After(transferPollInterval * time.Millisecond):
+		}
+	}
+}
+
+// waitTransferElection waits until the peer becomes the LEADER.
+func (r *Raft) waitTransferElection(peer *Peer) bool {
+	timeout := r.clock.After(time.Duration(r.conf.TransferLeaderTimeout) * time.Millisecond)
+	for {
+		c := make(chan *model.RaftRPCResponse, 1)
+		peer.SendPing(c)
+		if rsp := <-c; rsp.RetCode == model.OK && rsp.Raft.State == LEADER.String() {
+			return true
+		}
+
+		select {
+		case <-timeout:
+			return false
+		case <-r.clock.After(transferPollInterval * time.Millisecond):
+		}
+	}
+}
+
+// waitTransferRollback waits until this node becomes the LEADER again.
+func (r *Raft) waitTransferRollback() {
+	timeout := r.clock.After(time.Duration(r.conf.TransferLeaderTimeout) * time.Millisecond)
+	for r.getState() != LEADER {
+		select {
+		case <-timeout:
+			r.ERROR("transfer.leader.roll.back.timeout.state[%v]", r.getState())
+			return
+		case <-r.clock.After(transferPollInterval * time.Millisecond):
+		}
+	}
+	r.WARNING("transfer.leader.roll.back.done")
+}
+
+// sinceMs returns the milliseconds elapsed since t on the raft clock.
+func (r *Raft) sinceMs(t time.Time) int64 {
+	return int64(r.clock.Now().Sub(t) / time.Millisecond)
+}
+
+// isTransferCampaign returns true if this candidate campaigns for a leader transfer.
+func (r *Raft) isTransferCampaign() bool {
+	return atomic.LoadInt32(&r.transferCampaign) == 1
+}
+
+// setTransferCampaign marks the campaign of this candidate as a leader transfer or not.
+func (r *Raft) setTransferCampaign(transfer bool) {
+	var v int32
+	if transfer {
+		v = 1
+	}
+	atomic.StoreInt32(&r.transferCampaign, v)
+}
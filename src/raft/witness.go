@@ -0,0 +1,283 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"mysql"
+	"sync"
+	"time"
+)
+
+// WITNESS is a voting member of the RAFT cluster without MySQL behind it.
+// It answers the heartbeats, prevotes and voterequests with the GTID carried in the requests,
+// so two data nodes plus one cheap witness form a real majority.
+//
+// WITNESS never becomes CANDIDATE, and it's not a MySQL replica of the LEADER.
+
+// Witness tuple.
+type Witness struct {
+	*Raft
+
+	// the greatest candidate GTID we saw since the last heartbeat
+	gtidMutex sync.Mutex
+	gtid      *model.GTID
+	gtidFrom  string
+	gtidAt    time.Time
+
+	// witness process heartbeat request handler
+	processHeartbeatRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+
+	// witness process voterequest request handler
+	processRequestVoteRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+
+	// witness process ping request handler
+	processPingRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+}
+
+// NewWitness creates new Witness.
+func NewWitness(r *Raft) *Witness {
+	W := &Witness{Raft: r}
+	W.initHandlers()
+	return W
+}
+
+// Loop used to start the loop of the state machine.
+//--------------------------------------
+// State Machine
+//--------------------------------------
+// in WITNESS state, we only vote and never do leader election
+//
+func (r *Witness) Loop() {
+	// update begin
+	r.updateStateBegin()
+	r.WARNING("witness.start.without.mysql")
+
+	for r.getState() == WITNESS {
+		select {
+		case <-r.fired:
+			r.WARNING("state.machine.loop.got.fired")
+		case e := <-r.c:
+			switch e.Type {
+			// 1) Heartbeat
+			case MsgRaftHeartbeat:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processHeartbeatRequestHandler(req)
+				e.response <- rsp
+
+			// 2) RequestVote
+			case MsgRaftRequestVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processRequestVoteRequestHandler(req)
+				e.response <- rsp
+
+			// 3) Ping
+			case MsgRaftPing:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPingRequestHandler(req)
+				e.response <- rsp
+
+			// 4) PreVote
+			case MsgRaftPreVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPreVoteRequest(req)
+				e.response <- rsp
+
+			case MsgRaftDRPromote:
+				e.response <- r.drPromoteNotLeader()
+
+			default:
+				r.ERROR("get.unknown.request[%v]", e.Type)
+			}
+		}
+	}
+}
+
+// processHeartbeatRequest
+// EFFECT
+// handles the heartbeat request from the leader
+// In WITNESS state, we only handle the view and epoch changed
+//
+// RETURN
+// 1. ErrorInvalidRequest: the request.From is not a member of this cluster
+// 2. ErrorInvalidViewID: request leader viewid is old, he is a stale leader
+// 3. OK: new leader eggs
+func (r *Witness) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
+
+	if !r.checkRequest(req) {
+		rsp.RetCode = model.ErrorInvalidRequest
+		return rsp
+	}
+
+	viewdiff := (int)(r.getViewID() - req.GetViewID())
+	epochdiff := (int)(r.getEpochID() - req.GetEpochID())
+	switch {
+	case viewdiff > 0:
+		r.ERROR("get.heartbeat.from[N:%v, V:%v, E:%v].stale.viewid.ret.ErrorInvalidViewID", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.Raft.Leader = r.getLeader()
+		rsp.RetCode = model.ErrorInvalidViewID
+
+	case viewdiff <= 0:
+		r.updateLeaderAlive()
+		r.resetCandidateGTID()
+		r.updateClusterFreeze(req.GetFrom(), req.ClusterFreeze)
+
+		if r.getLeader() != req.GetFrom() {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].new.leader", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.setLeader(req.GetFrom())
+		}
+
+		// view change
+		if viewdiff < 0 {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.view", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.updateView(req.GetViewID(), req.GetFrom())
+		}
+
+		// epoch change
+		if epochdiff != 0 {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetWitnesses(), req.GetNextPeers())
+		}
+	}
+	return rsp
+}
+
+// processRequestVoteRequest
+// EFFECT
+// handles the requestvote request from other CANDIDATEs
+//
+// RETURN
+// 1. ErrorInvalidRequest: the request.From is not a member of this cluster
+// 2. ErrorInvalidViewID: request viewid is old
+// 3. ErrorInvalidGTID: we saw other CANDIDATE with the greater GTID recently
+// 4. ErrorVoteNotGranted: we have voted for other CANDIDATE in this view
+// 5. ErrorInvalidEpochID: the CANDIDATE has the stale membership epoch
+// 6. OK: give a vote
+func (r *Witness) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
+
+	if !r.checkRequest(req) {
+		rsp.RetCode = model.ErrorInvalidRequest
+		return rsp
+	}
+
+	r.WARNING("get.voterequest.from[%+v].request[%v]", req.GetFrom(), req.GetGTID())
+	// 1. check viewid(req.viewid < thisnode.viewid)
+	if req.GetViewID() < r.getViewID() {
+		r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.viewid.ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+		rsp.RetCode = model.ErrorInvalidViewID
+		return rsp
+	}
+	if !r.checkRequestEpoch(req) {
+		r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.epochid[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.getEpochID())
+		rsp.RetCode = model.ErrorInvalidEpochID
+		return rsp
+	}
+
+	// 2. check GTID with the other candidates
+	if !r.checkCandidateGTID(req) {
+		rsp.RetCode = model.ErrorInvalidGTID
+		return rsp
+	}
+
+	// 3. check viewid(req.viewid > thisnode.viewid)
+	if req.GetViewID() > r.getViewID() {
+		r.updateView(req.GetViewID(), noLeader)
+	} else {
+		if (r.votedFor != noVote) && (r.votedFor != req.GetFrom()) {
+			r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].already.vote.for[%v].ret.reject", req.GetFrom(), req.GetViewID(), req.GetEpochID(), r.votedFor)
+			rsp.RetCode = model.ErrorVoteNotGranted
+			return rsp
+		}
+	}
+
+	// 4. voted for this candidate
+	r.setVotedFor(req.GetFrom())
+	r.updateLeaderAlive()
+	r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].vote.for.this.candidate", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+	return rsp
+}
+
+func (r *Witness) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+	rsp.Raft.Priority = r.getPriority()
+	rsp.Raft.Zone = r.getZone()
+	return rsp
+}
+
+// checkCandidateGTID returns false if we saw other candidate with the greater GTID in the election timeout,
+// that candidate is more likely to have the latest data.
+// the witness has no GTID itself, it only compares the GTIDs carried in the prevotes and voterequests.
+func (r *Witness) checkCandidateGTID(req *model.RaftRPCRequest) bool {
+	r.gtidMutex.Lock()
+	defer r.gtidMutex.Unlock()
+
+	expired := r.clock.Now().Sub(r.gtidAt) > time.Duration(r.getElectionTimeout())*time.Millisecond
+	if r.gtid != nil && !expired && r.gtidFrom != req.GetFrom() {
+		greater, reason := mysql.GTIDGreater(r.gtid, &req.GTID, r.conf.GTIDTieBreak)
+		r.WARNING("get.vote.from[N:%v, V:%v].gtid[executed:%v, retrieved:%v].compare.with.candidate[%v].gtid[executed:%v, retrieved:%v].%v.greater[%v]",
+			req.GetFrom(), req.GetViewID(), req.GTID.Executed_GTID_Set, req.GTID.Retrieved_GTID_Set,
+			r.gtidFrom, r.gtid.Executed_GTID_Set, r.gtid.Retrieved_GTID_Set, reason, greater)
+		if greater {
+			r.WARNING("get.vote.from[N:%v, V:%v].candidate[%v].has.greater.gtid.ret.reject", req.GetFrom(), req.GetViewID(), r.gtidFrom)
+			return false
+		}
+	}
+
+	gtid := req.GetGTID()
+	r.gtid = &gtid
+	r.gtidFrom = req.GetFrom()
+	r.gtidAt = r.clock.Now()
+	return true
+}
+
+// resetCandidateGTID forgets the candidate GTID once the leader eggs.
+func (r *Witness) resetCandidateGTID() {
+	r.gtidMutex.Lock()
+	defer r.gtidMutex.Unlock()
+	r.gtid = nil
+	r.gtidFrom = ""
+}
+
+// handlers
+func (r *Witness) initHandlers() {
+	r.setProcessHeartbeatRequestHandler(r.processHeartbeatRequest)
+	r.setProcessRequestVoteRequestHandler(r.processRequestVoteRequest)
+	r.setProcessPingRequestHandler(r.processPingRequest)
+}
+
+// for tests
+func (r *Witness) setProcessHeartbeatRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processHeartbeatRequestHandler = f
+}
+
+func (r *Witness) setProcessRequestVoteRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processRequestVoteRequestHandler = f
+}
+
+func (r *Witness) setProcessPingRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processPingRequestHandler = f
+}
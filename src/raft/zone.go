@@ -0,0 +1,181 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+
+	"github.com/pkg/errors"
+)
+
+// getPeerZone returns the zone label of the node, empty if unknown.
+func (r *Raft) getPeerZone(id string) string {
+	if id == r.getID() {
+		return r.getZone()
+	}
+
+	r.zoneMutex.RLock()
+	defer r.zoneMutex.RUnlock()
+	return r.meta.Zones[id]
+}
+
+// updatePeerZone records the zone label we heard from the peer, persists it if changed.
+func (r *Raft) updatePeerZone(id string, zone string) {
+	if zone == "" || id == r.getID() {
+		return
+	}
+
+	r.zoneMutex.Lock()
+	if r.meta.Zones[id] == zone {
+		r.zoneMutex.Unlock()
+		return
+	}
+	zones := make(map[string]string, len(r.meta.Zones)+1)
+	for k, v := range r.meta.Zones {
+		zones[k] = v
+	}
+	zones[id] = zone
+	r.meta.Zones = zones
+	r.zoneMutex.Unlock()
+
+	r.WARNING("peer[%v].zone.changed.to[%v]", id, zone)
+	r.writeMeta()
+}
+
+// getPeerZones returns the zone labels of the peers we heard.
+func (r *Raft) getPeerZones() map[string]string {
+	r.zoneMutex.RLock()
+	defer r.zoneMutex.RUnlock()
+	return r.meta.Zones
+}
+
+// getZones returns the zone labels of all the members include us.
+func (r *Raft) getZones() map[string]string {
+	zones := make(map[string]string)
+	for _, id := range r.getAllPeers() {
+		if zone := r.getPeerZone(id); zone != "" {
+			zones[id] = zone
+		}
+	}
+	if zone := r.getZone(); zone != "" {
+		zones[r.getID()] = zone
+	}
+	return zones
+}
+
+// getZoneDelay returns the delay(ms) before this node becomes CANDIDATE,
+// the node out of the old leader's zone waits a half of the election timeout,
+// so the candidate in the same zone with the old leader campaigns first.
+func (r *Raft) getZoneDelay() int {
+	leader := r.getLeader()
+	if leader == noLeader || r.getZone() == "" {
+		return 0
+	}
+
+	zone := r.getPeerZone(leader)
+	if zone == "" || zone == r.getZone() {
+		return 0
+	}
+	return r.getElectionTimeout() / 2
+}
+
+// getSemiSyncWaitCount returns the rpl_semi_sync_master_wait_for_slave_count for the members,
+// if semi-sync-cross-zone is on, all the followers in our zone(or unknown zone) acks are not enough,
+// at least one ack must come from the other zone.
+func (r *Raft) getSemiSyncWaitCount(members int) int {
+	count := (members - 1) / 2
+	if !r.conf.SemiSyncCrossZone {
+		return count
+	}
+
+	if r.getZone() == "" {
+		r.WARNING("check.semi-sync.cross.zone.but.my.zone.is.unknown")
+		return count
+	}
+
+	sameZone := 0
+	otherZone := 0
+	for _, id := range r.getPeers() {
+		if id == r.getID() || r.isWitnessPeer(id) {
+			continue
+		}
+		if zone := r.getPeerZone(id); zone != "" && zone != r.getZone() {
+			otherZone++
+		} else {
+			sameZone++
+		}
+	}
+	if otherZone == 0 {
+		r.WARNING("check.semi-sync.cross.zone.but.no.follower.in.other.zone")
+		return count
+	}
+	// MySQL counts the acks of any replicas, it can't require one from the other zone,
+	// but if we wait for one more ack than the followers in our zone, at least one must come from the other zone.
+	if sameZone+1 > count {
+		count = sameZone + 1
+	}
+	return count
+}
+
+// checkZonePlacement returns ErrorZonePlacement if the majority of the members would sit in one zone after the node added,
+// the zone of the node is asked by a ping, the members with unknown zone are not counted.
+func (r *Raft) checkZonePlacement(connStr string) string {
+	zones := r.getZones()
+	peer := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+	defer peer.freePeer()
+	c := make(chan *model.RaftRPCResponse, 1)
+	peer.SendPing(c)
+	if rsp := <-c; rsp.RetCode != model.OK {
+		r.WARNING("change.peer[%v].ping.error[%v].zone.is.unknown", connStr, rsp.RetCode)
+	} else if rsp.Raft.Zone != "" {
+		zones[connStr] = rsp.Raft.Zone
+	}
+
+	members := append(append([]string{}, r.getPeers()...), connStr)
+	if err := r.zonePlacementError(members, zones); err != nil {
+		r.ERROR("change.peer[%v].error[%v]", connStr, err)
+		return model.ErrorZonePlacement
+	}
+	return model.OK
+}
+
+// zonePlacementError returns error if the majority of the members sits in one zone,
+// it's only a warning if all the labeled members are in the same zone.
+func (r *Raft) zonePlacementError(members []string, zones map[string]string) error {
+	uniq := make(map[string]bool)
+	counts := make(map[string]int)
+	unknown := []string{}
+	for _, member := range members {
+		if uniq[member] {
+			continue
+		}
+		uniq[member] = true
+		zone, ok := zones[member]
+		if !ok {
+			unknown = append(unknown, member)
+			continue
+		}
+		counts[zone]++
+	}
+	if len(unknown) > 0 {
+		r.WARNING("cluster.nodes[%v].zone.is.unknown", unknown)
+	}
+
+	quorums := len(uniq)/2 + 1
+	for zone, count := range counts {
+		if count >= quorums && len(uniq) > 1 {
+			if len(counts) == 1 {
+				r.WARNING("cluster.all.the.labeled.members.are.in.zone[%v]", zone)
+				return nil
+			}
+			return errors.Errorf("cluster.zone[%v].has.%v.of.%v.members.the.majority.sits.in.one.zone", zone, count, len(uniq))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the zone labels are exchanged and the semi-sync wait count requires a cross zone ack.
+//
+// TEST PROCESSES:
+// 1. set rafts[0], rafts[1] in zone az1 and rafts[2] in zone az2
+// 2. Start 3 rafts state as FOLLOWER
+// 3. wait the leader eggs and check the zones
+// 4. check the semi-sync wait count with semi-sync-cross-zone
+func TestRaftZone(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts[0], rafts[1] in zone az1 and rafts[2] in zone az2
+	MockSetZone(rafts[0], "az1")
+	MockSetZone(rafts[1], "az1")
+	MockSetZone(rafts[2], "az2")
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. wait the leader eggs and check the zones
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()))
+	want := map[string]string{
+		ids[0]: "az1",
+		ids[1]: "az1",
+		ids[2]: "az2",
+	}
+	for _, raft := range rafts {
+		assert.Equal(t, want, raft.GetZones())
+	}
+
+	// 4. check the semi-sync wait count with semi-sync-cross-zone
+	{
+		assert.Equal(t, 1, leader.getSemiSyncWaitCount(3))
+
+		leader.conf.SemiSyncCrossZone = true
+		switch leader.getZone() {
+		case "az1":
+			// the follower in az1 ack is not enough
+			assert.Equal(t, 2, leader.getSemiSyncWaitCount(3))
+		case "az2":
+			assert.Equal(t, 1, leader.getSemiSyncWaitCount(3))
+		}
+	}
+}
+
+// TEST EFFECTS:
+// test the node out of the old leader's zone delays the candidacy.
+//
+// TEST PROCESSES:
+// 1. Start 3 rafts state as IDLE, rafts[2] in zone az2
+// 2. check the zone delay of rafts[2] with the different leaders
+func TestRaftZoneDelay(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRafts(log, port, 3, 0)
+	defer cleanup()
+
+	// 1. Start 3 rafts state as IDLE, rafts[2] in zone az2
+	raft := rafts[2]
+	MockSetZone(raft, "az2")
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 2. check the zone delay of rafts[2] with the different leaders
+	raft.updatePeerZone(ids[0], "az1")
+	raft.updatePeerZone(ids[1], "az2")
+
+	raft.setLeader(ids[0])
+	assert.Equal(t, raft.getElectionTimeout()/2, raft.getZoneDelay())
+	raft.setLeader(ids[1])
+	assert.Equal(t, 0, raft.getZoneDelay())
+	raft.setLeader(noLeader)
+	assert.Equal(t, 0, raft.getZoneDelay())
+}
+
+// TEST EFFECTS:
+// test the semi-sync wait count with semi-sync-cross-zone makes every quorum of the acks cross the zones.
+//
+// TEST PROCESSES:
+// 1. the leader and 2 followers in az1, 2 followers in az2
+// 2. check every set of the acks with the wait count has a follower in az2
+// 3. check the acks one less than the wait count can be all in az1
+func TestRaftSemiSyncCrossZoneWaitCount(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-zone")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// 1. the leader and 2 followers in az1, 2 followers in az2
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = dir
+	conf.Zone = "az1"
+	conf.SemiSyncCrossZone = true
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	raft := NewRaft("127.0.0.1:0100", conf, 10000, log, mysql57, FOLLOWER)
+	zones := map[string]string{
+		"127.0.0.1:0101": "az1",
+		"127.0.0.1:0102": "az1",
+		"127.0.0.1:0201": "az2",
+		"127.0.0.1:0202": "az2",
+	}
+	followers := []string{}
+	for id, zone := range zones {
+		raft.AddPeer(id)
+		raft.updatePeerZone(id, zone)
+		followers = append(followers, id)
+	}
+	count := raft.getSemiSyncWaitCount(5)
+	assert.Equal(t, 3, count)
+
+	// 2. check every set of the acks with the wait count has a follower in az2
+	for mask := 0; mask < 1<<uint(len(followers)); mask++ {
+		acks := 0
+		crossZone := false
+		for i, id := range followers {
+			if mask&(1<<uint(i)) != 0 {
+				acks++
+				crossZone = crossZone || zones[id] == "az2"
+			}
+		}
+		if acks == count {
+			assert.True(t, crossZone, "acks.mask[%b]", mask)
+		}
+	}
+
+	// 3. check the acks one less than the wait count can be all in az1
+	{
+		sameZone := 0
+		for _, zone := range zones {
+			if zone == "az1" {
+				sameZone++
+			}
+		}
+		assert.Equal(t, count-1, sameZone)
+	}
+}
+
+// TEST EFFECTS:
+// test the leader refuses the membership change if the majority of the members would sit in one zone.
+//
+// TEST PROCESSES:
+// 1. set rafts[0], rafts[1] in zone az1 and rafts[2] in zone az2, start them
+// 2. check the zone placement of the members
+// 3. leader removes one FOLLOWER
+// 4. leader adds it back, the majority sits in az1, it's refused
+// 5. leader adds it back with force
+func TestRaftZonePlacement(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts[0], rafts[1] in zone az1 and rafts[2] in zone az2, start them
+	MockSetZone(rafts[0], "az1")
+	MockSetZone(rafts[1], "az1")
+	MockSetZone(rafts[2], "az2")
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	whoisleader := MockWaitLeaderEggs(rafts, 1)
+	leader := rafts[whoisleader]
+	removed := rafts[(whoisleader+1)%3]
+
+	// 2. check the zone placement of the members
+	{
+		members := []string{"n1", "n2", "n3"}
+
+		// no labels.
+		assert.Nil(t, leader.zonePlacementError(members, map[string]string{}))
+
+		// all in one zone.
+		zones := map[string]string{"n1": "az1", "n2": "az1", "n3": "az1"}
+		assert.Nil(t, leader.zonePlacementError(members, zones))
+
+		// majority in az1.
+		zones = map[string]string{"n1": "az1", "n2": "az1", "n3": "az2"}
+		err := leader.zonePlacementError(members, zones)
+		want := "cluster.zone[az1].has.2.of.3.members.the.majority.sits.in.one.zone"
+		got := err.Error()
+		assert.Equal(t, want, got)
+
+		// spread.
+		zones = map[string]string{"n1": "az1", "n2": "az2", "n3": "az3"}
+		assert.Nil(t, leader.zonePlacementError(append(members, "n1"), zones))
+	}
+
+	// 3. leader removes one FOLLOWER
+	{
+		_, retCode := leader.ProposeRemovePeer(removed.getID())
+		assert.Equal(t, model.OK, retCode)
+		assert.Equal(t, 2, len(leader.GetPeers()))
+	}
+
+	// 4. leader adds it back, the majority sits in az1, it's refused
+	{
+		adopted, retCode := leader.ProposeAddPeer(removed.getID(), false)
+		assert.Equal(t, model.ErrorZonePlacement, retCode)
+		assert.Nil(t, adopted)
+		assert.Equal(t, 2, len(leader.GetPeers()))
+	}
+
+	// 5. leader adds it back with force
+	{
+		_, retCode := leader.ProposeAddPeer(removed.getID(), true)
+		assert.Equal(t, model.OK, retCode)
+		assert.Equal(t, 3, len(leader.GetPeers()))
+	}
+}
@@ -11,9 +11,11 @@ package server
 import (
 	"config"
 	"fmt"
+	"io/ioutil"
 	"mysql"
 	"mysqld"
 	"os"
+	"path/filepath"
 	"raft"
 	"testing"
 	"xbase/common"
@@ -28,21 +30,48 @@ var (
 )
 
 func MockServers(log *xlog.Log, port int, count int) ([]*Server, func()) {
+	return mockServers(log, port, count, nil, "")
+}
+
+// MockServersWithSecret mock.
+// the servers sign and verify the rpc calls with the rpc-secret.
+func MockServersWithSecret(log *xlog.Log, port int, count int, secret string) ([]*Server, func()) {
+	return mockServers(log, port, count, nil, secret)
+}
+
+// MockServersWithNetwork mock.
+// the servers talk over the in-memory network, the tests inject the faults by the network or MockScenario.
+func MockServersWithNetwork(log *xlog.Log, port int, count int) ([]*Server, *xrpc.Network, func()) {
+	network := xrpc.NewNetwork()
+	servers, cleanup := mockServers(log, port, count, network, "")
+	return servers, network, cleanup
+}
+
+func mockServers(log *xlog.Log, port int, count int, network *xrpc.Network, secret string) ([]*Server, func()) {
 	names := []string{}
 	servers := []*Server{}
 	ip, _ := common.GetLocalIP()
 
-	os.Remove("peers.json")
+	// each server keeps its raft files in its own dir under the temp dir
+	dir, err := ioutil.TempDir("", "xenon-server")
+	if err != nil {
+		log.Panic("mock.server.create.temp.dir.error[%v]", err)
+	}
 	for i := 0; i < count; i++ {
 		name := fmt.Sprintf("%s:%d", ip, port+i)
 		names = append(names, name)
 
 		conf := config.DefaultConfig()
 		conf.Server.Endpoint = name
+		conf.Server.RPCSecret = secret
 		conf.Raft.HeartbeatTimeout = shortHeartbeatTimeoutForTest
 		conf.Raft.ElectionTimeout = shortHeartbeatTimeoutForTest * 3
+		conf.Raft.MetaDatadir = filepath.Join(dir, fmt.Sprintf("%d", port+i))
 
 		server := NewServer(conf, log, raft.FOLLOWER)
+		if network != nil {
+			server.SetTransport(network)
+		}
 
 		// mock mysqld
 		_, mysqld, _ := mysqld.MockMysqld(log, port)
@@ -66,12 +95,22 @@ func MockServers(log *xlog.Log, port int, count int) ([]*Server, func()) {
 	}
 
 	return servers, func() {
-		os.Remove("peers.json")
 		for i, s := range servers {
 			log.Info("mock.server[%v].shutdown", names[i])
 			s.Shutdown()
 		}
+		// the rafts write the history when they stop
+		os.RemoveAll(dir)
+	}
+}
+
+// MockScenario creates the network scenario of the servers from MockServersWithNetwork.
+func MockScenario(network *xrpc.Network, servers []*Server) *raft.MockScenario {
+	rafts := []*raft.Raft{}
+	for _, server := range servers {
+		rafts = append(rafts, server.raft)
 	}
+	return raft.MockNetworkScenario(network, rafts)
 }
 
 // wait the leader eggs when leadernums >0
@@ -10,6 +10,7 @@ package server
 
 import (
 	"model"
+	"raft"
 )
 
 type NodeRPC struct {
@@ -23,64 +24,86 @@ func (s *Server) GetNodeRPC() *NodeRPC {
 func (n *NodeRPC) AddNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	log := n.server.log
 	rsp.RetCode = model.OK
-	nodes := req.GetNodes()
 
 	log.Warning("server.rpc.node.add:%+v", req)
-	for _, node := range nodes {
-		if err := n.server.raft.AddPeer(node); err != nil {
-			rsp.RetCode = err.Error()
-			log.Error("rpc.add.peer[%v].error[%v]", node, err)
-			return nil
-		}
+	propose := func(node string) ([]string, string) {
+		return n.server.raft.ProposeAddPeer(node, req.Force)
 	}
+	n.changeNodes("add.peer", req.GetNodes(), propose, n.server.raft.AddPeer, rsp)
 	return nil
 }
 
 func (n *NodeRPC) AddIdleNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	log := n.server.log
 	rsp.RetCode = model.OK
-	nodes := req.GetNodes()
 
 	log.Warning("server.rpc.node.add:%+v", req)
-	for _, node := range nodes {
-		if err := n.server.raft.AddIdlePeer(node); err != nil {
-			rsp.RetCode = err.Error()
-			log.Error("rpc.add.idle.peer[%v].error[%v]", node, err)
-			return nil
-		}
+	n.changeNodes("add.idle.peer", req.GetNodes(), n.server.raft.ProposeAddIdlePeer, n.server.raft.AddIdlePeer, rsp)
+	return nil
+}
+
+func (n *NodeRPC) AddWitnessNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
+	log := n.server.log
+	rsp.RetCode = model.OK
+
+	log.Warning("server.rpc.node.add.witness:%+v", req)
+	propose := func(node string) ([]string, string) {
+		return n.server.raft.ProposeAddWitnessPeer(node, req.Force)
 	}
+	n.changeNodes("add.witness.peer", req.GetNodes(), propose, n.server.raft.AddWitnessPeer, rsp)
 	return nil
 }
 
 func (n *NodeRPC) RemoveNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	log := n.server.log
 	rsp.RetCode = model.OK
-	nodes := req.GetNodes()
 
 	log.Warning("server.rpc.node.remove:%+v", req)
+	n.changeNodes("remove.peer", req.GetNodes(), n.server.raft.ProposeRemovePeer, n.server.raft.RemovePeer, rsp)
+	return nil
+}
+
+// changeNodes changes the nodes one at a time, the leader commits each change after the majority acks.
+// The other members return ErrorNotLeader with the leader, only a node without leader
+// changes its local peers, it's the bootstrap of the cluster.
+func (n *NodeRPC) changeNodes(op string, nodes []string, propose func(string) ([]string, string), local func(string) error, rsp *model.NodeRPCResponse) {
+	log := n.server.log
+	defer func() {
+		rsp.EpochID = n.server.raft.GetEpochID()
+	}()
+
 	for _, node := range nodes {
-		if err := n.server.raft.RemovePeer(node); err != nil {
+		if n.server.raft.GetState() == raft.LEADER {
+			adopted, retCode := propose(node)
+			rsp.Adopted = adopted
+			if retCode != model.OK {
+				rsp.RetCode = retCode
+				log.Error("rpc.%v[%v].error[%v].adopted.by[%v]", op, node, retCode, adopted)
+				return
+			}
+			continue
+		}
+
+		if leader := n.server.raft.GetLeader(); leader != "" {
+			rsp.RetCode = model.ErrorNotLeader
+			rsp.Leader = leader
+			log.Error("rpc.%v[%v].error[%v].leader.is[%v]", op, node, rsp.RetCode, leader)
+			return
+		}
+		if err := local(node); err != nil {
 			rsp.RetCode = err.Error()
-			log.Error("rpc.remove.peer[%v].error[%v]", node, err)
-			return nil
+			log.Error("rpc.%v[%v].error[%v]", op, node, err)
+			return
 		}
 	}
-	return nil
 }
 
 func (n *NodeRPC) RemoveIdleNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	log := n.server.log
 	rsp.RetCode = model.OK
-	nodes := req.GetNodes()
 
 	log.Warning("server.rpc.node.remove:%+v", req)
-	for _, node := range nodes {
-		if err := n.server.raft.RemoveIdlePeer(node); err != nil {
-			rsp.RetCode = err.Error()
-			log.Error("rpc.remove.idle.peer[%v].error[%v]", node, err)
-			return nil
-		}
-	}
+	n.changeNodes("remove.idle.peer", req.GetNodes(), n.server.raft.ProposeRemoveIdlePeer, n.server.raft.RemoveIdlePeer, rsp)
 	return nil
 }
 
@@ -90,7 +113,12 @@ func (n *NodeRPC) GetNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse
 	rsp.ViewID = n.server.raft.GetVewiID()
 	rsp.EpochID = n.server.raft.GetEpochID()
 	rsp.State = n.server.raft.GetState().String()
+	rsp.Maintenance = n.server.raft.GetMaintenance()
+	rsp.Freeze = n.server.raft.GetFreeze()
+	rsp.ClusterFreeze = n.server.raft.GetClusterFreeze()
+	rsp.ClusterID = n.server.raft.GetClusterID()
 	nodes := n.server.raft.GetAllPeers()
 	rsp.Nodes = append(rsp.Nodes, nodes...)
+	rsp.Zones = n.server.raft.GetZones()
 	return nil
 }
@@ -11,6 +11,7 @@ package server
 import (
 	"fmt"
 	"model"
+	"raft"
 	"testing"
 	"xbase/common"
 	"xbase/xlog"
@@ -119,3 +120,44 @@ func TestServerRPCAddRemoveNodes(t *testing.T) {
 		}
 	}
 }
+
+// TEST EFFECTS:
+// test the follower refuses to change the nodes and returns the leader
+//
+// TEST PROCESSES:
+// 1. Start 3 servers and wait the leader eggs
+// 2. send add/remove nodes to a follower
+// 3. check the response is ErrorNotLeader with the leader
+func TestServerRPCChangeNodesNotLeader(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServers(log, port, 3)
+	defer cleanup()
+	MockWaitLeaderEggs(servers, 1)
+
+	var leader, follower string
+	for _, server := range servers {
+		if server.raft.GetState() == raft.LEADER {
+			leader = server.Address()
+		} else {
+			follower = server.Address()
+		}
+	}
+	ip, err := common.GetLocalIP()
+	assert.Nil(t, err)
+
+	for _, method := range []string{model.RPCNodesAdd, model.RPCNodesRemove} {
+		req := model.NewNodeRPCRequest()
+		req.Nodes = []string{fmt.Sprintf("%s:%d", ip, port+3)}
+		rsp := model.NewNodeRPCResponse(model.OK)
+		c, cleanup := MockGetClient(t, follower)
+		err := c.Call(method, req, rsp)
+		cleanup()
+		assert.Nil(t, err)
+		assert.Equal(t, model.ErrorNotLeader, rsp.RetCode)
+		assert.Equal(t, leader, rsp.Leader)
+	}
+	for _, server := range servers {
+		assert.Equal(t, 3, len(server.raft.GetPeers()))
+	}
+}
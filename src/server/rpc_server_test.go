@@ -80,7 +80,7 @@ func TestServerRPCStatus(t *testing.T) {
 			MysqlPort:             3306,
 			MysqlReplUser:         "repl",
 			MysqlPingTimeout:      1000,
-			RaftDataDir:           ".",
+			RaftDataDir:           servers[0].conf.Raft.MetaDatadir,
 			RaftHeartbeatTimeout:  100,
 			RaftElectionTimeout:   300,
 			RaftRPCRequestTimeout: 1000,
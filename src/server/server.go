@@ -12,6 +12,7 @@ import (
 	"config"
 	"mysql"
 	"mysqld"
+	"net"
 	"os"
 	"os/signal"
 	"raft"
@@ -20,6 +21,8 @@ import (
 	"time"
 	"xbase/xlog"
 	"xbase/xrpc"
+
+	"github.com/pkg/errors"
 )
 
 type RPCS struct {
@@ -40,6 +43,7 @@ type Server struct {
 	raft   *raft.Raft
 	conf   *config.Config
 	rpc    *xrpc.Service
+	auth   *xrpc.AuthTransport
 	rpcs   RPCS
 	begin  time.Time
 }
@@ -53,18 +57,84 @@ func NewServer(conf *config.Config, log *xlog.Log, initState raft.State) *Server
 	s.mysqld = mysqld.NewMysqld(conf.Backup, log)
 	s.mysql = mysql.NewMysql(conf.Mysql, conf.Raft.ElectionTimeout, log)
 	s.raft = raft.NewRaft(conf.Server.Endpoint, conf.Raft, conf.Mysql.SemiSyncTimeoutForTwoNodes, log, s.mysql, initState)
-	rpc, err := xrpc.NewService(xrpc.Log(log),
-		xrpc.ConnectionStr(conf.Server.Endpoint))
+	s.SetTransport(xrpc.DefaultTransport)
+	return s
+}
+
+func (s *Server) newRPC(transport xrpc.Transport) *xrpc.Service {
+	rpc, err := xrpc.NewService(xrpc.Log(s.log),
+		xrpc.ConnectionStr(s.conf.Server.Endpoint),
+		xrpc.UseTransport(transport))
 	if err != nil {
-		log.Panic("server.rpc.NewService.error[%v]", err)
+		s.log.Panic("server.rpc.NewService.error[%v]", err)
 	}
-	s.rpc = rpc
-	return s
+	return rpc
+}
+
+// SetTransport used to set the transport of the rpc service and the raft peers, it must be called before Init.
+// with the rpc tls, the connections are encrypted by the mutual TLS,
+// with the rpc-secret, the calls of the peers are signed and the calls to the service are verified.
+func (s *Server) SetTransport(transport xrpc.Transport) {
+	if s.conf.RPC.TLSCert != "" {
+		tlsTransport, err := xrpc.NewTLSTransport(transport, s.tlsOptions())
+		if err != nil {
+			s.log.Panic("server.rpc.NewTLSTransport.error[%+v]", err)
+		}
+		transport = tlsTransport
+	}
+	s.auth = nil
+	if s.conf.Server.RPCSecret != "" {
+		s.auth = xrpc.NewAuthTransport(transport, s.conf.Server.RPCSecret)
+		transport = s.auth
+	}
+	s.rpc = s.newRPC(transport)
+	s.raft.SetTransport(transport)
+}
+
+// tlsOptions returns the rpc tls options of the config,
+// the client certificates are verified if tls-verify-endpoint is on or tls-allowed-clients is set.
+func (s *Server) tlsOptions() xrpc.TLSOptions {
+	opts := xrpc.TLSOptions{
+		CA:             s.conf.RPC.TLSCA,
+		Cert:           s.conf.RPC.TLSCert,
+		Key:            s.conf.RPC.TLSKey,
+		VerifyEndpoint: s.conf.RPC.TLSVerifyEndpoint,
+	}
+	if s.conf.RPC.TLSVerifyEndpoint || len(s.conf.RPC.TLSAllowedClients) > 0 {
+		opts.VerifyClient = s.verifyRPCClient
+	}
+	return opts
+}
+
+// verifyRPCClient accepts the client certificate with the host of a member, this node,
+// a standby-of or standby-nodes node or a tls-allowed-clients host.
+func (s *Server) verifyRPCClient(hosts []string) error {
+	allowed := make(map[string]bool)
+	endpoints := append([]string{s.conf.Server.Endpoint}, s.raft.GetAllPeers()...)
+	endpoints = append(endpoints, s.conf.Raft.StandbyOf...)
+	endpoints = append(endpoints, s.conf.Raft.StandbyNodes...)
+	endpoints = append(endpoints, s.conf.RPC.TLSAllowedClients...)
+	for _, endpoint := range endpoints {
+		host, _, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			host = endpoint
+		}
+		allowed[host] = true
+	}
+	for _, host := range hosts {
+		if allowed[host] {
+			return nil
+		}
+	}
+	return errors.Errorf("server.rpc.tls.client%v.is.not.allowed", hosts)
 }
 
 func (s *Server) Init() {
-	s.setupMysqld()
-	s.setupMysql()
+	// the witness runs no mysql
+	if !s.raft.IsWitness() {
+		s.setupMysqld()
+		s.setupMysql()
+	}
 	s.setupRPC()
 }
 
@@ -174,11 +244,14 @@ func (s *Server) Start() {
 		}
 	}()
 
-	if !s.conf.Mysql.MonitorDisabled {
-		s.mysqld.MonitorStart()
+	if s.raft.IsWitness() {
+		log.Warning("server.start.as.witness.without.mysql")
+	} else {
+		if !s.conf.Mysql.MonitorDisabled {
+			s.mysqld.MonitorStart()
+		}
+		s.mysql.PingStart()
 	}
-
-	s.mysql.PingStart()
 	if err := s.raft.Start(); err != nil {
 		log.Panic("server.raft.start.error[%+v]", err)
 	}
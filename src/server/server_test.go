@@ -9,9 +9,11 @@
 package server
 
 import (
+	"model"
 	"testing"
 	"xbase/common"
 	"xbase/xlog"
+	"xbase/xrpc"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -113,3 +115,132 @@ func TestServer(t *testing.T) {
 	mysqlPasswd := server.MySQLPasswd()
 	assert.Equal(t, "", mysqlPasswd)
 }
+
+// TEST EFFECTS:
+// test the servers over the in-memory network elect a new leader when the leader is partitioned
+//
+// TEST PROCESSES:
+// 1. start 3 servers on the in-memory network, wait the leader eggs
+// 2. partition the leader from the others, the others elect a new leader
+func TestServerNetworkPartition(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.WARNING))
+	port := common.RandomPort(8000, 9000)
+	servers, network, cleanup := MockServersWithNetwork(log, port, 3)
+	defer cleanup()
+
+	// 1. start 3 servers on the in-memory network, wait the leader eggs
+	scenario := MockScenario(network, servers)
+	old := scenario.WaitLeaders(1).Leaders()
+	assert.Equal(t, 1, len(old))
+
+	// 2. partition the leader from the others, the others elect a new leader
+	others := []int{}
+	for i := range servers {
+		if i != old[0] {
+			others = append(others, i)
+		}
+	}
+	leaders := scenario.Partition([]int{old[0]}, others).Wait(func() bool {
+		leaders := scenario.Leaders()
+		return len(leaders) == 1 && leaders[0] != old[0]
+	}).Leaders()
+	assert.Equal(t, 1, len(leaders))
+	assert.NotEqual(t, old[0], leaders[0])
+}
+
+// TEST EFFECTS:
+// test the servers with the rpc-secret elect the leader and reject the unsigned calls
+//
+// TEST PROCESSES:
+// 1. start 3 servers with the rpc-secret, wait the leader eggs
+// 2. the unsigned call and the call signed with a wrong secret are rejected and counted
+// 3. the call signed with the rpc-secret works
+func TestServerRPCSecret(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServersWithSecret(log, port, 3, "secret")
+	defer cleanup()
+
+	// 1. start 3 servers with the rpc-secret, wait the leader eggs
+	MockWaitLeaderEggs(servers, 1)
+	name := servers[0].Address()
+
+	// 2. the unsigned call and the call signed with a wrong secret are rejected and counted
+	{
+		c, cleanup := MockGetClient(t, name)
+		defer cleanup()
+
+		err := c.CallTimeout(1000, model.RRCServerPing, model.NewServerRPCRequest(), model.NewServerRPCResponse(model.OK))
+		assert.NotNil(t, err)
+	}
+	{
+		c, err := xrpc.NewTransportClient(xrpc.NewAuthTransport(xrpc.DefaultTransport, "guess"), "", name, 100)
+		assert.Nil(t, err)
+		defer c.Close()
+
+		err = c.CallTimeout(1000, model.RRCServerPing, model.NewServerRPCRequest(), model.NewServerRPCResponse(model.OK))
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, uint64(2), servers[0].getStats().AuthRejected)
+
+	// 3. the call signed with the rpc-secret works
+	{
+		c, err := xrpc.NewTransportClient(xrpc.NewAuthTransport(xrpc.DefaultTransport, "secret"), "", name, 100)
+		assert.Nil(t, err)
+		defer c.Close()
+
+		rsp := model.NewServerRPCResponse(model.OK)
+		err = c.CallTimeout(1000, model.RPCServerStatus, model.NewServerRPCRequest(), rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, uint64(2), rsp.Stats.AuthRejected)
+	}
+}
+
+// TEST EFFECTS:
+// test the rpc tls client is allowed by the members, this node, the standby-of and standby-nodes nodes and tls-allowed-clients
+func TestServerVerifyRPCClient(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServers(log, port, 2)
+	defer cleanup()
+	s := servers[0]
+	s.conf.Raft.StandbyOf = []string{"10.0.0.1:8801"}
+	s.conf.Raft.StandbyNodes = []string{"10.1.0.1:8801"}
+	s.conf.RPC.TLSAllowedClients = []string{"xenoncli.local"}
+
+	ip, err := common.GetLocalIP()
+	assert.Nil(t, err)
+	assert.Nil(t, s.verifyRPCClient([]string{"node9", ip}))
+	assert.Nil(t, s.verifyRPCClient([]string{"10.0.0.1"}))
+	assert.Nil(t, s.verifyRPCClient([]string{"10.1.0.1"}))
+	assert.Nil(t, s.verifyRPCClient([]string{"xenoncli.local"}))
+	assert.NotNil(t, s.verifyRPCClient([]string{"10.0.0.2", "evil.local"}))
+}
+
+// TEST EFFECTS:
+// test the rpc tls client is verified if tls-verify-endpoint is on or tls-allowed-clients is set
+func TestServerTLSOptions(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServers(log, port, 1)
+	defer cleanup()
+	s := servers[0]
+
+	// neither.
+	assert.Nil(t, s.tlsOptions().VerifyClient)
+
+	// tls-verify-endpoint.
+	s.conf.RPC.TLSVerifyEndpoint = true
+	opts := s.tlsOptions()
+	assert.True(t, opts.VerifyEndpoint)
+	assert.NotNil(t, opts.VerifyClient)
+	assert.NotNil(t, opts.VerifyClient([]string{"evil.local"}))
+
+	// tls-allowed-clients.
+	s.conf.RPC.TLSVerifyEndpoint = false
+	s.conf.RPC.TLSAllowedClients = []string{"xenoncli.local"}
+	opts = s.tlsOptions()
+	assert.NotNil(t, opts.VerifyClient)
+	assert.Nil(t, opts.VerifyClient([]string{"xenoncli.local"}))
+}
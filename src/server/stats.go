@@ -14,7 +14,11 @@ import (
 )
 
 func (s *Server) getStats() *model.ServerStats {
-	return &model.ServerStats{
+	stats := &model.ServerStats{
 		Uptimes: uint64(time.Since(s.begin).Seconds()),
 	}
+	if s.auth != nil {
+		stats.AuthRejected = s.auth.Rejected()
+	}
+	return stats
 }
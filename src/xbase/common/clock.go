@@ -0,0 +1,189 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package common
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the time and the timers.
+// RealClock is the wall clock, FakeClock only moves when the test advances it.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) *Timer
+	NewTicker(d time.Duration) *Ticker
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// Timer fires once on C, like the time.Timer.
+type Timer struct {
+	C    <-chan time.Time
+	stop func() bool
+}
+
+// Stop prevents the timer from firing, returns false if the timer has already fired or been stopped.
+func (t *Timer) Stop() bool {
+	return t.stop()
+}
+
+// Ticker fires on C every period, like the time.Ticker.
+type Ticker struct {
+	C    <-chan time.Time
+	stop func()
+}
+
+// Stop turns off the ticker, the C is not closed.
+func (t *Ticker) Stop() {
+	t.stop()
+}
+
+type realClock struct{}
+
+// RealClock is the wall clock.
+var RealClock Clock = realClock{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) *Timer {
+	t := time.NewTimer(d)
+	return &Timer{C: t.C, stop: t.Stop}
+}
+
+func (realClock) NewTicker(d time.Duration) *Ticker {
+	t := time.NewTicker(d)
+	return &Ticker{C: t.C, stop: t.Stop}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// fakeTimer is a pending timer or ticker of the FakeClock.
+type fakeTimer struct {
+	at     time.Time
+	period time.Duration // 0 for the timer
+	c      chan time.Time
+}
+
+// FakeClock is a Clock for tests, the time stands still until Advance.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates the FakeClock starts at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the fake time.
+func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// NewTimer creates a timer fires when the clock is advanced by d.
+func (f *FakeClock) NewTimer(d time.Duration) *Timer {
+	t := f.add(d, 0)
+	return &Timer{C: t.c, stop: func() bool { return f.remove(t) }}
+}
+
+// NewTicker creates a ticker fires every time the clock is advanced by d.
+func (f *FakeClock) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive.interval.for.NewTicker")
+	}
+	t := f.add(d, d)
+	return &Ticker{C: t.c, stop: func() { f.remove(t) }}
+}
+
+// After returns the channel of a new timer.
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C
+}
+
+// Sleep blocks until the clock is advanced by d.
+func (f *FakeClock) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// Advance moves the clock forward by d and fires the due timers in time order.
+// like the time.Ticker, a ticker drops the ticks if the receiver is slow.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	end := f.now.Add(d)
+	for len(f.timers) > 0 && !f.timers[0].at.After(end) {
+		t := f.timers[0]
+		f.now = t.at
+		select {
+		case t.c <- f.now:
+		default:
+		}
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+		} else {
+			f.timers = f.timers[1:]
+		}
+		f.sortTimers()
+	}
+	f.now = end
+}
+
+// Timers returns the number of the pending timers and tickers.
+func (f *FakeClock) Timers() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.timers)
+}
+
+func (f *FakeClock) add(d time.Duration, period time.Duration) *fakeTimer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t := &fakeTimer{at: f.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		t.c <- f.now
+		return t
+	}
+	f.timers = append(f.timers, t)
+	f.sortTimers()
+	return t
+}
+
+func (f *FakeClock) remove(t *fakeTimer) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeClock) sortTimers() {
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].at.Before(f.timers[j].at)
+	})
+}
@@ -0,0 +1,96 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	t1 := NewNormalTimer(clock, 100)
+	t2 := NewNormalTimer(clock, 200)
+	assert.Equal(t, 2, clock.Timers())
+
+	clock.Advance(99 * time.Millisecond)
+	assert.False(t, fired(t1.C))
+	clock.Advance(time.Millisecond)
+	assert.True(t, fired(t1.C))
+	assert.False(t, fired(t2.C))
+	assert.Equal(t, start.Add(100*time.Millisecond), clock.Now())
+
+	// stop
+	assert.False(t, t1.Stop())
+	assert.True(t, t2.Stop())
+	clock.Advance(time.Second)
+	assert.False(t, fired(t2.C))
+	assert.Equal(t, 0, clock.Timers())
+
+	// release
+	t3 := NewNormalTimer(clock, 10)
+	clock.Advance(10 * time.Millisecond)
+	TimerRelease(t3)
+	assert.False(t, fired(t3.C))
+
+	// non-positive duration fires at once
+	assert.True(t, fired(clock.After(0)))
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	ticker := NewNormalTicker(clock, 100)
+	for i := 0; i < 3; i++ {
+		clock.Advance(100 * time.Millisecond)
+		assert.True(t, fired(ticker.C))
+	}
+
+	// the ticks are dropped if the receiver is slow
+	clock.Advance(time.Second)
+	assert.True(t, fired(ticker.C))
+	assert.False(t, fired(ticker.C))
+
+	ticker.Stop()
+	clock.Advance(time.Second)
+	assert.False(t, fired(ticker.C))
+	assert.Equal(t, 0, clock.Timers())
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clock := NewFakeClock(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	done := make(chan bool)
+	go func() {
+		clock.Sleep(time.Second)
+		done <- true
+	}()
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sleep.not.wakeup")
+	}
+}
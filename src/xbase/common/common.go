@@ -14,6 +14,15 @@ import (
 )
 
 func RandomTimeout(min int) *time.Timer {
+	return time.NewTimer(randomDuration(min))
+}
+
+// NewRandomTimer creates the timer of the clock, it fires in [min, max) ms, the max is decided by the min.
+func NewRandomTimer(clock Clock, min int) *Timer {
+	return clock.NewTimer(randomDuration(min))
+}
+
+func randomDuration(min int) time.Duration {
 	var max int
 	if min <= 5 {
 		max = min * 2
@@ -27,7 +36,7 @@ func RandomTimeout(min int) *time.Timer {
 	if delta > 0 {
 		d += rand.Intn(int(delta))
 	}
-	return time.NewTimer(time.Duration(d) * time.Millisecond)
+	return time.Duration(d) * time.Millisecond
 }
 
 func RandomPort(min int, max int) int {
@@ -43,6 +52,11 @@ func NormalTimeout(d int) *time.Timer {
 	return time.NewTimer(time.Duration(d) * time.Millisecond)
 }
 
+// NewNormalTimer creates the timer of the clock, it fires in d ms.
+func NewNormalTimer(clock Clock, d int) *Timer {
+	return clock.NewTimer(time.Duration(d) * time.Millisecond)
+}
+
 func NormalTimerRelaese(t *time.Timer) {
 	if t == nil {
 		return
@@ -59,3 +73,22 @@ func NormalTimerRelaese(t *time.Timer) {
 func NormalTicker(d int) *time.Ticker {
 	return time.NewTicker(time.Duration(d) * time.Millisecond)
 }
+
+// NewNormalTicker creates the ticker of the clock, it fires every d ms.
+func NewNormalTicker(clock Clock, d int) *Ticker {
+	return clock.NewTicker(time.Duration(d) * time.Millisecond)
+}
+
+// TimerRelease stops the timer of the clock and drains the fired value.
+func TimerRelease(t *Timer) {
+	if t == nil {
+		return
+	}
+
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
@@ -14,4 +14,5 @@ type Command interface {
 	Kill() error
 	RunCommand(string, []string) (string, error)
 	RunCommandWithTimeout(int, string, []string) (string, error)
+	RunCommandWithEnv(int, []string, string, []string) (string, error)
 }
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync/atomic"
@@ -31,6 +32,11 @@ func RunCommand(cmds string, args ...string) (string, error) {
 }
 
 func runCommandWithTimeout(log *xlog.Log, timeout int, cmds string, args ...string) (out string, err error) {
+	return runCommandWithEnv(log, timeout, nil, cmds, args...)
+}
+
+// runCommandWithEnv runs the command with the environment variables appended to the current process's.
+func runCommandWithEnv(log *xlog.Log, timeout int, env []string, cmds string, args ...string) (out string, err error) {
 	const tmpl = `Stdout: %v, Stderr: %v, Error: %v`
 
 	cmdStr := cmds + " " + strings.Join(args, " ")
@@ -41,6 +47,9 @@ func runCommandWithTimeout(log *xlog.Log, timeout int, cmds string, args ...stri
 	cmd := exec.Command(cmds, args...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
 	if err = cmd.Start(); err != nil {
 		err = fmt.Errorf(tmpl, stdout.String(), stderr.String(), err)
@@ -157,3 +166,7 @@ func (c *LinuxCommand) RunCommand(cmds string, args []string) (string, error) {
 func (c *LinuxCommand) RunCommandWithTimeout(timeout int, cmds string, args []string) (string, error) {
 	return runCommandWithTimeout(c.log, timeout, cmds, args...)
 }
+
+func (c *LinuxCommand) RunCommandWithEnv(timeout int, env []string, cmds string, args []string) (string, error) {
+	return runCommandWithEnv(c.log, timeout, env, cmds, args...)
+}
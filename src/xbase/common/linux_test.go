@@ -88,6 +88,11 @@ func TestCommand(t *testing.T) {
 	_, err = cmd.RunCommandWithTimeout(100, cmds, args)
 	assert.Nil(t, err)
 
+	args = []string{"-c", "echo -n $XENON_TEST_ENV"}
+	out, err := cmd.RunCommandWithEnv(100, []string{"XENON_TEST_ENV=xenon"}, cmds, args)
+	assert.Nil(t, err)
+	assert.Equal(t, "xenon", out)
+
 	args = []string{"-c", "sleep 1000"}
 	_, err = cmd.RunCommandWithTimeout(1, cmds, args)
 	assert.NotNil(t, err)
@@ -54,6 +54,10 @@ func (c *MockCommand) RunCommandWithTimeout(to int, cmds string, args []string)
 	return "", nil
 }
 
+func (c *MockCommand) RunCommandWithEnv(to int, env []string, cmds string, args []string) (string, error) {
+	return "", nil
+}
+
 // mock command
 type MockACommand struct {
 }
@@ -86,6 +90,10 @@ func (c *MockACommand) RunCommandWithTimeout(to int, cmds string, args []string)
 	return "", nil
 }
 
+func (c *MockACommand) RunCommandWithEnv(to int, env []string, cmds string, args []string) (string, error) {
+	return "", nil
+}
+
 // mock command
 type MockBCommand struct {
 }
@@ -118,6 +126,10 @@ func (c *MockBCommand) RunCommandWithTimeout(to int, cmds string, args []string)
 	return "", nil
 }
 
+func (c *MockBCommand) RunCommandWithEnv(to int, env []string, cmds string, args []string) (string, error) {
+	return "", nil
+}
+
 // get local  ip for test only
 func GetLocalIP() (string, error) {
 	ifaces, err := net.Interfaces()
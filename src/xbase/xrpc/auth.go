@@ -0,0 +1,266 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+	"xbase/xlog"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxAuthSkew is the max difference between the request timestamp and the service clock,
+	// the nonces are remembered as long, so a captured request can't be replayed.
+	maxAuthSkew = 30 * time.Second
+)
+
+// AuthTransport signs every request of the clients with the cluster shared secret,
+// the service on it verifies the signature before dispatching the call.
+// The request carries the timestamp, the random nonce and the HMAC-SHA256 of the method,
+// the timestamp, the nonce and the args, the call with a bad signature, an old timestamp
+// or a seen nonce is answered with an error and counted, the unsigned client is disconnected.
+type AuthTransport struct {
+	Transport
+	secret   []byte
+	mutex    sync.Mutex
+	nonces   map[string]time.Time // the seen nonces and when they can be forgotten
+	purgeAt  time.Time
+	rejected uint64
+}
+
+// NewAuthTransport creates the transport signing and verifying the requests with secret over the transport.
+func NewAuthTransport(transport Transport, secret string) *AuthTransport {
+	return &AuthTransport{
+		Transport: transport,
+		secret:    []byte(secret),
+		nonces:    make(map[string]time.Time),
+	}
+}
+
+// Rejected returns the number of the calls rejected by the services on the transport.
+func (a *AuthTransport) Rejected() uint64 {
+	return atomic.LoadUint64(&a.rejected)
+}
+
+// signedRequest follows the rpc.Request header on the wire, the args are gob encoded in Body.
+type signedRequest struct {
+	Timestamp int64 // unix milliseconds
+	Nonce     string
+	MAC       []byte
+	Body      []byte
+}
+
+func (a *AuthTransport) mac(method string, signed *signedRequest) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	fmt.Fprintf(h, "%s\n%d\n%s\n", method, signed.Timestamp, signed.Nonce)
+	h.Write(signed.Body)
+	return h.Sum(nil)
+}
+
+// sign encodes the args and signs them with the method.
+func (a *AuthTransport) sign(method string, body interface{}) (*signedRequest, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	signed := &signedRequest{
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Nonce:     hex.EncodeToString(nonce),
+		Body:      buf.Bytes(),
+	}
+	signed.MAC = a.mac(method, signed)
+	return signed, nil
+}
+
+// verify returns error if the signed request is forged, out of the time window or replayed.
+func (a *AuthTransport) verify(method string, signed *signedRequest) error {
+	now := time.Now()
+	stamp := time.Unix(0, signed.Timestamp*int64(time.Millisecond))
+	if stamp.Before(now.Add(-maxAuthSkew)) || stamp.After(now.Add(maxAuthSkew)) {
+		return errors.Errorf("xrpc.auth.timestamp[%v].out.of.window[%v]", signed.Timestamp, maxAuthSkew)
+	}
+	if !hmac.Equal(signed.MAC, a.mac(method, signed)) {
+		return errors.New("xrpc.auth.signature.mismatch")
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if now.After(a.purgeAt) {
+		for nonce, expire := range a.nonces {
+			if now.After(expire) {
+				delete(a.nonces, nonce)
+			}
+		}
+		a.purgeAt = now.Add(maxAuthSkew)
+	}
+	if _, ok := a.nonces[signed.Nonce]; ok {
+		return errors.Errorf("xrpc.auth.nonce[%v].replayed", signed.Nonce)
+	}
+	a.nonces[signed.Nonce] = stamp.Add(maxAuthSkew)
+	return nil
+}
+
+func (a *AuthTransport) reject() {
+	atomic.AddUint64(&a.rejected, 1)
+}
+
+// authClientCodec is the gob client codec signing the requests.
+type authClientCodec struct {
+	auth   *AuthTransport
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func (c *authClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	signed, err := c.auth.sign(r.ServiceMethod, body)
+	if err != nil {
+		return err
+	}
+	if err := c.enc.Encode(r); err != nil {
+		c.Close()
+		return errors.WithStack(err)
+	}
+	if err := c.enc.Encode(signed); err != nil {
+		c.Close()
+		return errors.WithStack(err)
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *authClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *authClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *authClientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+// authServerCodec is the gob server codec verifying the requests.
+// the call failed the verification gets the error as the response, the args are never decoded.
+type authServerCodec struct {
+	auth    *AuthTransport
+	log     *xlog.Log
+	conn    net.Conn
+	dec     *gob.Decoder
+	enc     *gob.Encoder
+	encBuf  *bufio.Writer
+	signed  signedRequest
+	authErr error
+	closed  bool
+}
+
+func (c *authServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	c.signed = signedRequest{}
+	if err := c.dec.Decode(&c.signed); err != nil {
+		// the unsigned client, the stream can't be followed any more
+		c.auth.reject()
+		c.log.Warning("xrpc.auth.reject.unsigned.call[%v].from[%v].error[%v]", r.ServiceMethod, c.conn.RemoteAddr(), err)
+		return err
+	}
+	if c.authErr = c.auth.verify(r.ServiceMethod, &c.signed); c.authErr != nil {
+		c.auth.reject()
+		c.log.Warning("xrpc.auth.reject.call[%v].from[%v].error[%v]", r.ServiceMethod, c.conn.RemoteAddr(), c.authErr)
+	}
+	return nil
+}
+
+func (c *authServerCodec) ReadRequestBody(body interface{}) error {
+	if c.authErr != nil {
+		return c.authErr
+	}
+	if body == nil {
+		return nil
+	}
+	return gob.NewDecoder(bytes.NewReader(c.signed.Body)).Decode(body)
+}
+
+func (c *authServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *authServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+// newRPCClient creates the rpc client on the conn, the requests are signed if the transport authenticates.
+func newRPCClient(transport Transport, conn net.Conn) *rpc.Client {
+	auth, ok := transport.(*AuthTransport)
+	if !ok {
+		return rpc.NewClient(conn)
+	}
+	encBuf := bufio.NewWriter(conn)
+	return rpc.NewClientWithCodec(&authClientCodec{
+		auth:   auth,
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+	})
+}
+
+// serveRPCConn serves the conn, the requests are verified if the transport authenticates.
+func serveRPCConn(server *rpc.Server, transport Transport, log *xlog.Log, conn net.Conn) {
+	auth, ok := transport.(*AuthTransport)
+	if !ok {
+		server.ServeConn(conn)
+		return
+	}
+	encBuf := bufio.NewWriter(conn)
+	server.ServeCodec(&authServerCodec{
+		auth:   auth,
+		log:    log,
+		conn:   conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+	})
+}
@@ -0,0 +1,98 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthCall(t *testing.T) {
+	network := NewNetwork()
+	auth := NewAuthTransport(network, "secret")
+	server := startNetworkServerForTest(t, auth, "node2")
+	defer server.stop()
+
+	// signed
+	{
+		client, err := NewTransportClient(NewAuthTransport(network, "secret"), "node1", "node2", 100)
+		assert.Nil(t, err)
+		defer client.Close()
+
+		rsp := Response{}
+		err = client.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, rsp.Value)
+
+		session := NewSession(NewAuthTransport(network, "secret"), "node1", "node2", 100)
+		defer session.Close()
+		err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, rsp.Value)
+		assert.Equal(t, uint64(0), auth.Rejected())
+	}
+
+	// wrong secret, the call is answered with the error and not dispatched
+	{
+		client, err := NewTransportClient(NewAuthTransport(network, "guess"), "node1", "node2", 100)
+		assert.Nil(t, err)
+		defer client.Close()
+
+		err = client.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &Response{})
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "xrpc.auth.signature.mismatch"))
+		assert.Equal(t, 3, server.count)
+		assert.Equal(t, uint64(1), auth.Rejected())
+	}
+
+	// unsigned
+	{
+		client, err := NewTransportClient(network, "node1", "node2", 100)
+		assert.Nil(t, err)
+		defer client.Close()
+
+		err = client.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &Response{})
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, server.count)
+		assert.Equal(t, uint64(2), auth.Rejected())
+	}
+}
+
+func TestAuthVerify(t *testing.T) {
+	auth := NewAuthTransport(NewNetwork(), "secret")
+	signed, err := auth.sign("TestServer.Ping", Request{Value: 1})
+	assert.Nil(t, err)
+
+	// the method is signed
+	err = auth.verify("TestServer.PingTimeout", signed)
+	assert.Equal(t, "xrpc.auth.signature.mismatch", err.Error())
+
+	// replay
+	assert.Nil(t, auth.verify("TestServer.Ping", signed))
+	err = auth.verify("TestServer.Ping", signed)
+	assert.True(t, strings.HasSuffix(err.Error(), "replayed"))
+
+	// the args are signed
+	other, err := auth.sign("TestServer.Ping", Request{Value: 1})
+	assert.Nil(t, err)
+	other.Body = signed.Body[:len(signed.Body)-1]
+	err = auth.verify("TestServer.Ping", other)
+	assert.Equal(t, "xrpc.auth.signature.mismatch", err.Error())
+
+	// out of the time window
+	stale, err := auth.sign("TestServer.Ping", Request{Value: 1})
+	assert.Nil(t, err)
+	stale.Timestamp -= int64(2 * maxAuthSkew / time.Millisecond)
+	stale.MAC = auth.mac("TestServer.Ping", stale)
+	err = auth.verify("TestServer.Ping", stale)
+	assert.True(t, strings.Contains(err.Error(), "out.of.window"))
+}
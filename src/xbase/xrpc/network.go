@@ -0,0 +1,206 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// link is one direction between two addresses.
+type link struct {
+	from string
+	to   string
+}
+
+// rule is the fault of a link.
+type rule struct {
+	cut   bool
+	delay time.Duration
+	drop  int // percent of the lost messages
+}
+
+// Network is an in-memory Transport with the fault injection.
+// The rules are one-way, the requests of a client go by the link client->service
+// and the responses go back by the link service->client.
+// Dialing over a cut link fails at once, but a lost request or response blocks the call
+// until it times out, just like a partitioned TCP connection, so the callers see
+// the CallTimeout error.
+type Network struct {
+	mutex     sync.Mutex
+	listeners map[string]*memListener
+	rules     map[link]*rule
+	rand      *rand.Rand
+}
+
+// NewNetwork creates the in-memory Network, all links are healthy.
+// the random source of Drop is fixed, so the same rules give the same drops.
+func NewNetwork() *Network {
+	return &Network{
+		listeners: make(map[string]*memListener),
+		rules:     make(map[link]*rule),
+		rand:      rand.New(rand.NewSource(1)),
+	}
+}
+
+// Listen announces the service on addr.
+func (n *Network) Listen(addr string) (net.Listener, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if _, ok := n.listeners[addr]; ok {
+		return nil, errors.Errorf("listen.mem[%v].address.already.in.use", addr)
+	}
+	l := &memListener{
+		network: n,
+		addr:    memAddr(addr),
+		conns:   make(chan net.Conn),
+		closed:  make(chan struct{}),
+	}
+	n.listeners[addr] = l
+	return l, nil
+}
+
+// Dial connects the client from to the service on addr.
+// if the link is cut, it fails at once like the host is unreachable.
+func (n *Network) Dial(from string, addr string, timeout time.Duration) (net.Conn, error) {
+	n.mutex.Lock()
+	l := n.listeners[addr]
+	r := n.getRule(from, addr)
+	n.mutex.Unlock()
+
+	if r != nil && r.cut {
+		return nil, errors.Errorf("dial.mem[%v->%v].host.unreachable", from, addr)
+	}
+	if l == nil {
+		return nil, errors.Errorf("dial.mem[%v->%v].connection.refused", from, addr)
+	}
+
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+	case <-time.After(timeout):
+	}
+	client.Close()
+	server.Close()
+	return nil, errors.Errorf("dial.mem[%v->%v].connection.refused", from, addr)
+}
+
+// Deliver applies the rule of the link from->to to a message, it returns the delay and false if the message is lost.
+func (n *Network) Deliver(from string, to string) (time.Duration, bool) {
+	n.mutex.Lock()
+	r := n.getRule(from, to)
+	lost := false
+	var delay time.Duration
+	if r != nil {
+		lost = r.cut || (r.drop > 0 && n.rand.Intn(100) < r.drop)
+		delay = r.delay
+	}
+	n.mutex.Unlock()
+	return delay, !lost
+}
+
+// Cut loses all the messages from->to, the other direction is untouched.
+func (n *Network) Cut(from string, to string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.setRule(from, to).cut = true
+}
+
+// Delay delays every message from->to by d.
+func (n *Network) Delay(from string, to string, d time.Duration) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.setRule(from, to).delay = d
+}
+
+// Drop loses percent of the messages from->to.
+func (n *Network) Drop(from string, to string, percent int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.setRule(from, to).drop = percent
+}
+
+// Heal removes all the faults of the link from->to.
+func (n *Network) Heal(from string, to string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.rules, link{from: from, to: to})
+}
+
+// HealAll removes all the faults.
+func (n *Network) HealAll() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.rules = make(map[link]*rule)
+}
+
+// getRule returns the rule of the link, nil if the link is healthy, the caller must hold the mutex.
+func (n *Network) getRule(from string, to string) *rule {
+	return n.rules[link{from: from, to: to}]
+}
+
+// setRule returns the rule of the link to update, the caller must hold the mutex.
+func (n *Network) setRule(from string, to string) *rule {
+	l := link{from: from, to: to}
+	r, ok := n.rules[l]
+	if !ok {
+		r = &rule{}
+		n.rules[l] = r
+	}
+	return r
+}
+
+type memAddr string
+
+func (a memAddr) Network() string {
+	return "mem"
+}
+
+func (a memAddr) String() string {
+	return string(a)
+}
+
+// memListener is the in-memory net.Listener of a service.
+type memListener struct {
+	network *Network
+	addr    memAddr
+	conns   chan net.Conn
+	once    sync.Once
+	closed  chan struct{}
+}
+
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.Errorf("accept.mem[%v].use.of.closed.listener", l.addr)
+	}
+}
+
+func (l *memListener) Close() error {
+	l.once.Do(func() {
+		l.network.mutex.Lock()
+		delete(l.network.listeners, string(l.addr))
+		l.network.mutex.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr {
+	return l.addr
+}
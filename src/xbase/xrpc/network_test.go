@@ -0,0 +1,124 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"testing"
+	"time"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startNetworkServerForTest(t *testing.T, network Transport, conn string) *TestServer {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	xrpc, err := NewService(ConnectionStr(conn), Log(log), UseTransport(network))
+	assert.Nil(t, err)
+
+	server := &TestServer{conn: conn, count: 1, rpc: xrpc}
+	err = xrpc.RegisterService(server)
+	assert.Nil(t, err)
+	err = xrpc.Start()
+	assert.Nil(t, err)
+	return server
+}
+
+func TestNetworkCall(t *testing.T) {
+	network := NewNetwork()
+	server := startNetworkServerForTest(t, network, "node2")
+	defer server.stop()
+
+	client, err := NewTransportClient(network, "node1", "node2", 100)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	req := Request{Value: 1}
+	rsp := Response{}
+	err = client.CallTimeout(100, "TestServer.Ping", req, &rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rsp.Value)
+
+	// no listener
+	_, err = NewTransportClient(network, "node1", "node3", 100)
+	assert.NotNil(t, err)
+}
+
+func network_call_ForTest(network *Network, timeout int) error {
+	var rsp Response
+	client, err := NewTransportClient(network, "node1", "node2", 100)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	req := Request{Value: 1}
+	return client.CallTimeout(timeout, "TestServer.Ping", req, &rsp)
+}
+
+func TestNetworkCut(t *testing.T) {
+	network := NewNetwork()
+	server := startNetworkServerForTest(t, network, "node2")
+	defer server.stop()
+
+	client, err := NewTransportClient(network, "node1", "node2", 100)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// dial over the cut link fails
+	network.Cut("node1", "node2")
+	_, err = NewTransportClient(network, "node1", "node2", 100)
+	assert.NotNil(t, err)
+
+	// the request is lost
+	err = client.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &Response{})
+	assert.Equal(t, "rpc.client.call[TestServer.Ping].timeout[100]", err.Error())
+	assert.Equal(t, 1, server.count)
+
+	// the response is lost, the request still reaches the server
+	network.Heal("node1", "node2")
+	network.Cut("node2", "node1")
+	err = network_call_ForTest(network, 100)
+	assert.Equal(t, "rpc.client.call[TestServer.Ping].timeout[100]", err.Error())
+	assert.Equal(t, 2, server.count)
+
+	// heal
+	network.HealAll()
+	err = network_call_ForTest(network, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, server.count)
+}
+
+func TestNetworkDelayAndDrop(t *testing.T) {
+	network := NewNetwork()
+	server := startNetworkServerForTest(t, network, "node2")
+	defer server.stop()
+
+	// delay
+	network.Delay("node1", "node2", 200*time.Millisecond)
+	err := network_call_ForTest(network, 100)
+	assert.Equal(t, "rpc.client.call[TestServer.Ping].timeout[100]", err.Error())
+	err = network_call_ForTest(network, 1000)
+	assert.Nil(t, err)
+
+	// drop all
+	network.HealAll()
+	network.Drop("node1", "node2", 100)
+	err = network_call_ForTest(network, 100)
+	assert.NotNil(t, err)
+
+	// drop half
+	network.Drop("node1", "node2", 50)
+	lost := 0
+	for i := 0; i < 20; i++ {
+		if err := network_call_ForTest(network, 20); err != nil {
+			lost++
+		}
+	}
+	assert.True(t, lost > 0 && lost < 20)
+}
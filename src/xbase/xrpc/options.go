@@ -20,6 +20,7 @@ var (
 type Options struct {
 	ConnectionStr string
 	Log           *xlog.Log
+	Transport     Transport
 }
 
 type Option func(*Options)
@@ -37,6 +38,10 @@ func newOptions(opts ...Option) *Options {
 	if len(opt.ConnectionStr) == 0 {
 		opt.ConnectionStr = DefaultConnectionStr
 	}
+
+	if opt.Transport == nil {
+		opt.Transport = DefaultTransport
+	}
 	return opt
 }
 
@@ -55,3 +60,11 @@ func Log(v *xlog.Log) Option {
 		o.Log = v
 	}
 }
+
+// UseTransport:
+// server transport, default is TCP
+func UseTransport(v Transport) Option {
+	return func(o *Options) {
+		o.Transport = v
+	}
+}
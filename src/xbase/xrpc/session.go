@@ -0,0 +1,208 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// minReconnectBackoff is the wait before the redial after the first failed dial, it doubles for every failure.
+	minReconnectBackoff = 50 * time.Millisecond
+
+	// maxReconnectBackoff is the max wait before the redial.
+	maxReconnectBackoff = 1 * time.Second
+
+	// maxSessionTimeouts is the number of the consecutive timed-out calls to drop the connection,
+	// the connection may be half-open.
+	maxSessionTimeouts = 3
+)
+
+// Session is a long-lived client from->connStr.
+// The calls are multiplexed on one connection, the connection is dialed in background
+// when the session is created and redialed with backoff when it's broken, so the dial
+// never delays a call on a healthy session.
+type Session struct {
+	transport Transport
+	from      string
+	connStr   string
+	timeout   int // dial timeout in milliseconds
+
+	mutex     sync.Mutex
+	rpcClient *rpc.Client
+	dialing   chan struct{} // closed when the running dial is done, nil if no dial runs
+	dialErr   error         // error of the last dial
+	backoff   time.Duration
+	retryAt   time.Time // no dial before it
+	timeouts  int       // consecutive timed-out calls
+	closed    bool
+}
+
+// NewSession creates the session from the address from to connStr over the transport and starts dialing.
+func NewSession(transport Transport, from string, connStr string, timeout int) *Session {
+	s := &Session{
+		transport: transport,
+		from:      from,
+		connStr:   connStr,
+		timeout:   timeout,
+	}
+	s.mutex.Lock()
+	s.dial()
+	s.mutex.Unlock()
+	return s
+}
+
+// dial starts the dial in background, the caller must hold the mutex.
+func (s *Session) dial() chan struct{} {
+	if s.dialing != nil {
+		return s.dialing
+	}
+
+	dialing := make(chan struct{})
+	s.dialing = dialing
+	go func() {
+		conn, err := s.transport.Dial(s.from, s.connStr, time.Duration(s.timeout)*time.Millisecond)
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		switch {
+		case s.closed:
+			if err == nil {
+				conn.Close()
+			}
+		case err != nil:
+			s.dialErr = err
+			s.backoff *= 2
+			if s.backoff < minReconnectBackoff {
+				s.backoff = minReconnectBackoff
+			}
+			if s.backoff > maxReconnectBackoff {
+				s.backoff = maxReconnectBackoff
+			}
+			s.retryAt = time.Now().Add(s.backoff)
+		default:
+			s.rpcClient = newRPCClient(s.transport, conn)
+			s.dialErr = nil
+			s.backoff = 0
+			s.timeouts = 0
+		}
+		s.dialing = nil
+		close(dialing)
+	}()
+	return dialing
+}
+
+// connect returns the connected rpc client, it waits for the running dial at most timeout milliseconds.
+// if the session is in the backoff, it fails at once.
+func (s *Session) connect(timeout int) (*rpc.Client, error) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, errors.New("xrpc.session.is.closed")
+	}
+	if s.rpcClient != nil {
+		rpcClient := s.rpcClient
+		s.mutex.Unlock()
+		return rpcClient, nil
+	}
+	dialing := s.dialing
+	if dialing == nil {
+		if wait := time.Until(s.retryAt); wait > 0 {
+			err := s.dialErr
+			s.mutex.Unlock()
+			return nil, errors.Wrapf(err, "xrpc.session[%v].not.connected.retry.in[%v]", s.connStr, wait)
+		}
+		dialing = s.dial()
+	}
+	s.mutex.Unlock()
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-dialing:
+	case <-timer.C:
+		return nil, errors.Errorf("xrpc.session[%v].connect.timeout[%v]", s.connStr, timeout)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rpcClient == nil {
+		return nil, errors.WithStack(s.dialErr)
+	}
+	return s.rpcClient, nil
+}
+
+// reset drops the broken connection and redials in background.
+func (s *Session) reset(rpcClient *rpc.Client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rpcClient != rpcClient || s.closed {
+		return
+	}
+	s.rpcClient.Close()
+	s.rpcClient = nil
+	s.dial()
+}
+
+// CallTimeout makes a call to the remote server with timeout in milliseconds.
+// the timed-out call won't close the session, but maxSessionTimeouts consecutive ones
+// drop the connection and redial.
+func (s *Session) CallTimeout(timeout int, method string, args interface{}, reply interface{}) error {
+	rpcClient, err := s.connect(timeout)
+	if err != nil {
+		return err
+	}
+
+	err = callTimeout(rpcClient, s.transport, s.from, s.connStr, timeout, method, args, reply)
+	switch errors.Cause(err).(type) {
+	case nil:
+		s.mutex.Lock()
+		s.timeouts = 0
+		s.mutex.Unlock()
+	case *timeoutError:
+		s.mutex.Lock()
+		s.timeouts++
+		broken := s.timeouts >= maxSessionTimeouts
+		s.mutex.Unlock()
+		if broken {
+			s.reset(rpcClient)
+		}
+	case rpc.ServerError:
+		// the remote service answered, the connection is fine
+	default:
+		s.reset(rpcClient)
+	}
+	return err
+}
+
+// Healthy returns true if the session is connected and the last call didn't time out.
+func (s *Session) Healthy() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rpcClient != nil && s.timeouts == 0
+}
+
+// Close closes the session and its connection.
+func (s *Session) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.rpcClient != nil {
+		defer func() { s.rpcClient = nil }()
+		return s.rpcClient.Close()
+	}
+	return nil
+}
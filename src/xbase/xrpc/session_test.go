@@ -0,0 +1,118 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+	"xbase/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCall(t *testing.T) {
+	port := common.RandomPort(6000, 6670)
+	conn := fmt.Sprintf("127.0.0.1:%v", port)
+	server := &TestServer{conn: conn, count: 1}
+	server.start(t)
+	defer server.stop()
+
+	session := NewSession(DefaultTransport, "", conn, 100)
+	defer session.Close()
+
+	// the calls share one connection
+	for i := 0; i < 100; i++ {
+		rsp := Response{}
+		err := session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, i+2, rsp.Value)
+	}
+	assert.True(t, session.Healthy())
+	server.rpc.mutex.Lock()
+	assert.Equal(t, 1, len(server.rpc.conns))
+	server.rpc.mutex.Unlock()
+
+	// the server error keeps the session
+	err := session.CallTimeout(100, "xx.xx", Request{Value: 1}, &Response{})
+	assert.Equal(t, "rpc: can't find service xx.xx", err.Error())
+	assert.True(t, session.Healthy())
+
+	// closed
+	session.Close()
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &Response{})
+	assert.Equal(t, "xrpc.session.is.closed", err.Error())
+}
+
+func TestSessionTimeout(t *testing.T) {
+	port := common.RandomPort(6000, 6670)
+	conn := fmt.Sprintf("127.0.0.1:%v", port)
+	server := &TestServer{conn: conn, count: 1}
+	server.start(t)
+	defer server.stop()
+
+	session := NewSession(DefaultTransport, "", conn, 100)
+	defer session.Close()
+
+	// the timed-out calls leave no goroutine and keep the session
+	rsp := Response{}
+	err := session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.Nil(t, err)
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 2; i++ {
+		err = session.CallTimeout(100, "TestServer.PingTimeout", Request{Value: 1}, &rsp)
+		assert.Equal(t, "rpc.client.call[TestServer.PingTimeout].timeout[100]", err.Error())
+		assert.True(t, runtime.NumGoroutine() <= goroutines+i+1)
+	}
+	assert.False(t, session.Healthy())
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.Nil(t, err)
+	assert.True(t, session.Healthy())
+}
+
+func TestSessionReconnect(t *testing.T) {
+	network := NewNetwork()
+	server := startNetworkServerForTest(t, network, "node2")
+	session := NewSession(network, "node1", "node2", 100)
+	defer session.Close()
+
+	rsp := Response{}
+	err := session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.Nil(t, err)
+
+	// the server is gone, the session fails fast in the backoff
+	server.stop()
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.NotNil(t, err)
+	assert.False(t, session.Healthy())
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < minReconnectBackoff)
+
+	// the server is back, the session reconnects after the backoff
+	server = startNetworkServerForTest(t, network, "node2")
+	defer server.stop()
+	time.Sleep(maxReconnectBackoff)
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.Nil(t, err)
+	assert.True(t, session.Healthy())
+
+	// the half-open connection is dropped after the consecutive timeouts
+	network.Cut("node2", "node1")
+	for i := 0; i < maxSessionTimeouts; i++ {
+		err = session.CallTimeout(50, "TestServer.Ping", Request{Value: 1}, &rsp)
+		assert.NotNil(t, err)
+	}
+	network.HealAll()
+	err = session.CallTimeout(100, "TestServer.Ping", Request{Value: 1}, &rsp)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,198 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions are the files of the mutual TLS, in PEM.
+type TLSOptions struct {
+	// CA verifies the certificates of the peers.
+	CA string
+	// Cert and Key are the certificate of this node, it's presented to the services and the clients.
+	Cert string
+	Key  string
+	// VerifyEndpoint requires the certificate of the service to have the SAN matching the dialed host.
+	VerifyEndpoint bool
+	// VerifyClient checks the hosts(the IP and DNS SANs) of the client certificate on the service,
+	// any client signed by the CA is accepted if it's nil.
+	VerifyClient func(hosts []string) error
+}
+
+// TLSTransport encrypts the connections of the transport with the mutual TLS,
+// both the client and the service present their certificate signed by the CA.
+// The files are checked at every handshake, the changed ones are reloaded,
+// so the certificates can be rotated without restarting, the running connections keep the old ones.
+type TLSTransport struct {
+	Transport
+	opts    TLSOptions
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+}
+
+// NewTLSTransport creates the TLS transport over the transport, it returns error if the files are invalid.
+func NewTLSTransport(transport Transport, opts TLSOptions) (*TLSTransport, error) {
+	t := &TLSTransport{
+		Transport: transport,
+		opts:      opts,
+	}
+	if _, _, err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// load returns the certificate and the CA pool, they are reloaded if the files are changed.
+// if the reload fails, the old ones are kept and the error is returned.
+func (t *TLSTransport) load() (*tls.Certificate, *x509.CertPool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	modTime := make(map[string]time.Time)
+	for _, file := range []string{t.opts.CA, t.opts.Cert, t.opts.Key} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return t.cert, t.pool, errors.WithStack(err)
+		}
+		modTime[file] = info.ModTime()
+	}
+	changed := t.cert == nil
+	for file, mod := range modTime {
+		if !t.modTime[file].Equal(mod) {
+			changed = true
+		}
+	}
+	if !changed {
+		return t.cert, t.pool, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.opts.Cert, t.opts.Key)
+	if err != nil {
+		return t.cert, t.pool, errors.Wrapf(err, "xrpc.tls.load.cert[%v].key[%v].error", t.opts.Cert, t.opts.Key)
+	}
+	ca, err := ioutil.ReadFile(t.opts.CA)
+	if err != nil {
+		return t.cert, t.pool, errors.WithStack(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return t.cert, t.pool, errors.Errorf("xrpc.tls.ca[%v].has.no.certificate", t.opts.CA)
+	}
+	t.cert, t.pool, t.modTime = &cert, pool, modTime
+	return t.cert, t.pool, nil
+}
+
+// Listen announces the service on addr, the clients must present the certificate signed by the CA.
+func (t *TLSTransport) Listen(addr string) (net.Listener, error) {
+	ln, err := t.Transport.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool, _ := t.load()
+			config := &tls.Config{
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				MinVersion:   tls.VersionTLS12,
+			}
+			if t.opts.VerifyClient != nil {
+				// it's called after the chain is verified
+				config.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+					if len(chains) == 0 || len(chains[0]) == 0 {
+						return errors.New("xrpc.tls.client.has.no.verified.certificate")
+					}
+					return t.opts.VerifyClient(certHosts(chains[0][0]))
+				}
+			}
+			return config, nil
+		},
+	}
+	return tls.NewListener(ln, config), nil
+}
+
+// certHosts returns the DNS and IP SANs of the certificate.
+func certHosts(cert *x509.Certificate) []string {
+	hosts := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
+
+// Dial connects the client from to the service on addr and does the handshake within the timeout.
+func (t *TLSTransport) Dial(from string, addr string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	conn, err := t.Transport.Dial(from, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, pool, _ := t.load()
+	config := &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.opts.VerifyEndpoint {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config.ServerName = host
+	} else {
+		// the chain is still verified, only the name is not
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, pool)
+		}
+	}
+
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(deadline)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "xrpc.tls.handshake[%v->%v].error", from, addr)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// verifyChain verifies the certificate of the service is signed by the CA, without checking its name.
+func verifyChain(rawCerts [][]byte, pool *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("xrpc.tls.peer.has.no.certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{Roots: pool, Intermediates: x509.NewCertPool()}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,193 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCAForTest(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// writeTLSFilesForTest writes the CA and the node certificate with the SAN dns to dir.
+func writeTLSFilesForTest(t *testing.T, ca *testCA, dir string, dns string) TLSOptions {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dns},
+		DNSNames:     []string{dns},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	opts := TLSOptions{
+		CA:   filepath.Join(dir, "ca.pem"),
+		Cert: filepath.Join(dir, "cert.pem"),
+		Key:  filepath.Join(dir, "key.pem"),
+	}
+	// the rewritten files get the newer mtime, so they are reloaded
+	mtime := time.Now()
+	if info, err := os.Stat(opts.Cert); err == nil {
+		mtime = info.ModTime().Add(time.Second)
+	}
+	files := map[string][]byte{
+		opts.CA:   ca.pem,
+		opts.Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		opts.Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+	for file, data := range files {
+		assert.Nil(t, ioutil.WriteFile(file, data, 0600))
+		assert.Nil(t, os.Chtimes(file, mtime, mtime))
+	}
+	return opts
+}
+
+func tlsCallForTest(transport Transport) error {
+	client, err := NewTransportClient(transport, "node1", "node2", 1000)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.CallTimeout(1000, "TestServer.Ping", Request{Value: 1}, &Response{})
+}
+
+func TestTLSCall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xrpc-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	serverDir, clientDir := filepath.Join(dir, "server"), filepath.Join(dir, "client")
+	assert.Nil(t, os.Mkdir(serverDir, 0700))
+	assert.Nil(t, os.Mkdir(clientDir, 0700))
+
+	network := NewNetwork()
+	ca := newTestCAForTest(t, "ca")
+	serverTLS, err := NewTLSTransport(network, writeTLSFilesForTest(t, ca, serverDir, "node2"))
+	assert.Nil(t, err)
+	server := startNetworkServerForTest(t, serverTLS, "node2")
+	defer server.stop()
+
+	// mutual tls
+	clientOpts := writeTLSFilesForTest(t, ca, clientDir, "node1")
+	client, err := NewTLSTransport(network, clientOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, tlsCallForTest(client))
+	assert.Equal(t, 2, server.count)
+
+	// the plain client
+	assert.NotNil(t, tlsCallForTest(network))
+
+	// the SAN matches the endpoint
+	clientOpts.VerifyEndpoint = true
+	client, err = NewTLSTransport(network, clientOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, tlsCallForTest(client))
+
+	// the client signed by the other CA
+	other := newTestCAForTest(t, "other")
+	otherDir := filepath.Join(dir, "other")
+	assert.Nil(t, os.Mkdir(otherDir, 0700))
+	otherClient, err := NewTLSTransport(network, writeTLSFilesForTest(t, other, otherDir, "node1"))
+	assert.Nil(t, err)
+	assert.NotNil(t, tlsCallForTest(otherClient))
+	assert.Equal(t, 3, server.count)
+
+	// the SAN doesn't match the endpoint
+	writeTLSFilesForTest(t, ca, serverDir, "node3")
+	assert.NotNil(t, tlsCallForTest(client))
+	clientOpts.VerifyEndpoint = false
+	client, err = NewTLSTransport(network, clientOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, tlsCallForTest(client))
+	assert.Equal(t, 4, server.count)
+
+	// rotate the server to the other CA, it's reloaded without restart
+	writeTLSFilesForTest(t, other, serverDir, "node2")
+	assert.NotNil(t, tlsCallForTest(client))
+	assert.Nil(t, tlsCallForTest(otherClient))
+	assert.Equal(t, 5, server.count)
+}
+
+func TestTLSVerifyClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xrpc-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	serverDir, clientDir := filepath.Join(dir, "server"), filepath.Join(dir, "client")
+	assert.Nil(t, os.Mkdir(serverDir, 0700))
+	assert.Nil(t, os.Mkdir(clientDir, 0700))
+
+	network := NewNetwork()
+	ca := newTestCAForTest(t, "ca")
+	serverOpts := writeTLSFilesForTest(t, ca, serverDir, "node2")
+	serverOpts.VerifyClient = func(hosts []string) error {
+		for _, host := range hosts {
+			if host == "node1" {
+				return nil
+			}
+		}
+		return errors.Errorf("client%v.is.not.allowed", hosts)
+	}
+	serverTLS, err := NewTLSTransport(network, serverOpts)
+	assert.Nil(t, err)
+	server := startNetworkServerForTest(t, serverTLS, "node2")
+	defer server.stop()
+
+	// the allowed client
+	clientOpts := writeTLSFilesForTest(t, ca, clientDir, "node1")
+	client, err := NewTLSTransport(network, clientOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, tlsCallForTest(client))
+
+	// the client signed by the CA but not allowed
+	writeTLSFilesForTest(t, ca, clientDir, "node4")
+	assert.NotNil(t, tlsCallForTest(client))
+	assert.Equal(t, 2, server.count)
+}
@@ -0,0 +1,50 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xrpc
+
+import (
+	"net"
+	"time"
+)
+
+// Transport carries the connections between the clients and the services.
+// TCP is the default, the in-memory Network is for the fault-injecting tests.
+type Transport interface {
+	// Listen announces the service on addr.
+	Listen(addr string) (net.Listener, error)
+
+	// Dial connects the client from to the service on addr.
+	Dial(from string, addr string, timeout time.Duration) (net.Conn, error)
+
+	// Deliver is called before every request from->to and every response to->from,
+	// it returns the delay of the message and false if the message is lost.
+	Deliver(from string, to string) (time.Duration, bool)
+}
+
+// DefaultKeepAlive is the TCP keepalive period of the connections,
+// the long-lived sessions find the dead peers by it.
+const DefaultKeepAlive = 10 * time.Second
+
+type tcpTransport struct{}
+
+// DefaultTransport is the TCP transport.
+var DefaultTransport Transport = tcpTransport{}
+
+func (tcpTransport) Listen(addr string) (net.Listener, error) {
+	return SetListener(addr)
+}
+
+func (tcpTransport) Dial(from string, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: DefaultKeepAlive}
+	return dialer.Dial("tcp", addr)
+}
+
+func (tcpTransport) Deliver(from string, to string) (time.Duration, bool) {
+	return 0, true
+}
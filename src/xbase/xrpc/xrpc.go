@@ -9,8 +9,11 @@
 package xrpc
 
 import (
+	"fmt"
 	"net"
 	"net/rpc"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,6 +24,8 @@ type Service struct {
 	opts       *Options
 	server     *rpc.Server  // rpc server
 	listener   net.Listener // net listener
+	mutex      sync.Mutex
+	conns      map[net.Conn]struct{} // the accepted connections, closed by Stop
 }
 
 // creates a new Service with options
@@ -31,6 +36,7 @@ func NewService(opts ...Option) (*Service, error) {
 		opts:       options,
 		registered: false,
 		server:     rpc.NewServer(),
+		conns:      make(map[net.Conn]struct{}),
 	}, nil
 }
 
@@ -49,7 +55,7 @@ func (s *Service) Start() error {
 		return errors.New("xrpc.Start.error[Please RegisterService first]")
 	}
 
-	ln, err := SetListener(s.opts.ConnectionStr)
+	ln, err := s.opts.Transport.Listen(s.opts.ConnectionStr)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -62,13 +68,26 @@ func (s *Service) Start() error {
 				s.opts.Log.Error("xrpc.accept.error[%v]", err)
 				return
 			}
-			go s.server.ServeConn(conn)
+			go s.serveConn(conn)
 		}
 	}()
 	s.opts.Log.Warning("xrpc.Start.listening.on[%v]", s.listener.Addr())
 	return nil
 }
 
+// serveConn serves the connection until the client or Stop closes it.
+func (s *Service) serveConn(conn net.Conn) {
+	s.mutex.Lock()
+	s.conns[conn] = struct{}{}
+	s.mutex.Unlock()
+
+	serveRPCConn(s.server, s.opts.Transport, s.opts.Log, conn)
+
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+}
+
 func SetListener(addr string) (net.Listener, error) {
 	var lis net.Listener
 	var err error
@@ -91,66 +110,167 @@ func (s *Service) Stop() {
 			return
 		}
 	}
+
+	// the clients keep their connections, close them as the service is gone
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
 	s.opts.Log.Warning("xrpc[%v].Stop.done", s.opts.ConnectionStr)
 }
 
 type Client struct {
 	connStr   string
+	from      string
 	timeout   int
+	transport Transport
 	rpcClient *rpc.Client
+	once      sync.Once
+	closed    chan struct{}
 }
 
 func NewClient(connStr string, timeout int) (*Client, error) {
+	return NewTransportClient(DefaultTransport, "", connStr, timeout)
+}
+
+// NewTransportClient creates the client from the address from to connStr over the transport.
+func NewTransportClient(transport Transport, from string, connStr string, timeout int) (*Client, error) {
 	var err error
 	var rpcClient *rpc.Client
 
-	if rpcClient, err = getNewRpcClient(connStr, timeout); err != nil {
+	if rpcClient, err = getNewRpcClient(transport, from, connStr, timeout); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	return &Client{connStr: connStr,
+		from:      from,
 		timeout:   timeout,
-		rpcClient: rpcClient}, nil
+		transport: transport,
+		rpcClient: rpcClient,
+		closed:    make(chan struct{})}, nil
 }
 
-func getNewRpcClient(connStr string, timeout int) (*rpc.Client, error) {
-	conn, err := net.DialTimeout("tcp", connStr, time.Duration(timeout)*time.Millisecond)
+func getNewRpcClient(transport Transport, from string, connStr string, timeout int) (*rpc.Client, error) {
+	conn, err := transport.Dial(from, connStr, time.Duration(timeout)*time.Millisecond)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return rpc.NewClient(conn), nil
+	return newRPCClient(transport, conn), nil
 }
 
 // make a client call to remote server(without retry)
 func (c *Client) Call(method string, args interface{}, reply interface{}) error {
-	if c.rpcClient == nil {
+	// the delayed message may outlive the client, Close resets c.rpcClient
+	rpcClient := c.rpcClient
+	if rpcClient == nil {
 		return errors.New("xrpc.client.is.closed")
 	} else {
-		if err := c.rpcClient.Call(method, args, reply); err != nil {
+		if !c.deliver(c.from, c.connStr) {
+			return c.lost(method)
+		}
+		if err := rpcClient.Call(method, args, reply); err != nil {
 			return errors.WithStack(err)
 		}
+		if !c.deliver(c.connStr, c.from) {
+			return c.lost(method)
+		}
 	}
 	return nil
 }
 
+// deliver waits the delay of the message, it returns false if the message is lost.
+func (c *Client) deliver(from string, to string) bool {
+	delay, ok := c.transport.Deliver(from, to)
+	if ok && delay > 0 {
+		time.Sleep(delay)
+	}
+	return ok
+}
+
+// lost blocks until the client is closed, as the lost message never comes.
+func (c *Client) lost(method string) error {
+	<-c.closed
+	return errors.Errorf("rpc.client.call[%v].message.lost", method)
+}
+
+// make a client call to remote server with timeout in milliseconds, the client is closed if the call times out
 func (c *Client) CallTimeout(timeout int, method string, args interface{}, reply interface{}) error {
-	errCh := make(chan error, 1)
-	go func() {
-		err := c.Call(method, args, reply)
-		errCh <- err
-	}()
+	rpcClient := c.rpcClient
+	if rpcClient == nil {
+		return errors.New("xrpc.client.is.closed")
+	}
 
-	select {
-	case err := <-errCh:
-		return err
-	case <-time.After(time.Millisecond * time.Duration(timeout)):
+	err := callTimeout(rpcClient, c.transport, c.from, c.connStr, timeout, method, args, reply)
+	if _, ok := err.(*timeoutError); ok {
 		c.Close()
-		return errors.Errorf("rpc.client.call[%v].timeout[%v]", method, timeout)
 	}
+	return err
+}
+
+// timeoutError is the error of the call timed out.
+type timeoutError struct {
+	method  string
+	timeout int
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("rpc.client.call[%v].timeout[%v]", e.method, e.timeout)
+}
+
+// callTimeout makes a call from->to over the rpcClient and waits at most timeout milliseconds.
+// No goroutine is left behind when the call times out, the late reply is decoded into a copy
+// of the reply and dropped, so the caller can reuse the reply at once.
+func callTimeout(rpcClient *rpc.Client, transport Transport, from string, to string, timeout int, method string, args interface{}, reply interface{}) error {
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+
+	// request
+	if !waitDeliver(transport, from, to, timer) {
+		return &timeoutError{method: method, timeout: timeout}
+	}
+	value := reflect.New(reflect.TypeOf(reply).Elem())
+	call := rpcClient.Go(method, args, value.Interface(), make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-timer.C:
+		return &timeoutError{method: method, timeout: timeout}
+	}
+	if call.Error != nil {
+		return errors.WithStack(call.Error)
+	}
+
+	// response
+	if !waitDeliver(transport, to, from, timer) {
+		return &timeoutError{method: method, timeout: timeout}
+	}
+	reflect.ValueOf(reply).Elem().Set(value.Elem())
+	return nil
+}
+
+// waitDeliver waits the delay of the message from->to, it returns false if the message is lost or the timer fires.
+// the lost message never comes, so it waits for the timer.
+func waitDeliver(transport Transport, from string, to string, timer *time.Timer) bool {
+	delay, ok := transport.Deliver(from, to)
+	if !ok {
+		<-timer.C
+		return false
+	}
+	if delay > 0 {
+		wait := time.NewTimer(delay)
+		defer wait.Stop()
+		select {
+		case <-wait.C:
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
 }
 
 // close the client connection
 func (c *Client) Close() error {
+	c.once.Do(func() { close(c.closed) })
 	if c.rpcClient != nil {
 		defer func() { c.rpcClient = nil }()
 		return c.rpcClient.Close()
@@ -10,6 +10,7 @@ package main
 
 import (
 	"build"
+	"cli/callx"
 	"config"
 	"ctl"
 	"flag"
@@ -29,8 +30,8 @@ var (
 func init() {
 	flag.StringVar(&flag_conf, "c", "", "xenon config file")
 	flag.StringVar(&flag_conf, "config", "", "xenon config file")
-	flag.StringVar(&flag_role, "r", "", "role type:[LEADER|FOLLOWER|IDLE]")
-	flag.StringVar(&flag_role, "role", "", "role type:[LEADER|FOLLOWER|IDLE]")
+	flag.StringVar(&flag_role, "r", "", "role type:[LEADER|FOLLOWER|IDLE|WITNESS]")
+	flag.StringVar(&flag_role, "role", "", "role type:[LEADER|FOLLOWER|IDLE|WITNESS]")
 }
 
 func main() {
@@ -63,6 +64,8 @@ func main() {
 		state = raft.FOLLOWER
 	case "IDLE":
 		state = raft.IDLE
+	case "WITNESS":
+		state = raft.WITNESS
 	default:
 		state = raft.UNKNOW
 	}
@@ -74,6 +77,11 @@ func main() {
 	log.Warning("xenon.conf.mysql:[%+v]", conf.Mysql)
 	log.Warning("xenon.conf.mysqld:[%+v]", conf.Backup)
 
+	// the admin APIs call the nodes as xenoncli does
+	if err := callx.SetRPCConfig(conf); err != nil {
+		log.Panic("xenon.set.rpc.config.error[%v]", err)
+	}
+
 	// server
 	server := server.NewServer(conf, log, state)
 	server.Init()